@@ -1,15 +1,28 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"crypto"
+	"encoding/hex"
+	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"io/fs"
 	"math/big"
 	"os"
+	"os/signal"
+	"path/filepath"
 	"rsa/utils"
 	"strings"
 	"time"
 )
 
+// Поток для сообщений программы
+// при выводе результата в стандартный вывод подменяется на стандартный поток ошибок
+var msgOut io.Writer = os.Stdout
+
 // Чтение публичного ключа из файла в параметре --public-key
 func readPubkey(fKey string) (*utils.PublicKey, error) {
 	// Читаем байтовое содержимое файла
@@ -17,29 +30,9 @@ func readPubkey(fKey string) (*utils.PublicKey, error) {
 	if err != nil {
 		return nil, err
 	}
-	// Переводим в строку
-	keyString := string(bytes)
-
-	// Разбиваем на подстроки по переносу строки
-	keyItems := strings.Split(keyString, "\n")
-	// Проверяем что строк в файле было 2, если нет - вернуть ошибку
-	if len(keyItems) != 2 {
-		return nil, fmt.Errorf("Невозможно получить публичный ключ из файла. Неверное количество строк %d, должно быть 2", len(keyItems))
-	}
-	// Переводим строковое представление e в число
-	e, ok := new(big.Int).SetString(keyItems[0], 10)
-	// Если перевести в число не удалось - вернуть ошибку
-	if !ok {
-		return nil, fmt.Errorf("Невозможно получить e из файла. Она должен быть числом в десятичном представлении на первой строке.")
-	}
-	// Переводим строковое представление n в число
-	n, ok := new(big.Int).SetString(keyItems[1], 10)
-	// Если перевести в число не удалось - вернуть ошибку
-	if !ok {
-		return nil, fmt.Errorf("Невозможно получить n из файла. Она должен быть числом в десятичном представлении на второй строке.")
-	}
-	// инициализируем и возвращаем публичный ключ
-	return utils.NewPublicKey(e, n), nil
+	// Разбираем e и n
+	// Подробнее в utils/rsa.go
+	return utils.ParsePublicKey(bytes)
 }
 
 // Чтение приватного ключа из файла в параметре --private-key
@@ -49,33 +42,82 @@ func readPrivkey(fKey string) (*utils.PrivateKey, error) {
 	if err != nil {
 		return nil, err
 	}
-	// Переводим в строку
-	keyString := string(bytes)
-	// Разбиваем на подстроки по переносу строки
-	keyItems := strings.Split(keyString, "\n")
-	// Проверяем что в файле была 1 строка, если нет - вернуть ошибку
-	if len(keyItems) != 1 {
-		return nil, fmt.Errorf("Невозможно получить приватный ключ из файла. Неверное количество строк %d, должно быть 1", len(keyItems))
-	}
-	// Переводим строковое представление в число d
-	d, ok := new(big.Int).SetString(keyItems[0], 10)
-	// Если перевести в число не удалось - вернуть ошибку
-	if !ok {
-		return nil, fmt.Errorf("Невозможно приватный ключ из файла. Ключ должен быть числом в десятичном представлении.")
+	// Разбираем d
+	// Подробнее в utils/rsa.go
+	return utils.ParsePrivateKey(bytes)
+}
+
+// Список значений флага, который можно указать несколько раз
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// Коды завершения программы
+const (
+	exitOK = iota
+	exitError
+	exitFileError
+	exitKeyFormat
+	exitKeyMismatch
+	exitMalformedCiphertext
+	exitMessageTooLong
+	exitNotRecipient
+	exitAuthentication
+	exitVerification
+	exitInterrupted = 130
+)
+
+// Вывод ошибки с понятным пояснением, возвращает код завершения, соответствующий ее типу
+func errorExitCode(prefix string, err error) int {
+	code, hint := exitError, ""
+	switch {
+	case errors.Is(err, utils.ErrKeyFormat):
+		code, hint = exitKeyFormat, "Проверьте, что указан верный файл ключа."
+	case errors.Is(err, utils.ErrKeyMismatch):
+		code, hint = exitKeyMismatch, "Приватный ключ не подходит к публичному, укажите ключи из одной пары."
+	case errors.Is(err, utils.ErrNotRecipient):
+		code, hint = exitNotRecipient, "Файл зашифрован для других получателей, укажите свою пару ключей."
+	case errors.Is(err, utils.ErrAuthentication):
+		code, hint = exitAuthentication, "Файл не расшифрован: ключ не подходит или шифр был изменен. Результат не записан."
+	case errors.Is(err, utils.ErrVerification):
+		code, hint = exitVerification, "Файл или подпись изменены, либо подпись сделана другим ключом или с другими параметрами."
+	case errors.Is(err, errUnauthenticated):
+		code, hint = exitAuthentication, "Укажите параметр --unauthenticated, если доверяете источнику файла."
+	case errors.Is(err, utils.ErrMalformedCiphertext):
+		code, hint = exitMalformedCiphertext, "Файл поврежден или не является шифром."
+	case errors.Is(err, utils.ErrMessageTooLong):
+		code, hint = exitMessageTooLong, "Сообщение не помещается в блок, используйте ключ большего размера."
+	case errors.Is(err, context.Canceled):
+		code, hint = exitInterrupted, "Работа прервана пользователем."
+	case errors.Is(err, fs.ErrNotExist), errors.Is(err, fs.ErrPermission):
+		code, hint = exitFileError, "Проверьте пути к файлам и права доступа."
 	}
 
-	// Проверяем что d != 0
-	if d.Cmp(big.NewInt(0)) == 0 {
-		return nil, fmt.Errorf("Невозможно получить приватный ключ, неверное содержимое файла")
+	fmt.Fprintf(msgOut, "%s: %s\n", prefix, err.Error())
+	if hint != "" {
+		fmt.Fprintln(msgOut, hint)
 	}
-	return utils.NewPrivateKey(d), nil
+	return code
 }
 
-// Генерация ключевой пары
-func genKeyPair() (string, string, error) {
+// Генерация ключевой пары, при bits > 0 - учебной с модулем из bits бит
+func genKeyPair(bits int) (string, string, error) {
 	// Генерируем публичный и приватный ключ, если произошла ошибка - возвращаем ее
 	// Подробнее в utils/signature.go
-	pubKey, privKey, err := utils.GenerateKeyPair()
+	generate := utils.GenerateKeyPair
+	if bits > 0 {
+		generate = func() (*utils.PublicKey, *utils.PrivateKey, error) {
+			return utils.GenerateKeyPairBits(bits)
+		}
+	}
+	pubKey, privKey, err := generate()
 	if err != nil {
 		return "", "", err
 	}
@@ -109,33 +151,195 @@ func genKeyPair() (string, string, error) {
 	return pubKeyFile, privKeyFile, nil
 }
 
-func ChipherFile(filename, outputFile, publicKeyFile string) error {
-	// Получаем публичный ключ из файла в параметре --public-key
-	pKey, err := readPubkey(publicKeyFile)
+// Открытие входного файла, "-" означает стандартный ввод
+func openInput(filename string) (io.ReadCloser, error) {
+	if filename == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+	return os.Open(filename)
+}
+
+// Создание выходного файла, "-" означает стандартный вывод
+func createOutput(filename string) (io.WriteCloser, error) {
+	if filename == "-" {
+		return nopWriteCloser{os.Stdout}, nil
+	}
+	return os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+}
+
+// Обертка для стандартного вывода, который не нужно закрывать
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+// Промежуточный выходной файл.
+// Результат пишется во временный файл и попадает в выходной только после успешного завершения,
+// поэтому при ошибке проверки на диске не остается непроверенных данных.
+type stagedOutput struct {
+	*os.File
+	target string
+}
+
+// Создание временного файла рядом с выходным, чтобы переименование было атомарным
+// для стандартного вывода временный файл создается во временном каталоге
+func createStagedOutput(outputFile string) (*stagedOutput, error) {
+	dir := os.TempDir()
+	if outputFile != "-" {
+		dir = filepath.Dir(outputFile)
+	}
+	f, err := os.CreateTemp(dir, ".rsa-*.tmp")
 	if err != nil {
+		return nil, err
+	}
+	return &stagedOutput{File: f, target: outputFile}, nil
+}
+
+// Перенос результата в выходной файл или в стандартный вывод
+func (s *stagedOutput) commit() error {
+	if s.target == "-" {
+		defer s.discard()
+		if _, err := s.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		_, err := io.Copy(os.Stdout, s.File)
+		return err
+	}
+	if err := s.Close(); err != nil {
+		os.Remove(s.Name())
 		return err
 	}
+	return os.Rename(s.Name(), s.target)
+}
 
-	// Читаем байтовое содержимое файла
-	bytes, err := os.ReadFile(filename)
+// Удаление временного файла без переноса результата
+func (s *stagedOutput) discard() {
+	s.Close()
+	os.Remove(s.Name())
+}
+
+// Потоковое копирование из входного файла в выходной через функцию преобразования
+// выходной файл закрывается с проверкой ошибки, чтобы не потерять недописанные данные
+// при staged == true результат появляется в выходном файле только если преобразование завершилось без ошибок
+func transformFile(filename, outputFile string, staged bool, transform func(dst io.Writer, src io.Reader) error) error {
+	in, err := openInput(filename)
 	if err != nil {
 		return err
 	}
+	defer in.Close()
 
-	// Формируем цифровую подпись
-	// Подробнее в utils/signature.go
-	chiper := pKey.ShipherBytes(bytes)
+	if staged {
+		out, err := createStagedOutput(outputFile)
+		if err != nil {
+			return err
+		}
+		bw := bufio.NewWriter(out)
+		if err = transform(bw, in); err == nil {
+			err = bw.Flush()
+		}
+		if err != nil {
+			out.discard()
+			return err
+		}
+		return out.commit()
+	}
+
+	out, err := createOutput(outputFile)
 	if err != nil {
 		return err
 	}
 
-	// Записываем шифр в файл переданный в параметре -o
-	err = os.WriteFile(outputFile, []byte(chiper), 0600)
+	// буферизуем запись, шифр пишется небольшими блоками
+	bw := bufio.NewWriter(out)
+	if err = transform(bw, in); err == nil {
+		err = bw.Flush()
+	}
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Ошибка: поблочный шифр не защищен от подделки, а расшифровать его без проверки не разрешено
+var errUnauthenticated = errors.New("файл в поблочном формате без имитовставки, его целостность невозможно проверить")
+
+// Расшифрование потока шифра
+// конверт (результат -enc) определяется по сигнатуре, иначе шифр считается поблочным в формате ShipherBytes
+// поблочный шифр не защищен от подделки, поэтому расшифровывается только при allowRaw == true
+func decryptStream(ctx context.Context, dst io.Writer, src io.Reader, privKey *utils.PrivateKey, pubKey *utils.PublicKey, jobs int, allowRaw bool) error {
+	br := bufio.NewReader(src)
+	header, _ := br.Peek(utils.EnvelopeMagicLen)
+
+	var plain io.Reader
+	if utils.IsEnvelope(header) {
+		// находим свой слот среди получателей и расшифровываем содержимое
+		// Подробнее в utils/envelope.go
+		r, err := utils.NewEnvelopeReaderContext(ctx, br, privKey, pubKey)
+		if err != nil {
+			return err
+		}
+		plain = r
+	} else {
+		if !allowRaw {
+			return errUnauthenticated
+		}
+		// расшифровываем поблочно в jobs горутинах
+		// Подробнее в utils/stream.go
+		plain = utils.NewDecryptReaderContext(ctx, br, privKey, pubKey, jobs)
+	}
+
+	_, err := io.Copy(dst, plain)
 	return err
 }
 
-func DeChipherFile(filename, outputFile, publicKeyFile, privateKeyFile string) error {
+// Зашифрование файла конвертом для всех получателей из publicKeyFiles
+// при raw == true - поблочно в формате ShipherBytes для одного получателя: такой шифр не защищен от подделки,
+// он нужен для совместимости и как вход для атак на поблочный формат
+func ChipherFile(ctx context.Context, filename, outputFile string, publicKeyFiles []string, jobs int, raw bool) error {
+	if raw && len(publicKeyFiles) != 1 {
+		return errors.New("в поблочном формате файл шифруется только для одного получателя")
+	}
+
+	// Получаем публичные ключи всех получателей из параметров --public-key
+	recipients := make([]*utils.PublicKey, 0, len(publicKeyFiles))
+	for _, publicKeyFile := range publicKeyFiles {
+		pKey, err := readPubkey(publicKeyFile)
+		if err != nil {
+			return fmt.Errorf("%s: %w", publicKeyFile, err)
+		}
+		recipients = append(recipients, pKey)
+	}
+
+	if raw {
+		// Шифруем файл поблочно в jobs горутинах, не читая его целиком в память
+		// Подробнее в utils/stream.go
+		return transformFile(filename, outputFile, false, func(dst io.Writer, src io.Reader) error {
+			ew := utils.NewEncryptWriterContext(ctx, dst, recipients[0], jobs)
+			if _, err := io.Copy(ew, src); err != nil {
+				return err
+			}
+			return ew.Close()
+		})
+	}
+
+	// Шифруем файл ключом содержимого, а его передаем каждому получателю, слоты вычисляются в jobs горутинах
+	// Подробнее в utils/envelope.go
+	return transformFile(filename, outputFile, false, func(dst io.Writer, src io.Reader) error {
+		ew, err := utils.NewEnvelopeWriterContext(ctx, dst, recipients, nil, jobs)
+		if err != nil {
+			return err
+		}
+		if _, err := io.Copy(ew, src); err != nil {
+			return err
+		}
+		return ew.Close()
+	})
+}
+
+func DeChipherFile(ctx context.Context, filename, outputFile, publicKeyFile, privateKeyFile string, jobs int, expBlinding, allowRaw bool) error {
 	// Получаем публичный ключ из файла в параметре --public-key
+	// по его отпечатку находится слот получателя в конверте
 	pubKey, err := readPubkey(publicKeyFile)
 	if err != nil {
 		return err
@@ -146,37 +350,25 @@ func DeChipherFile(filename, outputFile, publicKeyFile, privateKeyFile string) e
 	if err != nil {
 		return err
 	}
-
-	// Читаем байтовое содержимое файла
-	chipher, err := os.ReadFile(filename)
-	if err != nil {
-		return err
-	}
-
-	fmt.Println(len(chipher))
+	// шифр всегда ослепляется перед расшифрованием, степень - по параметру --exponent-blinding
+	// Подробнее в utils/blinding.go
+	privKey.ExponentBlinding = expBlinding
 
 	// запускаем процедуру расшифрования
 	// необходим и публичный ключ, потому что он содержит n
-	M := privKey.DeShipherBytes(string(chipher), pubKey)
-
-	// Записываем результат в файл, переданный в параметре -o
-	err = os.WriteFile(outputFile, M, 0600)
-	return err
+	// результат попадает в выходной файл только после проверки имитовставки
+	return transformFile(filename, outputFile, true, func(dst io.Writer, src io.Reader) error {
+		return decryptStream(ctx, dst, src, privKey, pubKey, jobs, allowRaw)
+	})
 }
 
-func Wiener(filename, publicKeyFile, outputFile string) (bool, *big.Int, [][2]*big.Int, error) {
+func Wiener(ctx context.Context, filename, publicKeyFile, outputFile string, jobs int) (bool, *big.Int, [][2]*big.Int, error) {
 	// Получаем публичный ключ из файла в параметре --public-key
 	pubKey, err := readPubkey(publicKeyFile)
 	if err != nil {
 		return false, nil, nil, err
 	}
 
-	// Читаем байтовое содержимое файла
-	bytes, err := os.ReadFile(filename)
-	if err != nil {
-		return false, nil, nil, err
-	}
-
 	// запускаем процедуру атаки
 	// если завершится удачно, d != nil
 	// также возвращает коэфициенты непрерывной дроби
@@ -186,148 +378,1394 @@ func Wiener(filename, publicKeyFile, outputFile string) (bool, *big.Int, [][2]*b
 		// инициализируем приватный ключ полученным значением
 		privateKey := utils.NewPrivateKey(d)
 
-		// вызываем процедуру расшифрования
-		M := privateKey.DeShipherBytes(string(bytes), pubKey)
-
-		// Записываем результат в файл, переданный в параметре -o
-		err = os.WriteFile(outputFile, M, 0600)
+		// вызываем процедуру расшифрования и записываем результат в файл, переданный в параметре -o
+		// атакуемые шифры обычно в поблочном формате, поэтому он разрешен
+		err = transformFile(filename, outputFile, true, func(dst io.Writer, src io.Reader) error {
+			return decryptStream(ctx, dst, src, privateKey, pubKey, jobs, true)
+		})
 		return true, d, quotients, err
 	} else {
 		return false, nil, quotients, nil
 	}
 }
 
-func main() {
-	// установка перчня флагов (аргументов) принимаемых программой с их описанием
-	fPath := flag.String("f", "", "Путь к файлу для защифрования или расшифрования")
-	fPublicKey := flag.String("public-key", "", "Путь к файлу с публичным ключем пользователя")
-	fPrivateKey := flag.String("private-key", "", "Путь к файлу с приватным ключем пользователя")
-	outputFile := flag.String("o", "", "Путь к файлу куда сохранить результаты зашифрования или расшифрования")
-	genMode := flag.Bool("gen", false, "Запуск в режиме генерации ключей пользователя.  Ключи сохраняются в текущий дериктории <timestamp>_public.rsakey и <timestamp>_private.rsakey")
-	cMode := flag.Bool("enc", false, "Запуск в режиме зашифрования")
-	dMode := flag.Bool("dec", false, "Запуск в режиме расшифрования")
-	wMode := flag.Bool("wiener", false, "Запуск в режиме попытки проведения атаки Винера")
+// Хэш-функция по имени из параметра --hash
+func parseHash(name string) (crypto.Hash, error) {
+	switch strings.ToLower(name) {
+	case "sha256", "sha-256":
+		return crypto.SHA256, nil
+	case "sha384", "sha-384":
+		return crypto.SHA384, nil
+	case "sha512", "sha-512":
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("неизвестная хэш-функция %q, поддерживаются sha256, sha384 и sha512", name)
+}
 
-	// Парсим флаги
-	flag.Parse()
+// Потоковое вычисление хэша файла, "-" означает стандартный ввод
+func hashFile(filename string, h crypto.Hash) ([]byte, error) {
+	in, err := openInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
 
-	// проверяем что одновременно не заданы режим проверки и формирования подписи
-	if (*cMode && *dMode) || (*cMode && *genMode) || (*genMode && *dMode) ||
-		(*wMode && *dMode) || (*wMode && *genMode) || (*cMode && *wMode) {
-		fmt.Println("Одновременно указаны несколько режимов работы. Это не допустимо, укажите один")
-		os.Exit(1)
+	hh := h.New()
+	if _, err := io.Copy(hh, in); err != nil {
+		return nil, err
 	}
+	return hh.Sum(nil), nil
+}
 
-	// режим генерации ключевой пары
-	if *genMode {
-		fmt.Println("Выбран режим генерации ключевой пары!")
-		// запускаем процедуру генерации
-		// в ней же происходит сохранение
-		pubKey, privKey, err := genKeyPair()
-		if err != nil {
-			fmt.Printf("Во время генерации ключей произошла ошибка: %s\n", err.Error())
-			os.Exit(1)
-		}
-		fmt.Println("Ключевая пара создана и сохранена успешно!")
-		fmt.Printf("Публичный ключ: %s\n", pubKey)
-		fmt.Printf("Приватный ключ: %s\n", privKey)
-		os.Exit(0)
+// Схемы подписи для параметра --scheme
+const (
+	schemePSS      = "pss"
+	schemePKCS1v15 = "pkcs1v15"
+)
+
+// Параметры подписи из параметров --scheme, --hash и --salt-len
+type signatureParams struct {
+	scheme string
+	// хэш-функция и длина соли, длина соли используется только схемой PSS
+	opts *utils.PSSOptions
+}
+
+// Подпись хэша файла по выбранной схеме
+// Подробнее в utils/pss.go и utils/pkcs1v15.go
+func (p *signatureParams) sign(privKey *utils.PrivateKey, pubKey *utils.PublicKey, digest []byte) ([]byte, error) {
+	if p.scheme == schemePKCS1v15 {
+		return privKey.SignPKCS1v15(pubKey, p.opts.Hash, digest)
 	}
+	return privKey.SignPSS(nil, pubKey, digest, p.opts)
+}
 
-	// Проверяем что задан путь к файлу
-	if *fPath == "" {
-		fmt.Println("Не указан путь к файлу. Укажите параметр --f <имя файла>")
-		os.Exit(1)
+// Проверка подписи хэша файла по выбранной схеме
+func (p *signatureParams) verify(pubKey *utils.PublicKey, digest, sig []byte) error {
+	if p.scheme == schemePKCS1v15 {
+		return pubKey.VerifyPKCS1v15(p.opts.Hash, digest, sig)
 	}
+	return pubKey.VerifyPSS(digest, sig, p.opts)
+}
 
-	// Проверяем что задан путь к файлу с ключом формирования подписи (приватный ключ)
-	if *fPublicKey == "" {
-		fmt.Println("Не указан путь к файлу с публичным ключом. Укажите параметр --public-key <имя файла>")
-		os.Exit(1)
+func SignFile(filename, signatureFile, publicKeyFile, privateKeyFile string, params *signatureParams, expBlinding bool) error {
+	// Получаем публичный ключ из файла в параметре --public-key
+	pubKey, err := readPubkey(publicKeyFile)
+	if err != nil {
+		return err
 	}
 
-	// Проверяем что задан путь к файлу с ключом формирования подписи (приватный ключ)
-	if *outputFile == "" {
-		fmt.Println("Не указан путь к файлу для сохранения результатов. Укажите параметр --o <имя файла>")
-		os.Exit(1)
+	// Получаем приватный ключ из файла в параметре --private-key
+	privKey, err := readPrivkey(privateKeyFile)
+	if err != nil {
+		return err
 	}
+	privKey.ExponentBlinding = expBlinding
 
-	if *wMode {
-		fmt.Println("Выбран режим попытки проведения атаки Винера!")
-		fmt.Printf("Путь к файлу: %s\n", *fPath)
-		fmt.Printf("Путь к файлу публичного ключа: %s\n", *fPublicKey)
+	// подписывается хэш файла, сам файл в память не читается
+	digest, err := hashFile(filename, params.opts.Hash)
+	if err != nil {
+		return err
+	}
 
-		ok, d, approx, err := Wiener(*fPath, *fPublicKey, *outputFile)
-		if err != nil {
-			fmt.Printf("Во время попытки атаки Винера произошла ошибка: %s\n", err.Error())
-			os.Exit(1)
-		}
+	sig, err := params.sign(privKey, pubKey, digest)
+	if err != nil {
+		return err
+	}
 
-		if ok {
-			fmt.Printf("Атака завершилась успешно. Публичный ключ d = %s\n", d)
-			fmt.Printf("Были рассмотрены следующие подходящие дроби: [")
-			for i := 0; i < len(approx); i++ {
-				p := approx[i][0]
-				q := approx[i][1]
-				fmt.Printf("%s/%s ", p, q)
-			}
-			fmt.Println("]")
-			fmt.Printf("Файл успешно расшифрован. Результат в файле: %s\n", *outputFile)
-		} else {
-			fmt.Println("Атака завершилась неудачно. Приватный ключ не найден.")
-			fmt.Printf("Были рассмотрены следующие подходящие дроби: [")
-			for i := 0; i < len(approx); i++ {
-				p := approx[i][0]
-				q := approx[i][1]
-				fmt.Printf("%s/%s ", p, q)
-			}
-			fmt.Println("]")
-		}
-		os.Exit(0)
+	// проверяем подпись перед сохранением, чтобы не выдать подпись неподходящим ключом
+	if err := params.verify(pubKey, digest, sig); err != nil {
+		return fmt.Errorf("%w: подпись не проверяется публичным ключом", utils.ErrKeyMismatch)
 	}
 
-	// Проверяем что задан путь к файлу с ключом формирования подписи (приватный ключ)
-	if *fPrivateKey == "" {
-		fmt.Println("Не указан путь к файлу с приватным ключом. Укажите параметр --private-key <имя файла>")
-		os.Exit(1)
+	// подпись сохраняется отдельно от файла
+	return os.WriteFile(signatureFile, sig, 0600)
+}
+
+func VerifyFile(filename, signatureFile, publicKeyFile string, params *signatureParams) error {
+	// Получаем публичный ключ из файла в параметре --public-key
+	pubKey, err := readPubkey(publicKeyFile)
+	if err != nil {
+		return err
 	}
 
-	// режим зашифрования
-	if *cMode {
-		fmt.Println("Выбран режим зашифрования")
-		fmt.Printf("Путь к файлу: %s\n", *fPath)
-		fmt.Printf("Путь к файлу приватного ключа: %s\n", *fPrivateKey)
-		fmt.Printf("Путь к файлу публичного ключа: %s\n", *fPublicKey)
+	sig, err := os.ReadFile(signatureFile)
+	if err != nil {
+		return err
+	}
 
-		// запускаем процедуру зашифрования
-		// в ней же происходит сохранение файлов
-		err := ChipherFile(*fPath, *outputFile, *fPublicKey)
+	digest, err := hashFile(filename, params.opts.Hash)
+	if err != nil {
+		return err
+	}
+
+	return params.verify(pubKey, digest, sig)
+}
+
+// Чтение входного файла целиком, "-" означает стандартный ввод
+func readInput(filename string) ([]byte, error) {
+	in, err := openInput(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer in.Close()
+	return io.ReadAll(in)
+}
+
+// Запись выходного файла целиком, "-" означает стандартный вывод
+func writeOutput(filename string, data []byte) error {
+	out, err := createOutput(filename)
+	if err != nil {
+		return err
+	}
+	_, err = out.Write(data)
+	if cerr := out.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// Файл состояния клиента слепой подписи: вариант протокола, r^-1 и префикс сообщения в hex по строкам
+// Файл секретный: по r^-1 подписывающий может связать подпись с сеансом, в котором ее выдал
+func writeBlindState(stateFile string, u *utils.Unblinder, prefix []byte) error {
+	data, err := u.MarshalText()
+	if err != nil {
+		return err
+	}
+	data = append(data, fmt.Sprintf("\n%x", prefix)...)
+	return os.WriteFile(stateFile, data, 0600)
+}
+
+func readBlindState(stateFile string) (*utils.Unblinder, []byte, error) {
+	data, err := os.ReadFile(stateFile)
+	if err != nil {
+		return nil, nil, err
+	}
+	lines := strings.SplitN(string(data), "\n", 3)
+	if len(lines) != 3 {
+		return nil, nil, errors.New("неверный формат файла состояния: ожидается три строки")
+	}
+	u, err := utils.ParseUnblinder([]byte(lines[0] + "\n" + lines[1]))
+	if err != nil {
+		return nil, nil, err
+	}
+	prefix, err := hex.DecodeString(strings.TrimSpace(lines[2]))
+	if err != nil || len(prefix) != u.Variant.PrefixSize() {
+		return nil, nil, errors.New("неверный формат файла состояния: неверный префикс сообщения")
+	}
+	return u, prefix, nil
+}
+
+// Клиент: подготовка и ослепление сообщения, ослепленное сообщение отправляется подписывающему
+func BlindFile(filename, outputFile, stateFile, publicKeyFile string, variant *utils.BlindVariant) error {
+	pubKey, err := readPubkey(publicKeyFile)
+	if err != nil {
+		return err
+	}
+	msg, err := readInput(filename)
+	if err != nil {
+		return err
+	}
+
+	// Подробнее в utils/blindsig.go
+	prepared, err := variant.Prepare(nil, msg)
+	if err != nil {
+		return err
+	}
+	blinded, u, err := utils.Blind(nil, pubKey, prepared, variant)
+	if err != nil {
+		return err
+	}
+
+	// префикс и r^-1 нужны для снятия ослепления, сохраняем их до отправки сообщения
+	if err := writeBlindState(stateFile, u, prepared[:variant.PrefixSize()]); err != nil {
+		return err
+	}
+	return writeOutput(outputFile, blinded)
+}
+
+// Подписывающий: подпись ослепленного сообщения
+func BlindSignFile(filename, outputFile, publicKeyFile, privateKeyFile string, expBlinding bool) error {
+	pubKey, err := readPubkey(publicKeyFile)
+	if err != nil {
+		return err
+	}
+	privKey, err := readPrivkey(privateKeyFile)
+	if err != nil {
+		return err
+	}
+	privKey.ExponentBlinding = expBlinding
+
+	blinded, err := readInput(filename)
+	if err != nil {
+		return err
+	}
+	blindSig, err := privKey.SignBlinded(pubKey, blinded)
+	if err != nil {
+		return err
+	}
+	return writeOutput(outputFile, blindSig)
+}
+
+// Клиент: снятие ослепления с подписи
+// итоговая подпись сохраняется вместе с префиксом сообщения: префикс || подпись
+func FinalizeFile(filename, outputFile, stateFile, blindSigFile, publicKeyFile string) error {
+	pubKey, err := readPubkey(publicKeyFile)
+	if err != nil {
+		return err
+	}
+	u, prefix, err := readBlindState(stateFile)
+	if err != nil {
+		return err
+	}
+	msg, err := readInput(filename)
+	if err != nil {
+		return err
+	}
+	blindSig, err := os.ReadFile(blindSigFile)
+	if err != nil {
+		return err
+	}
+
+	prepared := append(append([]byte(nil), prefix...), msg...)
+	sig, err := u.Unblind(pubKey, prepared, blindSig)
+	if err != nil {
+		return err
+	}
+	return writeOutput(outputFile, append(append([]byte(nil), prefix...), sig...))
+}
+
+// Проверка итоговой слепой подписи
+func BlindVerifyFile(filename, signatureFile, publicKeyFile string, variant *utils.BlindVariant) error {
+	pubKey, err := readPubkey(publicKeyFile)
+	if err != nil {
+		return err
+	}
+	msg, err := readInput(filename)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(signatureFile)
+	if err != nil {
+		return err
+	}
+	if len(data) < variant.PrefixSize() {
+		return utils.ErrVerification
+	}
+
+	// подписано подготовленное сообщение: префикс || сообщение
+	prefix, sig := data[:variant.PrefixSize()], data[variant.PrefixSize():]
+	prepared := append(append([]byte(nil), prefix...), msg...)
+	return pubKey.VerifyBlind(prepared, sig, variant)
+}
+
+func BonehDurfee(ctx context.Context, filename, publicKeyFile, outputFile string, jobs int, params utils.BonehDurfeeParams) (*utils.BonehDurfeeResult, error) {
+	// Получаем публичный ключ из файла в параметре --public-key
+	pubKey, err := readPubkey(publicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	// запускаем процедуру атаки
+	// если завершится удачно, result.D != nil
+	// Подробнее в utils/bonehdurfee.go
+	result, err := utils.BonehDurfeeAttack(ctx, pubKey.N, pubKey.E, params)
+	if err != nil || result.D == nil {
+		return result, err
+	}
+
+	// вызываем процедуру расшифрования и записываем результат в файл, переданный в параметре -o
+	privateKey := utils.NewPrivateKey(result.D)
+	err = transformFile(filename, outputFile, true, func(dst io.Writer, src io.Reader) error {
+		return decryptStream(ctx, dst, src, privateKey, pubKey, jobs, true)
+	})
+	return result, err
+}
+
+func Stereotyped(ctx context.Context, filename, publicKeyFile, template, outputFile string, params utils.SmallRootsParams) (*utils.StereotypedResult, error) {
+	// Получаем публичный ключ из файла в параметре --public-key
+	pubKey, err := readPubkey(publicKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	// шифр в поблочном формате читается целиком: нужен один блок и длина открытого текста в конце
+	chipher, err := readInput(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	// запускаем процедуру атаки
+	// если завершится удачно, result.Unknown != nil
+	// Подробнее в utils/stereotyped.go
+	result, err := utils.StereotypedAttack(ctx, pubKey, chipher, []byte(template), params)
+	if err != nil || result.Unknown == nil {
+		return result, err
+	}
+
+	// записываем шаблон с восстановленными байтами в файл, переданный в параметре -o
+	return result, writeOutput(outputFile, result.Message)
+}
+
+func CommonModulus(filenames, publicKeyFiles []string, outputFile string) (*utils.CommonModulusResult, error) {
+	if len(filenames) != 2 || len(publicKeyFiles) != 2 {
+		return nil, errors.New("нужны ровно два публичных ключа --public-key и два файла шифра --f")
+	}
+	// Получаем публичные ключи из файлов в параметрах --public-key
+	pub1, err := readPubkey(publicKeyFiles[0])
+	if err != nil {
+		return nil, err
+	}
+	pub2, err := readPubkey(publicKeyFiles[1])
+	if err != nil {
+		return nil, err
+	}
+	// шифры в поблочном формате читаются целиком, i-й файл зашифрован i-м ключом
+	chipher1, err := readInput(filenames[0])
+	if err != nil {
+		return nil, err
+	}
+	chipher2, err := readInput(filenames[1])
+	if err != nil {
+		return nil, err
+	}
+
+	// запускаем процедуру атаки
+	// Подробнее в utils/commonmodulus.go
+	result, err := utils.CommonModulusAttack(pub1, pub2, chipher1, chipher2)
+	if err != nil {
+		return nil, err
+	}
+
+	// записываем открытый текст в файл, переданный в параметре -o
+	return result, writeOutput(outputFile, result.Message)
+}
+
+func Hastad(filenames, publicKeyFiles []string, outputFile string) error {
+	if len(filenames) != len(publicKeyFiles) {
+		return fmt.Errorf("указано %d публичных ключей и %d файлов шифра, нужно по одному шифру на каждый ключ", len(publicKeyFiles), len(filenames))
+	}
+	// Получаем публичные ключи из файлов в параметрах --public-key и шифры в поблочном формате целиком
+	pubKeys := make([]*utils.PublicKey, len(publicKeyFiles))
+	chiphers := make([][]byte, len(filenames))
+	for i := range publicKeyFiles {
+		pubKey, err := readPubkey(publicKeyFiles[i])
+		if err != nil {
+			return err
+		}
+		chipher, err := readInput(filenames[i])
+		if err != nil {
+			return err
+		}
+		pubKeys[i], chiphers[i] = pubKey, chipher
+	}
+
+	// запускаем процедуру атаки
+	// Подробнее в utils/hastad.go
+	message, err := utils.HastadAttack(pubKeys, chiphers)
+	if err != nil {
+		return err
+	}
+
+	// записываем открытый текст в файл, переданный в параметре -o
+	return writeOutput(outputFile, message)
+}
+
+// Восстановление приватного ключа по найденному множителю p и расшифрование файла
+// общая часть атак, раскладывающих n на множители
+func decryptWithFactor(ctx context.Context, pubKey *utils.PublicKey, p *big.Int, filename, outputFile string, jobs int) (*utils.PrivateKey, *big.Int, error) {
+	privateKey, q, err := utils.PrivateKeyFromFactor(pubKey, p)
+	if err != nil {
+		return nil, nil, err
+	}
+	return privateKey, q, decryptRecovered(ctx, pubKey, privateKey, filename, outputFile, jobs)
+}
+
+// Расшифрование файла ключом, восстановленным атакой
+func decryptRecovered(ctx context.Context, pubKey *utils.PublicKey, privateKey *utils.PrivateKey, filename, outputFile string, jobs int) error {
+	// вызываем процедуру расшифрования и записываем результат в файл, переданный в параметре -o
+	// атакуемые шифры обычно в поблочном формате, поэтому он разрешен
+	return transformFile(filename, outputFile, true, func(dst io.Writer, src io.Reader) error {
+		return decryptStream(ctx, dst, src, privateKey, pubKey, jobs, true)
+	})
+}
+
+func Fermat(ctx context.Context, filename, publicKeyFile, outputFile string, jobs int, maxIterations int64) (*utils.FermatResult, *utils.PrivateKey, error) {
+	// Получаем публичный ключ из файла в параметре --public-key
+	pubKey, err := readPubkey(publicKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// запускаем процедуру факторизации
+	// если завершится удачно, result.P != nil
+	// Подробнее в utils/fermat.go
+	result, err := utils.FermatFactor(ctx, pubKey.N, maxIterations)
+	if err != nil || result.P == nil {
+		return result, nil, err
+	}
+
+	privateKey, _, err := decryptWithFactor(ctx, pubKey, result.P, filename, outputFile, jobs)
+	return result, privateKey, err
+}
+
+// Вывод прогресса этапов факторизации с шагом 10%
+func stageProgress() func(stage int, done float64) {
+	lastStage, lastStep := 0, -1
+	return func(stage int, done float64) {
+		step := int(done * 10)
+		if stage == lastStage && step == lastStep {
+			return
+		}
+		lastStage, lastStep = stage, step
+		fmt.Fprintf(msgOut, "Этап %d: %d%%\n", stage, step*10)
+	}
+}
+
+func PollardPM1(ctx context.Context, filename, publicKeyFile, outputFile string, jobs int, params utils.PollardPM1Params) (*utils.PollardPM1Result, *utils.PrivateKey, error) {
+	// Получаем публичный ключ из файла в параметре --public-key
+	pubKey, err := readPubkey(publicKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// запускаем процедуру факторизации
+	// если завершится удачно, result.P != nil
+	// Подробнее в utils/pollardpm1.go
+	result, err := utils.PollardPM1(ctx, pubKey.N, params)
+	if err != nil || result.P == nil {
+		return result, nil, err
+	}
+
+	privateKey, _, err := decryptWithFactor(ctx, pubKey, result.P, filename, outputFile, jobs)
+	return result, privateKey, err
+}
+
+func PollardRho(ctx context.Context, filename, publicKeyFile, outputFile string, jobs int, timeout time.Duration) (*utils.PollardRhoResult, *utils.PrivateKey, error) {
+	// Получаем публичный ключ из файла в параметре --public-key
+	pubKey, err := readPubkey(publicKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// запускаем процедуру факторизации в jobs горутинах не дольше timeout
+	// если завершится удачно, result.P != nil
+	// Подробнее в utils/pollardrho.go
+	rhoCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	result, err := utils.PollardRho(rhoCtx, pubKey.N, utils.PollardRhoParams{Workers: jobs})
+	if err != nil || result.P == nil {
+		return result, nil, err
+	}
+
+	privateKey, _, err := decryptWithFactor(ctx, pubKey, result.P, filename, outputFile, jobs)
+	return result, privateKey, err
+}
+
+func WilliamsPP1(ctx context.Context, filename, publicKeyFile, outputFile string, jobs int, params utils.WilliamsPP1Params) (*utils.WilliamsPP1Result, *utils.PrivateKey, error) {
+	// Получаем публичный ключ из файла в параметре --public-key
+	pubKey, err := readPubkey(publicKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// запускаем процедуру факторизации
+	// если завершится удачно, result.P != nil
+	// Подробнее в utils/williamspp1.go
+	result, err := utils.WilliamsPP1(ctx, pubKey.N, params)
+	if err != nil || result.P == nil {
+		return result, nil, err
+	}
+
+	privateKey, _, err := decryptWithFactor(ctx, pubKey, result.P, filename, outputFile, jobs)
+	return result, privateKey, err
+}
+
+// Разложение n квадратичным решетом и сохранение восстановленного приватного ключа в файл
+// при пустом privateKeyFile ключ сохраняется в <timestamp>_private.rsakey
+func FactorKey(ctx context.Context, publicKeyFile, privateKeyFile string, params utils.SIQSParams) (*utils.SIQSResult, string, error) {
+	// Получаем публичный ключ из файла в параметре --public-key
+	pubKey, err := readPubkey(publicKeyFile)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// запускаем процедуру факторизации
+	// если завершится удачно, result.P != nil
+	// Подробнее в utils/siqs.go
+	result, err := utils.SIQS(ctx, pubKey.N, params)
+	if err != nil || result.P == nil {
+		return result, "", err
+	}
+
+	privateKey, _, err := utils.PrivateKeyFromFactor(pubKey, result.P)
+	if err != nil {
+		return result, "", err
+	}
+	if privateKeyFile == "" {
+		privateKeyFile = fmt.Sprintf("%s_private.rsakey", time.Now().Format("20060102T150405"))
+	}
+	// Переводим D в строковое представление и записываем в файл, "-" - стандартный вывод
+	return result, privateKeyFile, writeOutput(privateKeyFile, []byte(privateKey.D.String()))
+}
+
+// Расширения файлов ключей, которые берутся из каталогов в режиме пакетного НОД
+var batchKeyExtensions = []string{".rsakey", ".pem", ".pub", ".crt", ".cer"}
+
+// Публичный ключ из набора для пакетного НОД
+type batchKey struct {
+	// путь к файлу, для файлов с несколькими ключами - с номером ключа после #
+	Name string
+	// имя файла приватного ключа без расширения
+	Base   string
+	PubKey *utils.PublicKey
+}
+
+// Результат пакетного НОД по набору файлов ключей
+type batchGCDReport struct {
+	Keys   []batchKey
+	Result *utils.BatchGCDResult
+	// файлы, которые не удалось прочитать как ключи
+	Skipped []error
+	// Written[i] - файл восстановленного приватного ключа Keys[i]
+	Written map[int]string
+	// ключи с общим множителем, приватный ключ которых восстановить не удалось
+	Failed map[int]error
+}
+
+// Файлы ключей из списка путей: файлы берутся как есть, каталоги обходятся рекурсивно
+func collectKeyFiles(paths []string) ([]string, error) {
+	var files []string
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, path)
+			continue
+		}
+		err = filepath.WalkDir(path, func(name string, entry fs.DirEntry, err error) error {
+			if err != nil || entry.IsDir() {
+				return err
+			}
+			ext := strings.ToLower(filepath.Ext(name))
+			for _, keyExt := range batchKeyExtensions {
+				if ext == keyExt {
+					files = append(files, name)
+					break
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Пакетный НОД по публичным ключам из файлов и каталогов paths
+// приватные ключи уязвимых ключей сохраняются в каталог outputDir
+func BatchGCDKeys(ctx context.Context, paths []string, outputDir string, jobs int) (*batchGCDReport, error) {
+	files, err := collectKeyFiles(paths)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &batchGCDReport{Written: make(map[int]string), Failed: make(map[int]error)}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			report.Skipped = append(report.Skipped, err)
+			continue
+		}
+		// Разбираем ключи в любом из поддерживаемых форматов
+		// Подробнее в utils/keyfiles.go
+		pubKeys, err := utils.ParsePublicKeys(data)
+		if err != nil {
+			report.Skipped = append(report.Skipped, fmt.Errorf("%s: %w", file, err))
+			continue
+		}
+		base := strings.TrimSuffix(filepath.Base(file), filepath.Ext(file))
+		for k, pubKey := range pubKeys {
+			key := batchKey{Name: file, Base: base, PubKey: pubKey}
+			if len(pubKeys) > 1 {
+				key.Name = fmt.Sprintf("%s#%d", file, k+1)
+				key.Base = fmt.Sprintf("%s_%d", base, k+1)
+			}
+			report.Keys = append(report.Keys, key)
+		}
+	}
+
+	moduli := make([]*big.Int, len(report.Keys))
+	for i, key := range report.Keys {
+		moduli[i] = key.PubKey.N
+	}
+	// запускаем пакетный НОД
+	// Подробнее в utils/batchgcd.go
+	report.Result, err = utils.BatchGCD(ctx, moduli, jobs)
+	if err != nil {
+		return report, err
+	}
+
+	if outputDir == "" {
+		outputDir = "."
+	}
+	if len(report.Result.Pairs) > 0 {
+		if err := os.MkdirAll(outputDir, 0700); err != nil {
+			return report, err
+		}
+	}
+	used := make(map[string]bool)
+	for i, p := range report.Result.Factors {
+		if p == nil {
+			continue
+		}
+		privateKey, _, err := utils.PrivateKeyFromFactor(report.Keys[i].PubKey, p)
+		if err != nil {
+			report.Failed[i] = err
+			continue
+		}
+		// Переводим D в строковое представление и записываем в файл.
+		// Одинаковые имена файлов из разных каталогов и уже существующие файлы
+		// различаются номером ключа и отпечатком модуля, существующие файлы не перезаписываются
+		data := []byte(privateKey.D.String())
+		name := report.Keys[i].Base + "_private.rsakey"
+		privateKeyFile := filepath.Join(outputDir, name)
+		if used[name] {
+			err = fs.ErrExist
+		} else {
+			err = writeNewFile(privateKeyFile, data)
+		}
+		if errors.Is(err, fs.ErrExist) {
+			fingerprint := hex.EncodeToString(report.Keys[i].PubKey.Fingerprint()[:4])
+			name = fmt.Sprintf("%s_%d_%s_private.rsakey", report.Keys[i].Base, i+1, fingerprint)
+			privateKeyFile = filepath.Join(outputDir, name)
+			err = writeNewFile(privateKeyFile, data)
+		}
+		if err != nil {
+			report.Failed[i] = err
+			continue
+		}
+		used[name] = true
+		report.Written[i] = privateKeyFile
+	}
+	return report, nil
+}
+
+// Запись в новый файл: существующий файл не перезаписывается, возвращается ошибка fs.ErrExist
+func writeNewFile(filename string, data []byte) error {
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(data); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func ECM(ctx context.Context, filename, publicKeyFile, outputFile string, params utils.ECMParams) (*utils.ECMFactorization, *utils.PrivateKey, error) {
+	// Получаем публичный ключ из файла в параметре --public-key
+	pubKey, err := readPubkey(publicKeyFile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// запускаем процедуру полного разложения, n может состоять из нескольких простых
+	// если завершится удачно, factorization.Rest == nil
+	// Подробнее в utils/ecm.go
+	factorization, err := utils.ECMFactorize(ctx, pubKey.N, params)
+	if err != nil || factorization.Rest != nil {
+		return factorization, nil, err
+	}
+
+	privateKey, err := utils.PrivateKeyFromPrimes(pubKey, factorization.Primes)
+	if err != nil {
+		return factorization, nil, err
+	}
+	return factorization, privateKey, decryptRecovered(ctx, pubKey, privateKey, filename, outputFile, params.Workers)
+}
+
+// Программа завершается через os.Exit только здесь, после выхода из run,
+// чтобы отложенные вызовы в run (восстановление обработки сигналов и т.п.) успели выполниться
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	// установка перчня флагов (аргументов) принимаемых программой с их описанием
+	var inputFiles stringList
+	flag.Var(&inputFiles, "f", "Путь к файлу для защифрования или расшифрования, \"-\" - стандартный ввод. В атаках на общий модуль и Хастада указывается несколько раз, по одному на каждый публичный ключ")
+	var publicKeys stringList
+	flag.Var(&publicKeys, "public-key", "Путь к файлу с публичным ключем пользователя. При зашифровании можно указать несколько раз, по одному на каждого получателя, в атаках на общий модуль и Хастада - по одному на каждый шифр, в пакетном НОД - файлы и каталоги с ключами")
+	fPrivateKey := flag.String("private-key", "", "Путь к файлу с приватным ключем пользователя")
+	outputFile := flag.String("o", "", "Путь к файлу куда сохранить результаты зашифрования или расшифрования, \"-\" - стандартный вывод")
+	genMode := flag.Bool("gen", false, "Запуск в режиме генерации ключей пользователя.  Ключи сохраняются в текущий дериктории <timestamp>_public.rsakey и <timestamp>_private.rsakey")
+	bits := flag.Int("bits", 0, "Длина модуля n в битах для учебных ключей в режиме генерации (не меньше 64), 0 - обычный ключ")
+	factorMode := flag.Bool("factor", false, "Запуск в режиме разложения n квадратичным решетом (SIQS) и восстановления приватного ключа. Ключ сохраняется в -o или <timestamp>_private.rsakey")
+	cMode := flag.Bool("enc", false, "Запуск в режиме зашифрования")
+	dMode := flag.Bool("dec", false, "Запуск в режиме расшифрования")
+	wMode := flag.Bool("wiener", false, "Запуск в режиме попытки проведения атаки Винера")
+	bdMode := flag.Bool("boneh-durfee", false, "Запуск в режиме попытки проведения атаки Бонеха-Дерфи")
+	bdM := flag.Int("bd-m", utils.DefaultBonehDurfeeParams.M, "Атака Бонеха-Дерфи: наибольшая степень сдвигов m, с ростом m атака сильнее, но медленнее")
+	bdT := flag.Int("bd-t", utils.DefaultBonehDurfeeParams.T, "Атака Бонеха-Дерфи: количество сдвигов по y t, -1 - выбрать автоматически")
+	bdDelta := flag.Float64("bd-delta", utils.DefaultBonehDurfeeParams.Delta, "Атака Бонеха-Дерфи: предполагаемая граница d < n^delta")
+	csMode := flag.Bool("stereotyped", false, "Запуск в режиме атаки Копперсмита на стереотипное сообщение при малом e")
+	template := flag.String("template", "", "Атака на стереотипное сообщение: известный открытый текст, неизвестные байты обозначаются символами ?")
+	csM := flag.Int("cs-m", 0, "Атака на стереотипное сообщение: степень n в решетке m, 0 - выбрать автоматически")
+	csT := flag.Int("cs-t", 0, "Атака на стереотипное сообщение: количество дополнительных сдвигов t, учитывается при заданном --cs-m")
+	fermatMode := flag.Bool("fermat", false, "Запуск в режиме атаки Ферма на ключ с близкими p и q")
+	fermatIterations := flag.Int64("fermat-iterations", utils.DefaultFermatIterations, "Атака Ферма: наибольшее количество итераций")
+	pm1Mode := flag.Bool("pollard-pm1", false, "Запуск в режиме атаки методом Полларда p - 1 на ключ с гладким p - 1")
+	pm1B1 := flag.Uint64("pm1-b1", utils.DefaultPollardPM1Params.B1, "Метод p - 1: граница гладкости этапа 1")
+	pm1B2 := flag.Uint64("pm1-b2", utils.DefaultPollardPM1Params.B2, "Метод p - 1: граница этапа 2, 0 - без этапа 2")
+	rhoMode := flag.Bool("pollard-rho", false, "Запуск в режиме атаки ро-методом Полларда-Брента на ключ с малым n или малым делителем")
+	rhoTimeout := flag.Duration("rho-timeout", time.Minute, "Ро-метод: наибольшее время работы, например 30s или 10m")
+	pp1Mode := flag.Bool("williams-pp1", false, "Запуск в режиме атаки методом Уильямса p + 1 на ключ с гладким p + 1")
+	pp1B1 := flag.Uint64("pp1-b1", utils.DefaultWilliamsPP1Params.B1, "Метод p + 1: граница гладкости этапа 1")
+	pp1B2 := flag.Uint64("pp1-b2", utils.DefaultWilliamsPP1Params.B2, "Метод p + 1: граница этапа 2, 0 - без этапа 2")
+	pp1Seeds := flag.Int("pp1-seeds", utils.DefaultWilliamsPP1Params.Seeds, "Метод p + 1: количество начальных значений A, от 1 до 10")
+	ecmMode := flag.Bool("ecm", false, "Запуск в режиме атаки методом эллиптических кривых на ключ с делителем средней величины или из нескольких простых")
+	ecmB1 := flag.Uint64("ecm-b1", utils.DefaultECMParams.B1, "Метод эллиптических кривых: граница гладкости этапа 1")
+	ecmB2 := flag.Uint64("ecm-b2", utils.DefaultECMParams.B2, "Метод эллиптических кривых: граница этапа 2, 0 - без этапа 2")
+	ecmCurves := flag.Int("ecm-curves", utils.DefaultECMParams.Curves, "Метод эллиптических кривых: наибольшее число кривых на один делитель, 0 - до прерывания")
+	cmMode := flag.Bool("common-modulus", false, "Запуск в режиме атаки на общий модуль: два ключа с одним n и два шифра одного сообщения")
+	hastadMode := flag.Bool("hastad", false, "Запуск в режиме широковещательной атаки Хастада: e ключей с малым e и e шифров одного сообщения")
+	batchMode := flag.Bool("batch-gcd", false, "Запуск в режиме пакетного НОД: поиск общих простых у набора публичных ключей (.rsakey, PEM, OpenSSH). Ключи и каталоги задаются параметрами --public-key, приватные ключи сохраняются в каталог -o")
+	signMode := flag.Bool("sign", false, "Запуск в режиме формирования подписи")
+	verifyMode := flag.Bool("verify", false, "Запуск в режиме проверки подписи")
+	scheme := flag.String("scheme", schemePSS, "Схема подписи: pss (RSASSA-PSS) или pkcs1v15 (RSASSA-PKCS1-v1_5)")
+	fSignature := flag.String("s", "", "Путь к файлу отсоединенной подписи")
+	hashName := flag.String("hash", "sha256", "Хэш-функция подписи: sha256, sha384 или sha512")
+	blindMode := flag.Bool("blind", false, "Слепая подпись, шаг клиента: ослепление сообщения для подписывающего")
+	blindSignMode := flag.Bool("blind-sign", false, "Слепая подпись, шаг подписывающего: подпись ослепленного сообщения")
+	finalizeMode := flag.Bool("finalize", false, "Слепая подпись, шаг клиента: снятие ослепления с подписи")
+	blindVerifyMode := flag.Bool("blind-verify", false, "Слепая подпись: проверка итоговой подписи")
+	fState := flag.String("state", "", "Путь к секретному файлу состояния клиента слепой подписи")
+	blindVariantName := flag.String("blind-variant", utils.BlindSHA384PSSRandomized.Name, "Вариант слепой подписи из RFC 9474")
+	saltLen := flag.Int("salt-len", utils.PSSSaltLengthEqualsHash, "Длина соли подписи PSS в байтах: -1 - равна длине хэша, 0 - максимальная при подписи и любая при проверке")
+	unauthenticated := flag.Bool("unauthenticated", false, "Поблочный формат без защиты от подделки: при зашифровании - записать шифр в нем (один получатель), при расшифровании - разрешить такие файлы")
+	expBlinding := flag.Bool("exponent-blinding", false, "Дополнительно ослеплять приватную степень при расшифровании")
+	jobs := flag.Int("jobs", utils.DefaultJobs(), "Количество горутин: для слотов получателей конверта, блоков поблочного формата и для атак")
+
+	// Парсим флаги
+	flag.Parse()
+
+	// основной входной файл - первый из -f, остальные нужны только атакам по нескольким шифрам
+	fPath := ""
+	if len(inputFiles) > 0 {
+		fPath = inputFiles[0]
+	}
+
+	// прерывание по Ctrl+C отменяет контекст и останавливает обработку блоков
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	// если результат пишется в стандартный вывод, сообщения программы уходят в стандартный поток ошибок
+	if *outputFile == "-" {
+		msgOut = os.Stderr
+	}
+
+	// Проверяем что количество горутин задано корректно
+	if *jobs < 1 {
+		fmt.Fprintln(msgOut, "Количество горутин должно быть положительным. Укажите параметр --jobs <число>")
+		return exitError
+	}
+
+	// проверяем что одновременно не задано несколько режимов работы
+	modes := 0
+	for _, mode := range []bool{*genMode, *factorMode, *cMode, *dMode, *wMode, *bdMode, *csMode, *fermatMode, *pm1Mode, *rhoMode, *pp1Mode, *ecmMode, *cmMode, *hastadMode, *batchMode, *signMode, *verifyMode,
+		*blindMode, *blindSignMode, *finalizeMode, *blindVerifyMode} {
+		if mode {
+			modes++
+		}
+	}
+	if modes > 1 {
+		fmt.Fprintln(msgOut, "Одновременно указаны несколько режимов работы. Это не допустимо, укажите один")
+		return exitError
+	}
+
+	// режим генерации ключевой пары
+	if *genMode {
+		fmt.Fprintln(msgOut, "Выбран режим генерации ключевой пары!")
+		// запускаем процедуру генерации
+		// в ней же происходит сохранение
+		pubKey, privKey, err := genKeyPair(*bits)
+		if err != nil {
+			return errorExitCode("Во время генерации ключей произошла ошибка", err)
+		}
+		fmt.Fprintln(msgOut, "Ключевая пара создана и сохранена успешно!")
+		fmt.Fprintf(msgOut, "Публичный ключ: %s\n", pubKey)
+		fmt.Fprintf(msgOut, "Приватный ключ: %s\n", privKey)
+		return exitOK
+	}
+
+	// режим разложения n, файл для расшифрования ему не нужен
+	if *factorMode {
+		if len(publicKeys) != 1 {
+			fmt.Fprintln(msgOut, "Укажите один файл публичного ключа параметром --public-key <имя файла>")
+			return exitError
+		}
+		// прогресс сбора соотношений с шагом 10%
+		lastStep := -1
+		params := utils.SIQSParams{Workers: *jobs, Progress: func(relations, needed int) {
+			if step := relations * 10 / needed; step != lastStep {
+				lastStep = step
+				fmt.Fprintf(msgOut, "Соотношений: %d из %d\n", relations, needed)
+			}
+		}}
+		fmt.Fprintln(msgOut, "Выбран режим разложения n квадратичным решетом!")
+		fmt.Fprintf(msgOut, "Путь к файлу публичного ключа: %s\n", publicKeys[0])
+		fmt.Fprintf(msgOut, "Горутин: %d\n", *jobs)
+
+		start := time.Now()
+		result, privateKeyFile, err := FactorKey(ctx, publicKeys[0], *outputFile, params)
+		if err != nil {
+			return errorExitCode("Во время разложения n произошла ошибка", err)
+		}
+
+		fmt.Fprintf(msgOut, "Множитель k = %d, база множителей: %d, интервал: 2 * %d\n", result.Multiplier, result.FactorBase, result.M)
+		fmt.Fprintf(msgOut, "Соотношений: %d полных, %d из пар частичных, многочленов: %d\n", result.Full, result.Combined, result.Polynomials)
+		fmt.Fprintf(msgOut, "Время работы: %s\n", time.Since(start).Round(time.Millisecond))
+		if result.P == nil {
+			fmt.Fprintln(msgOut, "Разложение не удалось: все зависимости дали тривиальный делитель. Запустите еще раз.")
+			return exitError
+		}
+		fmt.Fprintf(msgOut, "p = %s\n", result.P)
+		fmt.Fprintf(msgOut, "q = %s\n", result.Q)
+		fmt.Fprintf(msgOut, "Приватный ключ сохранен в файл: %s\n", privateKeyFile)
+		return exitOK
+	}
+
+	// режим пакетного НОД, файл для расшифрования ему не нужен
+	if *batchMode {
+		if len(publicKeys) == 0 {
+			fmt.Fprintln(msgOut, "Укажите файлы или каталоги публичных ключей параметрами --public-key <путь>")
+			return exitError
+		}
+		fmt.Fprintln(msgOut, "Выбран режим пакетного НОД!")
+		fmt.Fprintf(msgOut, "Пути к публичным ключам: %s\n", publicKeys.String())
+		fmt.Fprintf(msgOut, "Горутин: %d\n", *jobs)
+
+		start := time.Now()
+		report, err := BatchGCDKeys(ctx, publicKeys, *outputFile, *jobs)
+		if report != nil {
+			for _, skipped := range report.Skipped {
+				fmt.Fprintf(msgOut, "Пропущен файл %s\n", skipped)
+			}
+		}
+		if err != nil {
+			return errorExitCode("Во время пакетного НОД произошла ошибка", err)
+		}
+
+		result := report.Result
+		fmt.Fprintf(msgOut, "Ключей: %d, время работы: %s\n", len(report.Keys), time.Since(start).Round(time.Millisecond))
+		for _, pair := range result.Pairs {
+			fmt.Fprintf(msgOut, "Общий множитель у %s и %s: p = %s\n", report.Keys[pair.I].Name, report.Keys[pair.J].Name, pair.P)
+		}
+		for _, pair := range result.Duplicates {
+			fmt.Fprintf(msgOut, "Одинаковый модуль у %s и %s\n", report.Keys[pair[0]].Name, report.Keys[pair[1]].Name)
+		}
+		for i := range report.Keys {
+			if file, ok := report.Written[i]; ok {
+				fmt.Fprintf(msgOut, "Приватный ключ %s сохранен в файл: %s\n", report.Keys[i].Name, file)
+			} else if err, ok := report.Failed[i]; ok {
+				var pathErr *fs.PathError
+				if errors.As(err, &pathErr) {
+					fmt.Fprintf(msgOut, "Приватный ключ %s восстановлен, но не сохранен: %s\n", report.Keys[i].Name, err)
+				} else {
+					fmt.Fprintf(msgOut, "Приватный ключ %s не восстановлен: %s\n", report.Keys[i].Name, err)
+				}
+			}
+		}
+		if len(result.Pairs) == 0 && len(result.Duplicates) == 0 {
+			fmt.Fprintln(msgOut, "Общих множителей не найдено.")
+		}
+		return exitOK
+	}
+
+	// Проверяем что задан путь к файлу
+	if fPath == "" {
+		fmt.Fprintln(msgOut, "Не указан путь к файлу. Укажите параметр --f <имя файла>")
+		return exitError
+	}
+
+	// Проверяем что задан путь к файлу с публичным ключом
+	if len(publicKeys) == 0 {
+		fmt.Fprintln(msgOut, "Не указан путь к файлу с публичным ключом. Укажите параметр --public-key <имя файла>")
+		return exitError
+	}
+
+	// Несколько получателей бывает только у зашифрования, остальным режимам нужен один ключ
+	if len(publicKeys) > 1 && !*cMode && !*cmMode && !*hastadMode {
+		fmt.Fprintln(msgOut, "Несколько публичных ключей можно указать только в режиме зашифрования и в атаках на общий модуль и Хастада")
+		return exitError
+	}
+	if len(inputFiles) > 1 && !*cmMode && !*hastadMode {
+		fmt.Fprintln(msgOut, "Несколько файлов можно указать только в атаках на общий модуль и Хастада")
+		return exitError
+	}
+	fPublicKey := publicKeys[0]
+
+	// параметры подписи
+	var sigParams *signatureParams
+	if *signMode || *verifyMode {
+		if *scheme != schemePSS && *scheme != schemePKCS1v15 {
+			fmt.Fprintln(msgOut, "Неизвестная схема подписи. Укажите параметр --scheme pss или --scheme pkcs1v15")
+			return exitError
+		}
+		hash, err := parseHash(*hashName)
+		if err != nil {
+			return errorExitCode("Неверный параметр --hash", err)
+		}
+		if *saltLen < utils.PSSSaltLengthEqualsHash {
+			fmt.Fprintln(msgOut, "Длина соли не может быть отрицательной. Укажите параметр --salt-len <число>")
+			return exitError
+		}
+		sigParams = &signatureParams{
+			scheme: *scheme,
+			opts:   &utils.PSSOptions{Hash: hash, SaltLength: *saltLen},
+		}
+
+		// Проверяем что задан путь к файлу подписи
+		if *fSignature == "" {
+			fmt.Fprintln(msgOut, "Не указан путь к файлу подписи. Укажите параметр --s <имя файла>")
+			return exitError
+		}
+	}
+
+	// режим проверки подписи
+	if *verifyMode {
+		fmt.Fprintln(msgOut, "Выбран режим проверки подписи файла.")
+		fmt.Fprintf(msgOut, "Путь к файлу: %s\n", fPath)
+		fmt.Fprintf(msgOut, "Путь к файлу подписи: %s\n", *fSignature)
+		fmt.Fprintf(msgOut, "Путь к файлу публичного ключа: %s\n", fPublicKey)
+
+		err := VerifyFile(fPath, *fSignature, fPublicKey, sigParams)
+		if err != nil {
+			return errorExitCode("Проверка подписи завершена", err)
+		}
+		fmt.Fprintln(msgOut, "Проверка подписи завершена.")
+		fmt.Fprintln(msgOut, "Подпись верна.")
+		return exitOK
+	}
+
+	// вариант слепой подписи нужен для ослепления и проверки, при снятии ослепления он берется из файла состояния
+	var blindVariant *utils.BlindVariant
+	if *blindMode || *blindVerifyMode {
+		variant, err := utils.BlindVariantByName(*blindVariantName)
+		if err != nil {
+			return errorExitCode("Неверный параметр --blind-variant", err)
+		}
+		blindVariant = variant
+	}
+	if (*finalizeMode || *blindVerifyMode) && *fSignature == "" {
+		fmt.Fprintln(msgOut, "Не указан путь к файлу подписи. Укажите параметр --s <имя файла>")
+		return exitError
+	}
+	if (*blindMode || *finalizeMode) && *fState == "" {
+		fmt.Fprintln(msgOut, "Не указан путь к файлу состояния. Укажите параметр --state <имя файла>")
+		return exitError
+	}
+
+	// проверка итоговой слепой подписи
+	if *blindVerifyMode {
+		fmt.Fprintln(msgOut, "Выбран режим проверки слепой подписи файла.")
+		fmt.Fprintf(msgOut, "Путь к файлу: %s\n", fPath)
+		fmt.Fprintf(msgOut, "Путь к файлу подписи: %s\n", *fSignature)
+		fmt.Fprintf(msgOut, "Путь к файлу публичного ключа: %s\n", fPublicKey)
+
+		err := BlindVerifyFile(fPath, *fSignature, fPublicKey, blindVariant)
+		if err != nil {
+			return errorExitCode("Проверка подписи завершена", err)
+		}
+		fmt.Fprintln(msgOut, "Проверка подписи завершена.")
+		fmt.Fprintln(msgOut, "Подпись верна.")
+		return exitOK
+	}
+
+	// Проверяем что задан путь к файлу для сохранения результатов
+	// подпись сохраняется в файл из параметра --s
+	if *outputFile == "" && !*signMode {
+		fmt.Fprintln(msgOut, "Не указан путь к файлу для сохранения результатов. Укажите параметр --o <имя файла>")
+		return exitError
+	}
+
+	if *wMode {
+		fmt.Fprintln(msgOut, "Выбран режим попытки проведения атаки Винера!")
+		fmt.Fprintf(msgOut, "Путь к файлу: %s\n", fPath)
+		fmt.Fprintf(msgOut, "Путь к файлу публичного ключа: %s\n", fPublicKey)
+
+		ok, d, approx, err := Wiener(ctx, fPath, fPublicKey, *outputFile, *jobs)
+		if err != nil {
+			return errorExitCode("Во время попытки атаки Винера произошла ошибка", err)
+		}
+
+		if ok {
+			fmt.Fprintf(msgOut, "Атака завершилась успешно. Публичный ключ d = %s\n", d)
+			fmt.Fprintf(msgOut, "Были рассмотрены следующие подходящие дроби: [")
+			for i := 0; i < len(approx); i++ {
+				p := approx[i][0]
+				q := approx[i][1]
+				fmt.Fprintf(msgOut, "%s/%s ", p, q)
+			}
+			fmt.Fprintln(msgOut, "]")
+			fmt.Fprintf(msgOut, "Файл успешно расшифрован. Результат в файле: %s\n", *outputFile)
+		} else {
+			fmt.Fprintln(msgOut, "Атака завершилась неудачно. Приватный ключ не найден.")
+			fmt.Fprintf(msgOut, "Были рассмотрены следующие подходящие дроби: [")
+			for i := 0; i < len(approx); i++ {
+				p := approx[i][0]
+				q := approx[i][1]
+				fmt.Fprintf(msgOut, "%s/%s ", p, q)
+			}
+			fmt.Fprintln(msgOut, "]")
+		}
+		return exitOK
+	}
+
+	if *bdMode {
+		params := utils.BonehDurfeeParams{M: *bdM, T: *bdT, Delta: *bdDelta}
+		fmt.Fprintln(msgOut, "Выбран режим попытки проведения атаки Бонеха-Дерфи!")
+		fmt.Fprintf(msgOut, "Путь к файлу: %s\n", fPath)
+		fmt.Fprintf(msgOut, "Путь к файлу публичного ключа: %s\n", fPublicKey)
+
+		start := time.Now()
+		result, err := BonehDurfee(ctx, fPath, fPublicKey, *outputFile, *jobs, params)
+		if err != nil {
+			return errorExitCode("Во время попытки атаки Бонеха-Дерфи произошла ошибка", err)
+		}
+
+		fmt.Fprintf(msgOut, "Параметры решетки: m = %d, t = %d, delta = %g, размерность %d\n", params.M, result.T, params.Delta, result.Dimension)
+		fmt.Fprintf(msgOut, "Время работы: %s\n", time.Since(start).Round(time.Millisecond))
+		if result.D != nil {
+			fmt.Fprintf(msgOut, "Атака завершилась успешно. Приватный ключ d = %s\n", result.D)
+			fmt.Fprintf(msgOut, "Множители n: p = %s, q = %s\n", result.P, result.Q)
+			fmt.Fprintf(msgOut, "Файл успешно расшифрован. Результат в файле: %s\n", *outputFile)
+		} else {
+			fmt.Fprintln(msgOut, "Атака завершилась неудачно. Приватный ключ не найден.")
+			fmt.Fprintln(msgOut, "Попробуйте увеличить --bd-m или --bd-delta, если d может быть больше n^delta.")
+		}
+		return exitOK
+	}
+
+	if *csMode {
+		if *template == "" {
+			fmt.Fprintln(msgOut, "Не указан шаблон сообщения. Укажите параметр --template <текст с ? вместо неизвестных байт>")
+			return exitError
+		}
+		params := utils.SmallRootsParams{M: *csM, T: *csT}
+		fmt.Fprintln(msgOut, "Выбран режим атаки на стереотипное сообщение!")
+		fmt.Fprintf(msgOut, "Путь к файлу: %s\n", fPath)
+		fmt.Fprintf(msgOut, "Путь к файлу публичного ключа: %s\n", fPublicKey)
+
+		start := time.Now()
+		result, err := Stereotyped(ctx, fPath, fPublicKey, *template, *outputFile, params)
+		if err != nil {
+			return errorExitCode("Во время атаки на стереотипное сообщение произошла ошибка", err)
+		}
+
+		fmt.Fprintf(msgOut, "Неизвестное поле: блок %d, смещение %d байт\n", result.Block, result.Offset)
+		fmt.Fprintf(msgOut, "Параметры решетки: m = %d, t = %d, размерность %d\n", result.M, result.T, result.Dimension)
+		fmt.Fprintf(msgOut, "Время работы: %s\n", time.Since(start).Round(time.Millisecond))
+		if result.Unknown != nil {
+			fmt.Fprintf(msgOut, "Атака завершилась успешно. Неизвестные байты: %q (hex %x)\n", result.Unknown, result.Unknown)
+			fmt.Fprintf(msgOut, "Сообщение восстановлено. Результат в файле: %s\n", *outputFile)
+		} else {
+			fmt.Fprintln(msgOut, "Атака завершилась неудачно. Неизвестные байты не найдены.")
+			fmt.Fprintln(msgOut, "Проверьте шаблон или увеличьте --cs-m.")
+		}
+		return exitOK
+	}
+
+	if *fermatMode {
+		fmt.Fprintln(msgOut, "Выбран режим попытки проведения атаки Ферма!")
+		fmt.Fprintf(msgOut, "Путь к файлу: %s\n", fPath)
+		fmt.Fprintf(msgOut, "Путь к файлу публичного ключа: %s\n", fPublicKey)
+
+		start := time.Now()
+		result, privateKey, err := Fermat(ctx, fPath, fPublicKey, *outputFile, *jobs, *fermatIterations)
+		if err != nil {
+			return errorExitCode("Во время попытки атаки Ферма произошла ошибка", err)
+		}
+
+		fmt.Fprintf(msgOut, "Выполнено итераций: %d\n", result.Iterations)
+		fmt.Fprintf(msgOut, "Время работы: %s\n", time.Since(start).Round(time.Millisecond))
+		if result.P != nil {
+			fmt.Fprintf(msgOut, "Атака завершилась успешно. Множители n: p = %s, q = %s\n", result.P, result.Q)
+			fmt.Fprintf(msgOut, "|p - q| занимает %d бит\n", new(big.Int).Sub(result.Q, result.P).BitLen())
+			fmt.Fprintf(msgOut, "Приватный ключ d = %s\n", privateKey.D)
+			fmt.Fprintf(msgOut, "Файл успешно расшифрован. Результат в файле: %s\n", *outputFile)
+		} else {
+			fmt.Fprintln(msgOut, "Атака завершилась неудачно. Множители n не найдены.")
+			fmt.Fprintln(msgOut, "Вероятно, p и q далеки друг от друга. Можно увеличить --fermat-iterations.")
+		}
+		return exitOK
+	}
+
+	if *pm1Mode {
+		params := utils.PollardPM1Params{B1: *pm1B1, B2: *pm1B2, Progress: stageProgress()}
+		fmt.Fprintln(msgOut, "Выбран режим попытки проведения атаки методом Полларда p - 1!")
+		fmt.Fprintf(msgOut, "Путь к файлу: %s\n", fPath)
+		fmt.Fprintf(msgOut, "Путь к файлу публичного ключа: %s\n", fPublicKey)
+		fmt.Fprintf(msgOut, "Границы: B1 = %d, B2 = %d\n", params.B1, params.B2)
+
+		start := time.Now()
+		result, privateKey, err := PollardPM1(ctx, fPath, fPublicKey, *outputFile, *jobs, params)
+		if err != nil {
+			return errorExitCode("Во время попытки атаки методом p - 1 произошла ошибка", err)
+		}
+
+		fmt.Fprintf(msgOut, "Время работы: %s\n", time.Since(start).Round(time.Millisecond))
+		if result.P != nil {
+			fmt.Fprintf(msgOut, "Атака завершилась успешно на этапе %d. Множители n: p = %s, q = %s\n", result.Stage, result.P, result.Q)
+			fmt.Fprintf(msgOut, "Приватный ключ d = %s\n", privateKey.D)
+			fmt.Fprintf(msgOut, "Файл успешно расшифрован. Результат в файле: %s\n", *outputFile)
+		} else {
+			fmt.Fprintln(msgOut, "Атака завершилась неудачно. Множители n не найдены.")
+			fmt.Fprintln(msgOut, "У p - 1 и q - 1 есть простые делители больше границ. Можно увеличить --pm1-b1 и --pm1-b2.")
+		}
+		return exitOK
+	}
+
+	if *rhoMode {
+		fmt.Fprintln(msgOut, "Выбран режим попытки проведения атаки ро-методом Полларда!")
+		fmt.Fprintf(msgOut, "Путь к файлу: %s\n", fPath)
+		fmt.Fprintf(msgOut, "Путь к файлу публичного ключа: %s\n", fPublicKey)
+		fmt.Fprintf(msgOut, "Горутин: %d, ограничение по времени: %s\n", *jobs, *rhoTimeout)
+
+		start := time.Now()
+		result, privateKey, err := PollardRho(ctx, fPath, fPublicKey, *outputFile, *jobs, *rhoTimeout)
+		if err != nil {
+			return errorExitCode("Во время попытки атаки ро-методом произошла ошибка", err)
+		}
+
+		fmt.Fprintf(msgOut, "Выполнено итераций: %d\n", result.Iterations)
+		fmt.Fprintf(msgOut, "Время работы: %s\n", time.Since(start).Round(time.Millisecond))
+		if result.P != nil {
+			fmt.Fprintf(msgOut, "Атака завершилась успешно. Множители n: p = %s, q = %s\n", result.P, result.Q)
+			fmt.Fprintf(msgOut, "Приватный ключ d = %s\n", privateKey.D)
+			fmt.Fprintf(msgOut, "Файл успешно расшифрован. Результат в файле: %s\n", *outputFile)
+		} else {
+			fmt.Fprintln(msgOut, "Атака завершилась неудачно: время истекло, множители n не найдены.")
+			fmt.Fprintln(msgOut, "Ро-методу нужно около sqrt(p) итераций, он подходит только для малых делителей. Можно увеличить --rho-timeout.")
+		}
+		return exitOK
+	}
+
+	if *pp1Mode {
+		// при смене начального значения прогресс этапов выводится заново
+		var lastSeed int64
+		var progress func(stage int, done float64)
+		params := utils.WilliamsPP1Params{B1: *pp1B1, B2: *pp1B2, Seeds: *pp1Seeds, Progress: func(seed int64, stage int, done float64) {
+			if seed != lastSeed {
+				lastSeed, progress = seed, stageProgress()
+				fmt.Fprintf(msgOut, "Начальное значение A = %d\n", seed)
+			}
+			progress(stage, done)
+		}}
+		fmt.Fprintln(msgOut, "Выбран режим попытки проведения атаки методом Уильямса p + 1!")
+		fmt.Fprintf(msgOut, "Путь к файлу: %s\n", fPath)
+		fmt.Fprintf(msgOut, "Путь к файлу публичного ключа: %s\n", fPublicKey)
+		fmt.Fprintf(msgOut, "Границы: B1 = %d, B2 = %d, начальных значений: %d\n", params.B1, params.B2, params.Seeds)
+
+		start := time.Now()
+		result, privateKey, err := WilliamsPP1(ctx, fPath, fPublicKey, *outputFile, *jobs, params)
+		if err != nil {
+			return errorExitCode("Во время попытки атаки методом p + 1 произошла ошибка", err)
+		}
+
+		fmt.Fprintf(msgOut, "Время работы: %s\n", time.Since(start).Round(time.Millisecond))
+		if result.P != nil {
+			fmt.Fprintf(msgOut, "Атака завершилась успешно с A = %d на этапе %d. Множители n: p = %s, q = %s\n", result.Seed, result.Stage, result.P, result.Q)
+			fmt.Fprintf(msgOut, "Приватный ключ d = %s\n", privateKey.D)
+			fmt.Fprintf(msgOut, "Файл успешно расшифрован. Результат в файле: %s\n", *outputFile)
+		} else {
+			fmt.Fprintln(msgOut, "Атака завершилась неудачно. Множители n не найдены.")
+			fmt.Fprintln(msgOut, "У p + 1 и q + 1 есть простые делители больше границ, либо не подошли начальные значения. Можно увеличить --pp1-b1, --pp1-b2 и --pp1-seeds.")
+		}
+		return exitOK
+	}
+
+	if *ecmMode {
+		params := utils.ECMParams{B1: *ecmB1, B2: *ecmB2, Curves: *ecmCurves, Workers: *jobs, Progress: func(curves int) {
+			if curves%10 == 0 {
+				fmt.Fprintf(msgOut, "Проверено кривых: %d\n", curves)
+			}
+		}}
+		fmt.Fprintln(msgOut, "Выбран режим попытки проведения атаки методом эллиптических кривых!")
+		fmt.Fprintf(msgOut, "Путь к файлу: %s\n", fPath)
+		fmt.Fprintf(msgOut, "Путь к файлу публичного ключа: %s\n", fPublicKey)
+		fmt.Fprintf(msgOut, "Границы: B1 = %d, B2 = %d, кривых: %d, горутин: %d\n", params.B1, params.B2, params.Curves, params.Workers)
+
+		start := time.Now()
+		factorization, privateKey, err := ECM(ctx, fPath, fPublicKey, *outputFile, params)
+		if err != nil {
+			return errorExitCode("Во время попытки атаки методом эллиптических кривых произошла ошибка", err)
+		}
+
+		fmt.Fprintf(msgOut, "Проверено кривых: %d\n", factorization.Curves)
+		fmt.Fprintf(msgOut, "Время работы: %s\n", time.Since(start).Round(time.Millisecond))
+		if factorization.Rest == nil {
+			fmt.Fprintln(msgOut, "Атака завершилась успешно. Простые множители n:")
+			for _, p := range factorization.Primes {
+				fmt.Fprintf(msgOut, "  %s\n", p)
+			}
+			fmt.Fprintf(msgOut, "Приватный ключ d = %s\n", privateKey.D)
+			fmt.Fprintf(msgOut, "Файл успешно расшифрован. Результат в файле: %s\n", *outputFile)
+		} else {
+			fmt.Fprintln(msgOut, "Атака завершилась неудачно. Разложить n полностью не удалось.")
+			for _, p := range factorization.Primes {
+				fmt.Fprintf(msgOut, "Найден простой множитель: %s\n", p)
+			}
+			fmt.Fprintf(msgOut, "Не разложен составной делитель из %d бит\n", factorization.Rest.BitLen())
+			fmt.Fprintln(msgOut, "Наименьший делитель слишком велик для заданных границ. Можно увеличить --ecm-b1, --ecm-b2 и --ecm-curves.")
+		}
+		return exitOK
+	}
+
+	if *cmMode {
+		fmt.Fprintln(msgOut, "Выбран режим попытки проведения атаки на общий модуль!")
+		for i, publicKeyFile := range publicKeys {
+			fmt.Fprintf(msgOut, "Путь к файлу публичного ключа %d: %s\n", i+1, publicKeyFile)
+		}
+		for i, filename := range inputFiles {
+			fmt.Fprintf(msgOut, "Путь к файлу шифра %d: %s\n", i+1, filename)
+		}
+
+		result, err := CommonModulus(inputFiles, publicKeys, *outputFile)
+		if err != nil {
+			return errorExitCode("Во время попытки атаки на общий модуль произошла ошибка", err)
+		}
+		fmt.Fprintf(msgOut, "Атака завершилась успешно: a * e1 + b * e2 = 1, a = %s, b = %s\n", result.A, result.B)
+		fmt.Fprintf(msgOut, "Открытый текст восстановлен. Результат в файле: %s\n", *outputFile)
+		return exitOK
+	}
+
+	if *hastadMode {
+		fmt.Fprintln(msgOut, "Выбран режим попытки проведения широковещательной атаки Хастада!")
+		for i, publicKeyFile := range publicKeys {
+			fmt.Fprintf(msgOut, "Путь к файлу публичного ключа %d: %s\n", i+1, publicKeyFile)
+		}
+		for i, filename := range inputFiles {
+			fmt.Fprintf(msgOut, "Путь к файлу шифра %d: %s\n", i+1, filename)
+		}
+
+		err := Hastad(inputFiles, publicKeys, *outputFile)
+		if err != nil {
+			return errorExitCode("Во время попытки атаки Хастада произошла ошибка", err)
+		}
+		fmt.Fprintf(msgOut, "Открытый текст восстановлен. Результат в файле: %s\n", *outputFile)
+		return exitOK
+	}
+
+	// режим зашифрования
+	if *cMode {
+		fmt.Fprintln(msgOut, "Выбран режим зашифрования")
+		fmt.Fprintf(msgOut, "Путь к файлу: %s\n", fPath)
+		for _, publicKeyFile := range publicKeys {
+			fmt.Fprintf(msgOut, "Путь к файлу публичного ключа получателя: %s\n", publicKeyFile)
+		}
+		if *unauthenticated {
+			fmt.Fprintln(msgOut, "Формат: поблочный, без защиты от подделки")
+		}
+
+		// запускаем процедуру зашифрования
+		// в ней же происходит сохранение файлов
+		err := ChipherFile(ctx, fPath, *outputFile, publicKeys, *jobs, *unauthenticated)
+		if err != nil {
+			return errorExitCode("Во время зашифрования произошла ошибка", err)
+		}
+		fmt.Fprintf(msgOut, "Файл успешно зашифрован. Результат в файле: %s\n", *outputFile)
+		return exitOK
+	}
+
+	// ослепление сообщения
+	if *blindMode {
+		fmt.Fprintln(msgOut, "Выбран режим ослепления сообщения")
+		fmt.Fprintf(msgOut, "Путь к файлу: %s\n", fPath)
+		fmt.Fprintf(msgOut, "Путь к файлу публичного ключа подписывающего: %s\n", fPublicKey)
+		fmt.Fprintf(msgOut, "Вариант: %s\n", blindVariant.Name)
+
+		err := BlindFile(fPath, *outputFile, *fState, fPublicKey, blindVariant)
+		if err != nil {
+			return errorExitCode("Во время ослепления произошла ошибка", err)
+		}
+		fmt.Fprintf(msgOut, "Сообщение ослеплено. Передайте подписывающему файл: %s\n", *outputFile)
+		fmt.Fprintf(msgOut, "Файл состояния %s никому не передавайте, он нужен для снятия ослепления\n", *fState)
+		return exitOK
+	}
+
+	// снятие ослепления с подписи
+	if *finalizeMode {
+		fmt.Fprintln(msgOut, "Выбран режим снятия ослепления с подписи")
+		fmt.Fprintf(msgOut, "Путь к файлу: %s\n", fPath)
+		fmt.Fprintf(msgOut, "Путь к файлу слепой подписи: %s\n", *fSignature)
+		fmt.Fprintf(msgOut, "Путь к файлу публичного ключа подписывающего: %s\n", fPublicKey)
+
+		err := FinalizeFile(fPath, *outputFile, *fState, *fSignature, fPublicKey)
+		if err != nil {
+			return errorExitCode("Во время снятия ослепления произошла ошибка", err)
+		}
+		fmt.Fprintf(msgOut, "Подпись получена и проверена. Результат в файле: %s\n", *outputFile)
+		return exitOK
+	}
+
+	// Проверяем что задан путь к файлу с приватным ключом
+	if *fPrivateKey == "" {
+		fmt.Fprintln(msgOut, "Не указан путь к файлу с приватным ключом. Укажите параметр --private-key <имя файла>")
+		return exitError
+	}
+
+	// режим формирования подписи
+	if *signMode {
+		fmt.Fprintln(msgOut, "Выбран режим формирования подписи файла.")
+		fmt.Fprintf(msgOut, "Путь к файлу: %s\n", fPath)
+		fmt.Fprintf(msgOut, "Путь к файлу приватного ключа: %s\n", *fPrivateKey)
+		fmt.Fprintf(msgOut, "Путь к файлу публичного ключа: %s\n", fPublicKey)
+
+		err := SignFile(fPath, *fSignature, fPublicKey, *fPrivateKey, sigParams, *expBlinding)
+		if err != nil {
+			return errorExitCode("Во время формирования подписи произошла ошибка", err)
+		}
+		fmt.Fprintf(msgOut, "Подпись сформирована. Результат в файле: %s\n", *fSignature)
+		return exitOK
+	}
+
+	// подпись ослепленного сообщения
+	if *blindSignMode {
+		fmt.Fprintln(msgOut, "Выбран режим подписи ослепленного сообщения")
+		fmt.Fprintf(msgOut, "Путь к файлу: %s\n", fPath)
+		fmt.Fprintf(msgOut, "Путь к файлу приватного ключа: %s\n", *fPrivateKey)
+		fmt.Fprintf(msgOut, "Путь к файлу публичного ключа: %s\n", fPublicKey)
+
+		err := BlindSignFile(fPath, *outputFile, fPublicKey, *fPrivateKey, *expBlinding)
 		if err != nil {
-			fmt.Printf("Во время зашифрования произошла ошибка: %s\n", err.Error())
-			os.Exit(1)
+			return errorExitCode("Во время подписи произошла ошибка", err)
 		}
-		fmt.Printf("Файл успешно зашифрован. Результат в файле: %s\n", *outputFile)
-		os.Exit(0)
+		fmt.Fprintf(msgOut, "Слепая подпись сформирована. Передайте клиенту файл: %s\n", *outputFile)
+		return exitOK
 	}
 
 	// процедура расшифрования
 	if *dMode {
-		fmt.Println("Выбран режим расшифрования")
-		fmt.Printf("Путь к файлу: %s\n", *fPath)
-		fmt.Printf("Путь к файлу приватного ключа: %s\n", *fPrivateKey)
-		fmt.Printf("Путь к файлу публичного ключа: %s\n", *fPublicKey)
+		fmt.Fprintln(msgOut, "Выбран режим расшифрования")
+		fmt.Fprintf(msgOut, "Путь к файлу: %s\n", fPath)
+		fmt.Fprintf(msgOut, "Путь к файлу приватного ключа: %s\n", *fPrivateKey)
+		fmt.Fprintf(msgOut, "Путь к файлу публичного ключа: %s\n", fPublicKey)
 
 		// запускаем процедуру расшифрования
 		// в ней же происходит сохранение файлов
-		err := DeChipherFile(*fPath, *outputFile, *fPublicKey, *fPrivateKey)
+		err := DeChipherFile(ctx, fPath, *outputFile, fPublicKey, *fPrivateKey, *jobs, *expBlinding, *unauthenticated)
 		if err != nil {
-			fmt.Printf("Во время расшифрования произошла ошибка: %s\n", err.Error())
-			os.Exit(1)
+			return errorExitCode("Во время расшифрования произошла ошибка", err)
 		}
-		fmt.Printf("Файл успешно расшифрован. Результат в файле: %s\n", *outputFile)
-		os.Exit(0)
+		fmt.Fprintf(msgOut, "Файл успешно расшифрован. Результат в файле: %s\n", *outputFile)
+		return exitOK
 	}
-	fmt.Println("Не указан режим работы программы. Исползуйте -h для вызова справки")
+	fmt.Fprintln(msgOut, "Не указан режим работы программы. Исползуйте -h для вызова справки")
+	return exitOK
 }
 
 // https://www.geeksforgeeks.org/how-to-generate-large-prime-numbers-for-rsa-algorithm/amp/
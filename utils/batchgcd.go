@@ -0,0 +1,183 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"math/big"
+	"sort"
+)
+
+// Пакетный НОД Бернштейна.
+// Ключи, сгенерированные со сломанным генератором случайных чисел, иногда имеют общий простой множитель,
+// и тогда НОД(n_i, n_j) раскладывает оба модуля. Попарные НОД требуют k^2 / 2 операций, поэтому сначала
+// строится дерево произведений P = n_1 * ... * n_k, затем дерево остатков P mod n_i^2, и
+// НОД((P mod n_i^2) / n_i, n_i) = НОД(n_i, Π_(j != i) n_j) для всех ключей сразу за квазилинейное время.
+
+// Пара ключей с общим простым множителем
+type SharedFactor struct {
+	// номера ключей, I < J
+	I, J int
+	// общий простой множитель
+	P *big.Int
+}
+
+// Результат пакетного НОД
+type BatchGCDResult struct {
+	// Factors[i] - простой множитель n_i, общий с другим ключом, nil - общих множителей нет
+	Factors []*big.Int
+	// все пары ключей с общим простым множителем
+	Pairs []SharedFactor
+	// пары ключей с одинаковым n: у них общие оба множителя, разложить их по НОД нельзя
+	Duplicates [][2]int
+}
+
+// Дерево произведений: levels[0] - сами числа, levels[h][i] = levels[h-1][2i] * levels[h-1][2i+1],
+// последний уровень - одно произведение всех чисел
+func productTree(ctx context.Context, values []*big.Int, jobs int) ([][]*big.Int, error) {
+	levels := [][]*big.Int{values}
+	for level := values; len(level) > 1; {
+		next := make([]*big.Int, (len(level)+1)/2)
+		err := processBlocks(ctx, jobs, next, func(i int, _ *big.Int) *big.Int {
+			if 2*i+1 == len(level) {
+				return level[2*i]
+			}
+			return new(big.Int).Mul(level[2*i], level[2*i+1])
+		})
+		if err != nil {
+			return nil, err
+		}
+		levels = append(levels, next)
+		level = next
+	}
+	return levels, nil
+}
+
+// НОД(n_i, Π_(j != i) n_j) для всех n_i деревом остатков по дереву произведений
+func batchGCDs(ctx context.Context, moduli []*big.Int, jobs int) ([]*big.Int, error) {
+	levels, err := productTree(ctx, moduli, jobs)
+	if err != nil {
+		return nil, err
+	}
+	// спуск от корня: остаток узла = остаток родителя mod (узел)^2
+	rems := levels[len(levels)-1]
+	for h := len(levels) - 2; h >= 0; h-- {
+		parent, level := rems, levels[h]
+		rems = make([]*big.Int, len(level))
+		err := processBlocks(ctx, jobs, rems, func(i int, _ *big.Int) *big.Int {
+			square := new(big.Int).Mul(level[i], level[i])
+			return square.Mod(parent[i/2], square)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	// (P mod n^2) / n = (P / n) mod n
+	gcds := make([]*big.Int, len(moduli))
+	err = processBlocks(ctx, jobs, gcds, func(i int, _ *big.Int) *big.Int {
+		q := new(big.Int).Quo(rems[i], moduli[i])
+		return q.GCD(nil, nil, q, moduli[i])
+	})
+	return gcds, err
+}
+
+// Поиск общих простых множителей у модулей moduli пакетным НОД в jobs горутинах
+func BatchGCD(ctx context.Context, moduli []*big.Int, jobs int) (*BatchGCDResult, error) {
+	if len(moduli) < 2 {
+		return nil, errors.New("для пакетного НОД нужно не меньше двух ключей")
+	}
+	result := &BatchGCDResult{Factors: make([]*big.Int, len(moduli))}
+
+	// одинаковые модули дали бы НОД = n, поэтому в дерево попадает по одному экземпляру
+	var unique []*big.Int
+	// owners[u] - номера ключей с модулем unique[u]
+	var owners [][]int
+	index := make(map[string]int)
+	for i, n := range moduli {
+		key := string(n.Bytes())
+		if u, ok := index[key]; ok {
+			for _, j := range owners[u] {
+				result.Duplicates = append(result.Duplicates, [2]int{j, i})
+			}
+			owners[u] = append(owners[u], i)
+			continue
+		}
+		index[key] = len(unique)
+		unique = append(unique, n)
+		owners = append(owners, []int{i})
+	}
+	if len(unique) < 2 {
+		return result, nil
+	}
+
+	gcds, err := batchGCDs(ctx, unique, jobs)
+	if err != nil {
+		return nil, err
+	}
+
+	// общие простые каждого уязвимого модуля: при НОД < n это сам НОД,
+	// при НОД = n общими могут быть оба множителя, их находим попарными НОД среди уязвимых модулей
+	var vulnerable []int
+	for u, g := range gcds {
+		if g.Cmp(i1) != 0 {
+			vulnerable = append(vulnerable, u)
+		}
+	}
+	shared := make(map[string]*big.Int)
+	holders := make(map[string][]int)
+	addShared := func(u int, p *big.Int) {
+		key := p.String()
+		if _, ok := shared[key]; !ok {
+			shared[key] = p
+		}
+		for _, v := range holders[key] {
+			if v == u {
+				return
+			}
+		}
+		holders[key] = append(holders[key], u)
+	}
+	for _, u := range vulnerable {
+		if gcds[u].Cmp(unique[u]) != 0 {
+			addShared(u, gcds[u])
+			continue
+		}
+		for _, v := range vulnerable {
+			if v == u {
+				continue
+			}
+			// НОД двух разных модулей из двух простых - одно общее простое
+			if p, _ := splitGCD(unique[u], unique[v]); p != nil {
+				addShared(u, p)
+			}
+		}
+	}
+
+	// пары по каждому общему простому, с учетом ключей с одинаковыми модулями
+	for key, us := range holders {
+		p := shared[key]
+		var keys []int
+		for _, u := range us {
+			for _, i := range owners[u] {
+				if result.Factors[i] == nil {
+					result.Factors[i] = p
+				}
+				keys = append(keys, i)
+			}
+		}
+		sort.Ints(keys)
+		for a := 0; a < len(keys); a++ {
+			for b := a + 1; b < len(keys); b++ {
+				if moduli[keys[a]].Cmp(moduli[keys[b]]) != 0 {
+					result.Pairs = append(result.Pairs, SharedFactor{I: keys[a], J: keys[b], P: p})
+				}
+			}
+		}
+	}
+	sort.Slice(result.Pairs, func(a, b int) bool {
+		if result.Pairs[a].I != result.Pairs[b].I {
+			return result.Pairs[a].I < result.Pairs[b].I
+		}
+		return result.Pairs[a].J < result.Pairs[b].J
+	})
+	return result, nil
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+// n различных случайных простых по bits бит
+func testPrimes(t *testing.T, count, bits int) []*big.Int {
+	t.Helper()
+	var primes []*big.Int
+	seen := make(map[string]bool)
+	for len(primes) < count {
+		p, err := rand.Prime(rand.Reader, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !seen[p.String()] {
+			seen[p.String()] = true
+			primes = append(primes, p)
+		}
+	}
+	return primes
+}
+
+func mul(a, b *big.Int) *big.Int {
+	return new(big.Int).Mul(a, b)
+}
+
+// Общее простое, одинаковые модули и модуль, оба множителя которого есть у других ключей (НОД = n)
+func TestBatchGCD(t *testing.T) {
+	primes := testPrimes(t, 6, 128)
+	p, q, r, s, u, v := primes[0], primes[1], primes[2], primes[3], primes[4], primes[5]
+	moduli := []*big.Int{
+		mul(p, q),
+		mul(r, s),
+		// p общее с ключом 0, r - с ключом 1
+		mul(p, r),
+		mul(u, v),
+		// тот же модуль, что у ключа 0
+		mul(p, q),
+	}
+
+	for _, jobs := range []int{1, 4} {
+		result, err := BatchGCD(context.Background(), moduli, jobs)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want := []*big.Int{p, r, nil, nil, p}
+		for i, f := range result.Factors {
+			switch {
+			case i == 2:
+				// у ключа 2 общие оба простых, годится любое
+				if f == nil || (f.Cmp(p) != 0 && f.Cmp(r) != 0) {
+					t.Fatalf("jobs %d: ключ 2: множитель %v", jobs, f)
+				}
+			case want[i] == nil:
+				if f != nil {
+					t.Fatalf("jobs %d: ключ %d: лишний множитель %s", jobs, i, f)
+				}
+			case f == nil || f.Cmp(want[i]) != 0:
+				t.Fatalf("jobs %d: ключ %d: множитель %v, ожидалось %s", jobs, i, f, want[i])
+			}
+		}
+
+		wantPairs := []SharedFactor{{0, 2, p}, {1, 2, r}, {2, 4, p}}
+		if len(result.Pairs) != len(wantPairs) {
+			t.Fatalf("jobs %d: пары %v, ожидалось %v", jobs, result.Pairs, wantPairs)
+		}
+		for i, pair := range result.Pairs {
+			if pair.I != wantPairs[i].I || pair.J != wantPairs[i].J || pair.P.Cmp(wantPairs[i].P) != 0 {
+				t.Fatalf("jobs %d: пары %v, ожидалось %v", jobs, result.Pairs, wantPairs)
+			}
+		}
+
+		if len(result.Duplicates) != 1 || result.Duplicates[0] != [2]int{0, 4} {
+			t.Fatalf("jobs %d: одинаковые модули %v, ожидалось [[0 4]]", jobs, result.Duplicates)
+		}
+	}
+}
+
+// Без общих множителей, только одинаковые модули и меньше двух ключей
+func TestBatchGCDEdgeCases(t *testing.T) {
+	primes := testPrimes(t, 6, 64)
+	distinct := []*big.Int{mul(primes[0], primes[1]), mul(primes[2], primes[3]), mul(primes[4], primes[5])}
+	result, err := BatchGCD(context.Background(), distinct, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Pairs) != 0 || len(result.Duplicates) != 0 {
+		t.Fatalf("взаимно простые модули: %+v", result)
+	}
+	for i, f := range result.Factors {
+		if f != nil {
+			t.Fatalf("ключ %d: лишний множитель %s", i, f)
+		}
+	}
+
+	same := []*big.Int{distinct[0], distinct[0], distinct[0]}
+	result, err = BatchGCD(context.Background(), same, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Pairs) != 0 || len(result.Duplicates) != 3 {
+		t.Fatalf("одинаковые модули: пары %v, одинаковые %v", result.Pairs, result.Duplicates)
+	}
+
+	if _, err := BatchGCD(context.Background(), distinct[:1], 2); err == nil {
+		t.Fatal("один ключ принят")
+	}
+}
@@ -0,0 +1,93 @@
+package utils
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Ослепление операций с приватным ключом.
+// Перед возведением в секретную степень шифр умножается на r ^ e (mod n) для случайного r,
+// а результат - на r^-1 (mod n): (c * r^e)^d * r^-1 = c^d (mod n).
+// Так время операции и промежуточные значения не связаны с входными данными,
+// которые может подобрать атакующий.
+// Дополнительно можно ослепить и саму степень: d' = d + k * (e * d - 1).
+// e * d - 1 кратно φ(n), поэтому c^d' = c^d (mod n), а двоичная запись d' меняется при каждой операции.
+// φ(n) в файле приватного ключа не хранится, поэтому используется именно e * d - 1.
+
+// Ослепляющие параметры одной операции
+type blinder struct {
+	// r ^ e (mod n) и r^-1 (mod n)
+	re, rInv *big.Int
+	// степень для возведения, ослепленная или исходная
+	d *big.Int
+}
+
+// источник случайности для ослепления
+func (privKey *PrivateKey) random() io.Reader {
+	if privKey.Rand != nil {
+		return privKey.Rand
+	}
+	return rand.Reader
+}
+
+// Генерация ослепляющих параметров для одной операции с приватным ключом
+func (privKey *PrivateKey) newBlinder(pubKey *PublicKey) (*blinder, error) {
+	n := pubKey.N
+	b := &blinder{d: privKey.D}
+
+	// выбираем случайное r от 2 до n - 1, обратимое по модулю n
+	for b.rInv == nil {
+		r, err := rand.Int(privKey.random(), n)
+		if err != nil {
+			return nil, fmt.Errorf("не удалось получить случайное число для ослепления: %w", err)
+		}
+		if r.Cmp(i1) <= 0 {
+			continue
+		}
+		b.rInv = new(big.Int).ModInverse(r, n)
+		b.re = pubKey.expE(r)
+	}
+
+	// d' = d + k * (e * d - 1) для случайного 64-битного k
+	if privKey.ExponentBlinding {
+		var buf [8]byte
+		if _, err := io.ReadFull(privKey.random(), buf[:]); err != nil {
+			return nil, fmt.Errorf("не удалось получить случайное число для ослепления степени: %w", err)
+		}
+		k := new(big.Int).SetUint64(binary.BigEndian.Uint64(buf[:]))
+		multiple := new(big.Int).Mul(pubKey.E, privKey.D)
+		multiple.Sub(multiple, i1)
+		b.d = new(big.Int).Add(privKey.D, multiple.Mul(multiple, k))
+	}
+
+	return b, nil
+}
+
+// c ^ d (mod n) с заранее сгенерированными ослепляющими параметрами
+func (privKey *PrivateKey) expBlinded(c *big.Int, pubKey *PublicKey, b *blinder) *big.Int {
+	n := pubKey.N
+
+	// c' = c * r^e (mod n)
+	blinded := new(big.Int).Mul(c, b.re)
+	blinded.Mod(blinded, n)
+
+	// m' = c' ^ d (mod n)
+	m := privKey.expD(blinded, b.d, n)
+
+	// m = m' * r^-1 (mod n)
+	m.Mul(m, b.rInv)
+	return m.Mod(m, n)
+}
+
+// c ^ d (mod n) с ослеплением
+// используется для всех операций с приватным ключом
+func (privKey *PrivateKey) blindedExpD(c *big.Int, pubKey *PublicKey) (*big.Int, error) {
+	b, err := privKey.newBlinder(pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return privKey.expBlinded(c, pubKey, b), nil
+}
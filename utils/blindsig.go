@@ -0,0 +1,229 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+)
+
+// Слепая подпись RSABSSA по RFC 9474 (схема Чаума с кодированием PSS).
+// Клиент готовит сообщение (Prepare), ослепляет его (Blind) и отправляет подписывающему.
+// Подписывающий возводит ослепленное сообщение в степень d (SignBlinded), не видя самого сообщения.
+// Клиент снимает ослепление (Unblind) и получает обычную подпись RSASSA-PSS,
+// которую любой может проверить публичным ключом (VerifyBlind), не связывая ее с сеансом подписи.
+
+// длина случайного префикса сообщения для вариантов Randomized
+const blindPrefixSize = 32
+
+// Вариант протокола слепой подписи (RFC 9474, раздел 5)
+type BlindVariant struct {
+	// имя варианта из RFC
+	Name string
+	// хэш-функция PSS
+	Hash crypto.Hash
+	// точная длина соли PSS в байтах
+	SaltLength int
+	// добавлять ли к сообщению случайный префикс
+	Randomized bool
+}
+
+// Варианты протокола, рекомендованные RFC 9474
+var (
+	BlindSHA384PSSRandomized        = &BlindVariant{"RSABSSA-SHA384-PSS-Randomized", crypto.SHA384, 48, true}
+	BlindSHA384PSSZeroRandomized    = &BlindVariant{"RSABSSA-SHA384-PSSZERO-Randomized", crypto.SHA384, 0, true}
+	BlindSHA384PSSDeterministic     = &BlindVariant{"RSABSSA-SHA384-PSS-Deterministic", crypto.SHA384, 48, false}
+	BlindSHA384PSSZeroDeterministic = &BlindVariant{"RSABSSA-SHA384-PSSZERO-Deterministic", crypto.SHA384, 0, false}
+)
+
+// Поиск варианта протокола по имени из RFC, без учета регистра
+func BlindVariantByName(name string) (*BlindVariant, error) {
+	for _, v := range []*BlindVariant{
+		BlindSHA384PSSRandomized,
+		BlindSHA384PSSZeroRandomized,
+		BlindSHA384PSSDeterministic,
+		BlindSHA384PSSZeroDeterministic,
+	} {
+		if strings.EqualFold(v.Name, name) {
+			return v, nil
+		}
+	}
+	return nil, fmt.Errorf("неизвестный вариант слепой подписи %q", name)
+}
+
+// Длина префикса, который Prepare добавляет к сообщению
+func (v *BlindVariant) PrefixSize() int {
+	if v.Randomized {
+		return blindPrefixSize
+	}
+	return 0
+}
+
+// Подготовка сообщения (RFC 9474, раздел 4.1).
+// Для вариантов Randomized к сообщению добавляется случайный 32-байтовый префикс,
+// подписывается и проверяется именно результат, поэтому префикс передается проверяющему вместе с подписью.
+// random - источник префикса, nil - crypto/rand.Reader.
+func (v *BlindVariant) Prepare(random io.Reader, msg []byte) ([]byte, error) {
+	if !v.Randomized {
+		return msg, nil
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+	prepared := make([]byte, blindPrefixSize+len(msg))
+	if _, err := io.ReadFull(random, prepared[:blindPrefixSize]); err != nil {
+		return nil, err
+	}
+	copy(prepared[blindPrefixSize:], msg)
+	return prepared, nil
+}
+
+// хэш подготовленного сообщения
+func (v *BlindVariant) digest(msg []byte) ([]byte, error) {
+	if err := checkHash(v.Hash); err != nil {
+		return nil, err
+	}
+	h := v.Hash.New()
+	h.Write(msg)
+	return h.Sum(nil), nil
+}
+
+// Секрет клиента для снятия ослепления
+type Unblinder struct {
+	// вариант протокола, с которым сообщение было ослеплено
+	Variant *BlindVariant
+	// r^-1 (mod n)
+	Inv *big.Int
+}
+
+// Ослепление подготовленного сообщения msg (RFC 9474, раздел 4.2).
+// Возвращает ослепленное сообщение для подписывающего и секрет для снятия ослепления,
+// который нельзя никому передавать.
+// random - источник соли и ослепляющего множителя, nil - crypto/rand.Reader.
+func Blind(random io.Reader, pubKey *PublicKey, msg []byte, variant *BlindVariant) ([]byte, *Unblinder, error) {
+	if random == nil {
+		random = rand.Reader
+	}
+	digest, err := variant.digest(msg)
+	if err != nil {
+		return nil, nil, err
+	}
+	h := variant.Hash.New()
+
+	// encoded_msg = EMSA-PSS-ENCODE(msg, bit_len(n) - 1)
+	salt := make([]byte, variant.SaltLength)
+	if _, err := io.ReadFull(random, salt); err != nil {
+		return nil, nil, err
+	}
+	em, err := emsaPSSEncode(digest, pubKey.N.BitLen()-1, salt, h)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// m должно быть обратимо по модулю n
+	m := new(big.Int).SetBytes(em)
+	if new(big.Int).GCD(nil, nil, m, pubKey.N).Cmp(i1) != 0 {
+		return nil, nil, errors.New("сообщение не взаимно просто с модулем")
+	}
+
+	// выбираем случайное r от 1 до n - 1, обратимое по модулю n
+	var r, inv *big.Int
+	for inv == nil {
+		r, err = rand.Int(random, pubKey.N)
+		if err != nil {
+			return nil, nil, fmt.Errorf("не удалось получить случайное число для ослепления: %w", err)
+		}
+		if r.Sign() == 0 {
+			continue
+		}
+		inv = new(big.Int).ModInverse(r, pubKey.N)
+	}
+
+	// z = m * r^e (mod n)
+	z := pubKey.expE(r)
+	z.Mul(z, m)
+	z.Mod(z, pubKey.N)
+
+	return z.FillBytes(make([]byte, chipherBlockSize(pubKey.N))), &Unblinder{Variant: variant, Inv: inv}, nil
+}
+
+// Подпись ослепленного сообщения (RFC 9474, раздел 4.3).
+// Подписывающий не видит исходного сообщения, поэтому подписывает все, что ему прислали:
+// решение о выдаче подписи принимается до вызова, например по учетной записи клиента.
+// Результат проверяется публичным ключом, чтобы ошибка вычисления не раскрыла ключ.
+func (privKey *PrivateKey) SignBlinded(pubKey *PublicKey, blinded []byte) ([]byte, error) {
+	k := chipherBlockSize(pubKey.N)
+	if len(blinded) != k {
+		return nil, fmt.Errorf("%w: длина ослепленного сообщения %d, ожидается %d", ErrMalformedCiphertext, len(blinded), k)
+	}
+	m := new(big.Int).SetBytes(blinded)
+	if m.Cmp(pubKey.N) >= 0 {
+		return nil, fmt.Errorf("%w: ослепленное сообщение не меньше модуля", ErrMessageTooLong)
+	}
+
+	// s = m ^ d (mod n)
+	s, err := privKey.blindedExpD(m, pubKey)
+	if err != nil {
+		return nil, err
+	}
+	if pubKey.expE(s).Cmp(m) != 0 {
+		return nil, fmt.Errorf("%w: подпись не проверяется публичным ключом", ErrKeyMismatch)
+	}
+	return s.FillBytes(make([]byte, k)), nil
+}
+
+// Снятие ослепления с подписи (RFC 9474, раздел 4.4, Finalize).
+// msg - то же подготовленное сообщение, которое передавалось в Blind.
+// Полученная подпись сразу проверяется, при несовпадении возвращается ErrVerification.
+func (u *Unblinder) Unblind(pubKey *PublicKey, msg, blindSig []byte) ([]byte, error) {
+	k := chipherBlockSize(pubKey.N)
+	if len(blindSig) != k {
+		return nil, fmt.Errorf("%w: длина слепой подписи %d, ожидается %d", ErrMalformedCiphertext, len(blindSig), k)
+	}
+
+	// s = z * r^-1 (mod n)
+	s := new(big.Int).SetBytes(blindSig)
+	s.Mul(s, u.Inv)
+	s.Mod(s, pubKey.N)
+	sig := s.FillBytes(make([]byte, k))
+
+	if err := pubKey.VerifyBlind(msg, sig, u.Variant); err != nil {
+		return nil, err
+	}
+	return sig, nil
+}
+
+// Проверка итоговой слепой подписи подготовленного сообщения msg (RFC 9474, раздел 4.5).
+// Это обычная проверка RSASSA-PSS с точной длиной соли варианта.
+func (pubKey *PublicKey) VerifyBlind(msg, sig []byte, variant *BlindVariant) error {
+	digest, err := variant.digest(msg)
+	if err != nil {
+		return err
+	}
+	return pubKey.verifyPSS(digest, sig, variant.SaltLength, variant.Hash.New())
+}
+
+// Текстовое представление секрета клиента: имя варианта и r^-1 по строкам, как в файлах ключей
+func (u *Unblinder) MarshalText() ([]byte, error) {
+	return []byte(fmt.Sprintf("%s\n%s", u.Variant.Name, u.Inv)), nil
+}
+
+// Разбор секрета клиента из текстового представления
+func ParseUnblinder(data []byte) (*Unblinder, error) {
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 {
+		return nil, errors.New("неверный формат секрета ослепления: ожидается две строки")
+	}
+	variant, err := BlindVariantByName(strings.TrimSpace(lines[0]))
+	if err != nil {
+		return nil, err
+	}
+	inv, ok := new(big.Int).SetString(strings.TrimSpace(lines[1]), 10)
+	if !ok || inv.Sign() <= 0 {
+		return nil, errors.New("неверный формат секрета ослепления: r^-1 не является положительным числом")
+	}
+	return &Unblinder{Variant: variant, Inv: inv}, nil
+}
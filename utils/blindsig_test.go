@@ -0,0 +1,219 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rsa"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"os"
+	"testing"
+)
+
+var blindVariants = []*BlindVariant{
+	BlindSHA384PSSRandomized,
+	BlindSHA384PSSZeroRandomized,
+	BlindSHA384PSSDeterministic,
+	BlindSHA384PSSZeroDeterministic,
+}
+
+// Полный протокол для всех вариантов, итоговая подпись проверяется crypto/rsa.VerifyPSS
+func TestBlindSignRoundTrip(t *testing.T) {
+	std, pubKey, privKey := testStdKey(t, 2048)
+	msg := []byte("сообщение для слепой подписи")
+	for _, variant := range blindVariants {
+		prepared, err := variant.Prepare(nil, msg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(prepared) != variant.PrefixSize()+len(msg) || !bytes.Equal(prepared[variant.PrefixSize():], msg) {
+			t.Fatalf("%s: неверно подготовленное сообщение", variant.Name)
+		}
+
+		blinded, unblinder, err := Blind(nil, pubKey, prepared, variant)
+		if err != nil {
+			t.Fatalf("%s: %s", variant.Name, err)
+		}
+		blindSig, err := privKey.SignBlinded(pubKey, blinded)
+		if err != nil {
+			t.Fatalf("%s: %s", variant.Name, err)
+		}
+		sig, err := unblinder.Unblind(pubKey, prepared, blindSig)
+		if err != nil {
+			t.Fatalf("%s: %s", variant.Name, err)
+		}
+
+		if err := pubKey.VerifyBlind(prepared, sig, variant); err != nil {
+			t.Fatalf("%s: %s", variant.Name, err)
+		}
+		// нулевую длину соли crypto/rsa понимает как автоопределение, соль 0 при этом тоже находится
+		stdOpts := &rsa.PSSOptions{Hash: variant.Hash, SaltLength: variant.SaltLength}
+		if err := rsa.VerifyPSS(&std.PublicKey, variant.Hash, testDigest(variant.Hash, string(prepared)), sig, stdOpts); err != nil {
+			t.Fatalf("%s: crypto/rsa не принимает подпись: %s", variant.Name, err)
+		}
+
+		// подпись другого сообщения не проверяется
+		other := append([]byte(nil), prepared...)
+		other[len(other)-1] ^= 1
+		if err := pubKey.VerifyBlind(other, sig, variant); !errors.Is(err, ErrVerification) {
+			t.Fatalf("%s: чужое сообщение: ошибка %v, ожидалась ErrVerification", variant.Name, err)
+		}
+		if _, err := unblinder.Unblind(pubKey, other, blindSig); !errors.Is(err, ErrVerification) {
+			t.Fatalf("%s: снятие ослепления с чужим сообщением: ошибка %v, ожидалась ErrVerification", variant.Name, err)
+		}
+	}
+}
+
+// Секрет ослепления переживает сохранение в текст
+func TestUnblinderText(t *testing.T) {
+	_, pubKey, privKey := testStdKey(t, 1024)
+	msg := []byte("сообщение")
+	for _, variant := range blindVariants {
+		blinded, unblinder, err := Blind(nil, pubKey, msg, variant)
+		if err != nil {
+			t.Fatal(err)
+		}
+		text, err := unblinder.MarshalText()
+		if err != nil {
+			t.Fatal(err)
+		}
+		parsed, err := ParseUnblinder(text)
+		if err != nil {
+			t.Fatalf("%s: %s", variant.Name, err)
+		}
+		if parsed.Variant != variant || parsed.Inv.Cmp(unblinder.Inv) != 0 {
+			t.Fatalf("%s: секрет после разбора не совпадает", variant.Name)
+		}
+		blindSig, err := privKey.SignBlinded(pubKey, blinded)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := parsed.Unblind(pubKey, msg, blindSig); err != nil {
+			t.Fatalf("%s: %s", variant.Name, err)
+		}
+	}
+
+	for _, text := range []string{
+		"",
+		"RSABSSA-SHA384-PSS-Randomized",
+		"RSABSSA-SHA256-PSS-Randomized\n12345",
+		"RSABSSA-SHA384-PSS-Randomized\n0",
+		"RSABSSA-SHA384-PSS-Randomized\n-5",
+		"RSABSSA-SHA384-PSS-Randomized\nабв",
+		"RSABSSA-SHA384-PSS-Randomized\n1\n2",
+	} {
+		if _, err := ParseUnblinder([]byte(text)); err == nil {
+			t.Errorf("секрет %q принят", text)
+		}
+	}
+}
+
+// тестовый вектор RFC 9474
+type blindVector struct {
+	Name       string `json:"name"`
+	N          string `json:"n"`
+	E          string `json:"e"`
+	D          string `json:"d"`
+	MsgPrefix  string `json:"msg_prefix"`
+	Msg        string `json:"msg"`
+	InputMsg   string `json:"input_msg"`
+	Salt       string `json:"salt"`
+	Inv        string `json:"inv"`
+	BlindedMsg string `json:"blinded_msg"`
+	BlindSig   string `json:"blind_sig"`
+	Sig        string `json:"sig"`
+}
+
+func vectorInt(t *testing.T, s string) *big.Int {
+	t.Helper()
+	x, ok := new(big.Int).SetString(s, 0)
+	if !ok {
+		t.Fatalf("неверное число %q", s)
+	}
+	return x
+}
+
+func vectorBytes(t *testing.T, s string) []byte {
+	t.Helper()
+	b, err := hex.DecodeString(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+// Тестовые векторы RFC 9474, приложение A (testdata/rfc9474.json).
+// Соль и ослепляющий множитель r = inv^-1 подаются в Blind как источник случайности.
+func TestBlindSignRFC9474(t *testing.T) {
+	data, err := os.ReadFile("testdata/rfc9474.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var vectors []blindVector
+	if err := json.Unmarshal(data, &vectors); err != nil {
+		t.Fatal(err)
+	}
+	if len(vectors) != len(blindVariants) {
+		t.Fatalf("%d векторов, ожидалось %d", len(vectors), len(blindVariants))
+	}
+
+	for _, v := range vectors {
+		variant, err := BlindVariantByName(v.Name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := vectorInt(t, v.N)
+		pubKey := NewPublicKey(vectorInt(t, v.E), n)
+		privKey := NewPrivateKey(vectorInt(t, v.D))
+
+		prepared, err := variant.Prepare(bytes.NewReader(vectorBytes(t, v.MsgPrefix)), vectorBytes(t, v.Msg))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(prepared, vectorBytes(t, v.InputMsg)) {
+			t.Fatalf("%s: подготовленное сообщение не совпадает", v.Name)
+		}
+
+		r := new(big.Int).ModInverse(vectorInt(t, v.Inv), n)
+		random := append(vectorBytes(t, v.Salt), r.FillBytes(make([]byte, chipherBlockSize(n)))...)
+		blinded, unblinder, err := Blind(bytes.NewReader(random), pubKey, prepared, variant)
+		if err != nil {
+			t.Fatalf("%s: %s", v.Name, err)
+		}
+		if !bytes.Equal(blinded, vectorBytes(t, v.BlindedMsg)) {
+			t.Fatalf("%s: ослепленное сообщение не совпадает", v.Name)
+		}
+		if unblinder.Inv.Cmp(vectorInt(t, v.Inv)) != 0 {
+			t.Fatalf("%s: r^-1 не совпадает", v.Name)
+		}
+
+		blindSig, err := privKey.SignBlinded(pubKey, blinded)
+		if err != nil {
+			t.Fatalf("%s: %s", v.Name, err)
+		}
+		if !bytes.Equal(blindSig, vectorBytes(t, v.BlindSig)) {
+			t.Fatalf("%s: слепая подпись не совпадает", v.Name)
+		}
+
+		sig, err := unblinder.Unblind(pubKey, prepared, blindSig)
+		if err != nil {
+			t.Fatalf("%s: %s", v.Name, err)
+		}
+		if !bytes.Equal(sig, vectorBytes(t, v.Sig)) {
+			t.Fatalf("%s: подпись не совпадает", v.Name)
+		}
+	}
+}
+
+// Слепая подпись отвергает блоки неверной длины и не меньше модуля
+func TestSignBlindedReject(t *testing.T) {
+	_, pubKey, privKey := testStdKey(t, 1024)
+	k := chipherBlockSize(pubKey.N)
+	if _, err := privKey.SignBlinded(pubKey, make([]byte, k-1)); !errors.Is(err, ErrMalformedCiphertext) {
+		t.Fatalf("короткий блок: ошибка %v, ожидалась ErrMalformedCiphertext", err)
+	}
+	if _, err := privKey.SignBlinded(pubKey, pubKey.N.FillBytes(make([]byte, k))); !errors.Is(err, ErrMessageTooLong) {
+		t.Fatalf("блок n: ошибка %v, ожидалась ErrMessageTooLong", err)
+	}
+}
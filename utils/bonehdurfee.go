@@ -0,0 +1,325 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+)
+
+// Атака Бонеха-Дерфи на малую приватную степень (d < n^0.292).
+// Из e * d = 1 + k * φ(n) и φ(n) = n + 1 - (p + q) следует
+// f(x, y) = 1 + x * (A + y) = 0 (mod e), где A = (n + 1) / 2, x = 2k, y = -(p + q) / 2.
+// Корень (x, y) мал: |x| < X ≈ 2n^δ, |y| < Y ≈ n^0.5, поэтому его можно найти методом Копперсмита:
+// строится решетка из сдвигов f (с заменой u = xy + 1 по Херрману-Мэю), после LLL-приведения
+// короткие векторы дают многочлены, у которых (x, y) - корень уже над целыми числами.
+// Исключив x через результант, находим y, а по нему - p, q и d.
+
+// Параметры атаки Бонеха-Дерфи
+type BonehDurfeeParams struct {
+	// m - наибольшая степень f в сдвигах, с ростом m граница ближе к 0.292, но решетка больше
+	M int
+	// t - количество сдвигов по y, отрицательное значение - выбрать автоматически как (1 - 2δ) * m
+	T int
+	// δ - предполагаемая граница d < n^δ
+	Delta float64
+}
+
+// Параметры по умолчанию
+var DefaultBonehDurfeeParams = BonehDurfeeParams{M: 6, T: -1, Delta: 0.26}
+
+// Результат атаки Бонеха-Дерфи
+type BonehDurfeeResult struct {
+	// приватная степень и множители n, nil - если атака не удалась
+	D, P, Q *big.Int
+	// фактически использованное t и размерность решетки
+	T         int
+	Dimension int
+}
+
+// моном u^U * x^X * y^Y
+type bdMonomial struct{ U, X, Y int }
+
+// многочлен от u, x, y
+type bdPoly map[bdMonomial]*big.Int
+
+func (p bdPoly) add(m bdMonomial, c *big.Int) {
+	if old, ok := p[m]; ok {
+		old.Add(old, c)
+	} else {
+		p[m] = new(big.Int).Set(c)
+	}
+}
+
+// (u - 1)^j = Σ C(j, r) * (-1)^(j-r) * u^r
+func uMinusOne(j, r int) *big.Int {
+	c := new(big.Int).Binomial(int64(j), int64(r))
+	if (j-r)%2 == 1 {
+		c.Neg(c)
+	}
+	return c
+}
+
+// Построение сдвигов f и соответствующих им мономов.
+// У каждого сдвига ровно один моном, не встречавшийся в предыдущих, поэтому решетка треугольная.
+func bdShifts(e, A *big.Int, m, t int) ([]bdPoly, []bdMonomial) {
+	var polys []bdPoly
+	var monomials []bdMonomial
+
+	// f^k = (u + A * x)^k = Σ C(k, l) * A^l * u^(k-l) * x^l
+	coef := func(k, l int) *big.Int {
+		c := new(big.Int).Binomial(int64(k), int64(l))
+		c.Mul(c, new(big.Int).Exp(A, big.NewInt(int64(l)), nil))
+		return c.Mul(c, new(big.Int).Exp(e, big.NewInt(int64(m-k)), nil))
+	}
+
+	// сдвиги по x: x^i * f^k * e^(m-k), новый моном u^k * x^i
+	for k := 0; k <= m; k++ {
+		for i := 0; i <= m-k; i++ {
+			p := bdPoly{}
+			for l := 0; l <= k; l++ {
+				p.add(bdMonomial{k - l, i + l, 0}, coef(k, l))
+			}
+			polys = append(polys, p)
+			monomials = append(monomials, bdMonomial{k, i, 0})
+		}
+	}
+
+	// сдвиги по y: y^j * f^k * e^(m-k) с заменой xy = u - 1, новый моном u^k * y^j
+	if t > 0 {
+		for j := 1; j <= t; j++ {
+			for k := (m / t) * j; k <= m; k++ {
+				p := bdPoly{}
+				for l := 0; l <= k; l++ {
+					c := coef(k, l)
+					if l >= j {
+						// u^(k-l) * x^(l-j) * (u - 1)^j
+						for r := 0; r <= j; r++ {
+							p.add(bdMonomial{k - l + r, l - j, 0}, new(big.Int).Mul(c, uMinusOne(j, r)))
+						}
+					} else {
+						// u^(k-l) * y^(j-l) * (u - 1)^l
+						for r := 0; r <= l; r++ {
+							p.add(bdMonomial{k - l + r, 0, j - l}, new(big.Int).Mul(c, uMinusOne(l, r)))
+						}
+					}
+				}
+				polys = append(polys, p)
+				monomials = append(monomials, bdMonomial{k, 0, j})
+			}
+		}
+	}
+	return polys, monomials
+}
+
+// значение монома в точке (U, X, Y)
+func (mono bdMonomial) weight(U, X, Y *big.Int) *big.Int {
+	w := new(big.Int).Exp(U, big.NewInt(int64(mono.U)), nil)
+	w.Mul(w, new(big.Int).Exp(X, big.NewInt(int64(mono.X)), nil))
+	return w.Mul(w, new(big.Int).Exp(Y, big.NewInt(int64(mono.Y)), nil))
+}
+
+// Многочлен от x и y из вектора приведенной решетки: коэффициенты делятся на веса мономов,
+// а u заменяется на xy + 1
+func bdVectorPoly(v []*big.Int, monomials []bdMonomial, weights []*big.Int) xyPoly {
+	var p xyPoly
+	coef := func(i, j int) *big.Int {
+		for len(p) <= i {
+			p = append(p, nil)
+		}
+		for len(p[i]) <= j {
+			p[i] = append(p[i], new(big.Int))
+		}
+		return p[i][j]
+	}
+
+	c := new(big.Int)
+	for idx, mono := range monomials {
+		if v[idx].Sign() == 0 {
+			continue
+		}
+		c.Quo(v[idx], weights[idx])
+		// (xy + 1)^a * x^b * y^c = Σ C(a, r) * x^(r+b) * y^(r+c)
+		for r := 0; r <= mono.U; r++ {
+			term := new(big.Int).Binomial(int64(mono.U), int64(r))
+			term.Mul(term, c)
+			cf := coef(r+mono.X, r+mono.Y)
+			cf.Add(cf, term)
+		}
+	}
+	for i := range p {
+		p[i] = p[i].normalize()
+	}
+	return p
+}
+
+// Удаление бесполезных строк решетки (Бонех-Дерфи, раздел 5).
+// Строка с диагональным элементом не меньше bound = e^m только увеличивает определитель,
+// ее можно убрать вместе со столбцом ее монома, если этот моном не встречается в следующих строках.
+func bdHelpfulOnly(basis [][]*big.Int, monomials []bdMonomial, weights []*big.Int, bound *big.Int) ([][]*big.Int, []bdMonomial, []*big.Int) {
+	keep := make([]bool, len(basis))
+	for i := range keep {
+		keep[i] = true
+	}
+	for i := len(basis) - 1; i >= 0; i-- {
+		if basis[i][i].CmpAbs(bound) < 0 {
+			continue
+		}
+		used := false
+		for j := i + 1; j < len(basis); j++ {
+			if keep[j] && basis[j][i].Sign() != 0 {
+				used = true
+				break
+			}
+		}
+		keep[i] = used
+	}
+
+	var rows [][]*big.Int
+	var monos []bdMonomial
+	var ws []*big.Int
+	for i := range basis {
+		if !keep[i] {
+			continue
+		}
+		var row []*big.Int
+		for j := range basis[i] {
+			if keep[j] {
+				row = append(row, basis[i][j])
+			}
+		}
+		rows = append(rows, row)
+		monos = append(monos, monomials[i])
+		ws = append(ws, weights[i])
+	}
+	return rows, monos, ws
+}
+
+// Восстановление p, q по y = -(p + q) / 2
+func factorsFromHalfSum(n, y *big.Int) (*big.Int, *big.Int, bool) {
+	// s = p + q, p и q - корни z^2 - s*z + n
+	s := new(big.Int).Lsh(y, 1)
+	s.Neg(s)
+	disc := new(big.Int).Mul(s, s)
+	disc.Sub(disc, new(big.Int).Lsh(n, 2))
+	if disc.Sign() < 0 {
+		return nil, nil, false
+	}
+	r := new(big.Int).Sqrt(disc)
+	if new(big.Int).Mul(r, r).Cmp(disc) != 0 {
+		return nil, nil, false
+	}
+	p := new(big.Int).Add(s, r)
+	p.Rsh(p, 1)
+	q := new(big.Int).Sub(s, r)
+	q.Rsh(q, 1)
+	if q.Cmp(i1) <= 0 || new(big.Int).Mul(p, q).Cmp(n) != 0 {
+		return nil, nil, false
+	}
+	return p, q, true
+}
+
+// Основная функция атаки Бонеха-Дерфи.
+// Если d не найден, возвращается результат с D == nil и без ошибки.
+// Долгое LLL-приведение прерывается отменой контекста.
+func BonehDurfeeAttack(ctx context.Context, n, e *big.Int, params BonehDurfeeParams) (*BonehDurfeeResult, error) {
+	m, t, delta := params.M, params.T, params.Delta
+	if m < 1 {
+		return nil, errors.New("параметр m должен быть положительным")
+	}
+	if delta <= 0 || delta >= 0.5 {
+		return nil, errors.New("параметр δ должен быть в интервале (0, 0.5)")
+	}
+	if t < 0 {
+		t = int((1 - 2*delta) * float64(m))
+	}
+	if t > m {
+		return nil, fmt.Errorf("параметр t = %d не может быть больше m = %d", t, m)
+	}
+	if n.Bit(0) == 0 {
+		return nil, errors.New("модуль должен быть нечетным")
+	}
+
+	// A = (n + 1) / 2, X = 2 * n^δ, Y = n^0.5, U = XY + 1
+	A := new(big.Int).Add(n, i1)
+	A.Rsh(A, 1)
+	X := new(big.Int).Lsh(i1, uint(math.Ceil(delta*float64(n.BitLen())))+1)
+	Y := new(big.Int).Sqrt(n)
+	U := new(big.Int).Mul(X, Y)
+	U.Add(U, i1)
+
+	polys, monomials := bdShifts(e, A, m, t)
+	dim := len(monomials)
+
+	// строки - сдвиги, столбцы - мономы, коэффициенты умножены на значения мономов в (U, X, Y)
+	column := make(map[bdMonomial]int, dim)
+	weights := make([]*big.Int, dim)
+	for i, mono := range monomials {
+		column[mono] = i
+		weights[i] = mono.weight(U, X, Y)
+	}
+	basis := make([][]*big.Int, dim)
+	for i, p := range polys {
+		basis[i] = make([]*big.Int, dim)
+		for j := range basis[i] {
+			basis[i][j] = new(big.Int)
+		}
+		for mono, c := range p {
+			j, ok := column[mono]
+			if !ok || j > i {
+				return nil, fmt.Errorf("решетка не треугольная: моном %v в сдвиге %d", mono, i)
+			}
+			basis[i][j].Mul(c, weights[j])
+		}
+	}
+
+	// убираем бесполезные строки: они только увеличивают определитель решетки
+	em := new(big.Int).Exp(e, big.NewInt(int64(m)), nil)
+	basis, monomials, weights = bdHelpfulOnly(basis, monomials, weights, em)
+	dim = len(basis)
+	result := &BonehDurfeeResult{T: t, Dimension: dim}
+
+	if err := LLLFloat(ctx, basis, big.NewRat(99, 100)); err != nil {
+		return nil, err
+	}
+
+	// по Хоугрейв-Грэму корень по модулю e^m заведомо является корнем над целыми числами,
+	// если норма вектора меньше e^m / sqrt(dim); на практике достаточно нормы меньше e^m
+	emSquared := new(big.Int).Mul(em, em)
+	var vectors []xyPoly
+	for i := range basis {
+		if dot(basis[i], basis[i]).Cmp(emSquared) >= 0 {
+			continue
+		}
+		vectors = append(vectors, bdVectorPoly(basis[i], monomials, weights))
+	}
+
+	// y = -(p + q) / 2 лежит в [-(n + 1) / 2, -sqrt(n)]
+	lo := new(big.Int).Neg(A)
+	hi := new(big.Int).Neg(Y)
+
+	// перебираем пары коротких векторов, пока результант не даст подходящий y
+	for i := 0; i < len(vectors); i++ {
+		for j := i + 1; j < len(vectors); j++ {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+			res := eliminateX(vectors[i], vectors[j])
+			if res.degree() <= 0 {
+				continue
+			}
+			for _, y := range res.integerRoots(lo, hi) {
+				p, q, ok := factorsFromHalfSum(n, y)
+				if !ok {
+					continue
+				}
+				if d := privateExponent(e, p, q); d != nil {
+					result.D, result.P, result.Q = d, p, q
+					return result, nil
+				}
+			}
+		}
+	}
+	return result, nil
+}
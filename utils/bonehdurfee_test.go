@@ -0,0 +1,181 @@
+package utils
+
+import (
+	"context"
+	"math/big"
+	"testing"
+)
+
+// многочлен от одной переменной из коэффициентов по возрастанию степени
+func testPoly(coefficients ...int64) intPoly {
+	p := make(intPoly, len(coefficients))
+	for i, c := range coefficients {
+		p[i] = big.NewInt(c)
+	}
+	return p
+}
+
+func equalInts(got []*big.Int, want ...int64) bool {
+	if len(got) != len(want) {
+		return false
+	}
+	for i := range got {
+		if got[i].Cmp(big.NewInt(want[i])) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Целые корни на отрезке: простые, кратные, близкие и нецелые корни
+func TestIntegerRoots(t *testing.T) {
+	lo, hi := big.NewInt(-10000), big.NewInt(10000)
+	cases := []struct {
+		name string
+		p    intPoly
+		want []int64
+	}{
+		// (x - 3)(x + 5)(x - 1000)(2x - 7): корень 3.5 не целый
+		{"простые корни", polyMul(polyMul(testPoly(-3, 1), testPoly(5, 1)), polyMul(testPoly(-1000, 1), testPoly(-7, 2))), []int64{-5, 3, 1000}},
+		// (x - 4)^2 (x + 2): двойной корень в экстремуме
+		{"двойной корень", polyMul(polyMul(testPoly(-4, 1), testPoly(-4, 1)), testPoly(2, 1)), []int64{-2, 4}},
+		// (x - 7)(x - 8): соседние корни по обе стороны от корня производной
+		{"соседние корни", polyMul(testPoly(-7, 1), testPoly(-8, 1)), []int64{7, 8}},
+		{"корни вне отрезка", polyMul(testPoly(-20000, 1), testPoly(20001, 1)), nil},
+		{"нет вещественных корней", testPoly(1, 0, 1), nil},
+		{"линейный", testPoly(12, -4), []int64{3}},
+		{"константа", testPoly(5), nil},
+	}
+	for _, c := range cases {
+		if got := c.p.integerRoots(lo, hi); !equalInts(got, c.want...) {
+			t.Errorf("%s: корни %v, ожидалось %v", c.name, got, c.want)
+		}
+	}
+
+	// большой корень порядка sqrt(n), как y в атаке
+	root := new(big.Int).Lsh(i1, 130)
+	root.Add(root, big.NewInt(12345))
+	p := polyMul(intPoly{new(big.Int).Neg(root), big.NewInt(1)}, testPoly(1, 0, 3))
+	got := p.integerRoots(new(big.Int), new(big.Int).Lsh(i1, 131))
+	if len(got) != 1 || got[0].Cmp(root) != 0 {
+		t.Errorf("большой корень: %v, ожидалось %s", got, root)
+	}
+}
+
+// Результант по x системы с известными общими корнями
+func TestEliminateX(t *testing.T) {
+	// p = xy - 6, q = x + y - 5: общие корни (2, 3) и (3, 2)
+	p := xyPoly{testPoly(-6), testPoly(0, 1)}
+	q := xyPoly{testPoly(-5, 1), testPoly(1)}
+	res := eliminateX(p, q)
+	if res.degree() != 2 {
+		t.Fatalf("степень результанта %d, ожидалось 2", res.degree())
+	}
+	if got := res.integerRoots(big.NewInt(-100), big.NewInt(100)); !equalInts(got, 2, 3) {
+		t.Fatalf("корни результанта %v, ожидалось [2 3]", got)
+	}
+
+	// p = x^2 - y, q = x^2 + x - y - 2: x = 2, y = 4
+	p = xyPoly{testPoly(0, -1), testPoly(), testPoly(1)}
+	q = xyPoly{testPoly(-2, -1), testPoly(1), testPoly(1)}
+	if got := eliminateX(p, q).integerRoots(big.NewInt(-100), big.NewInt(100)); !equalInts(got, 4) {
+		t.Fatalf("корни результанта %v, ожидалось [4]", got)
+	}
+
+	// многочлен без x возвращается как есть
+	if got := eliminateX(xyPoly{testPoly(-9, 0, 1)}, q); !equalInts(got.integerRoots(big.NewInt(-100), big.NewInt(100)), -3, 3) {
+		t.Fatalf("многочлен без x: %v", got)
+	}
+}
+
+// Ключ с d из 66 бит при 256-битном n (δ ≈ 0.258), на котором атака Винера не срабатывает
+func bdTestKey() (n, e, d, p, q *big.Int) {
+	n, _ = new(big.Int).SetString("102185782804561053327136203269994736309356356780960996017098762312468276722993", 10)
+	e, _ = new(big.Int).SetString("72459171172854773178348149829806002533544107660868137791084033138028257254579", 10)
+	d, _ = new(big.Int).SetString("37480281591754296235", 10)
+	p, _ = new(big.Int).SetString("324131975466178718873194125242017969439", 10)
+	q, _ = new(big.Int).SetString("315259803225502951836997766653362746287", 10)
+	return n, e, d, p, q
+}
+
+// Решетка треугольная, а корень (u, x, y) ключа - общий корень всех сдвигов по модулю e^m
+func TestBonehDurfeeShifts(t *testing.T) {
+	n, e, d, p, q := bdTestKey()
+	m, tShifts := 6, 2
+
+	// x = 2k, y = -(p + q) / 2, u = xy + 1, где e * d = 1 + k * φ(n)
+	phi := new(big.Int).Mul(new(big.Int).Sub(p, i1), new(big.Int).Sub(q, i1))
+	x := new(big.Int).Mul(e, d)
+	x.Sub(x, i1).Quo(x, phi).Lsh(x, 1)
+	y := new(big.Int).Add(p, q)
+	y.Rsh(y, 1).Neg(y)
+	u := new(big.Int).Mul(x, y)
+	u.Add(u, i1)
+	A := new(big.Int).Add(n, i1)
+	A.Rsh(A, 1)
+	em := new(big.Int).Exp(e, big.NewInt(int64(m)), nil)
+
+	polys, monomials := bdShifts(e, A, m, tShifts)
+	if len(polys) != len(monomials) {
+		t.Fatalf("%d сдвигов и %d мономов", len(polys), len(monomials))
+	}
+	seen := make(map[bdMonomial]bool)
+	for i, poly := range polys {
+		// новый моном сдвига встречается впервые, остальные - уже были
+		if seen[monomials[i]] {
+			t.Fatalf("сдвиг %d: моном %v уже был", i, monomials[i])
+		}
+		seen[monomials[i]] = true
+		value := new(big.Int)
+		for mono, c := range poly {
+			if !seen[mono] {
+				t.Fatalf("сдвиг %d: моном %v еще не встречался, решетка не треугольная", i, mono)
+			}
+			value.Add(value, new(big.Int).Mul(c, mono.weight(u, x, y)))
+		}
+		if value.Mod(value, em).Sign() != 0 {
+			t.Fatalf("сдвиг %d не обращается в 0 (mod e^m) на корне", i)
+		}
+	}
+}
+
+// Атака Винера на тестовом ключе не срабатывает, Бонеха-Дерфи находит d
+func TestBonehDurfeeAttack(t *testing.T) {
+	if testing.Short() {
+		t.Skip("атака Бонеха-Дерфи пропускается в режиме -short")
+	}
+	n, e, d, _, _ := bdTestKey()
+
+	if got, _ := WienerAttack(n, e); got != nil {
+		t.Fatalf("атака Винера нашла d = %s, ключ не подходит для теста", got)
+	}
+
+	result, err := BonehDurfeeAttack(context.Background(), n, e, DefaultBonehDurfeeParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.D == nil || result.D.Cmp(d) != 0 {
+		t.Fatalf("d = %v, ожидалось %s", result.D, d)
+	}
+	if new(big.Int).Mul(result.P, result.Q).Cmp(n) != 0 {
+		t.Fatalf("p * q != n")
+	}
+}
+
+// Неверные параметры отвергаются до построения решетки
+func TestBonehDurfeeParams(t *testing.T) {
+	n, e := big.NewInt(3233), big.NewInt(17)
+	for _, params := range []BonehDurfeeParams{
+		{M: 0, T: -1, Delta: 0.26},
+		{M: 6, T: -1, Delta: 0},
+		{M: 6, T: -1, Delta: 0.5},
+		{M: 4, T: 5, Delta: 0.26},
+	} {
+		if _, err := BonehDurfeeAttack(context.Background(), n, e, params); err == nil {
+			t.Errorf("параметры %+v приняты", params)
+		}
+	}
+	if _, err := BonehDurfeeAttack(context.Background(), big.NewInt(3232), e, DefaultBonehDurfeeParams); err == nil {
+		t.Error("четный модуль принят")
+	}
+}
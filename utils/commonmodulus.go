@@ -0,0 +1,84 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Атака на общий модуль.
+// Если одно сообщение m зашифровано двумя ключами с одним n и взаимно простыми e1, e2, то расширенный
+// алгоритм Евклида дает a * e1 + b * e2 = 1, и c1^a * c2^b = m^(a * e1 + b * e2) = m (mod n).
+// Один из коэффициентов отрицателен, для него берется обратный по модулю n блок шифра.
+// Закрытый ключ при этом не нужен и не восстанавливается.
+
+// Результат атаки на общий модуль
+type CommonModulusResult struct {
+	// коэффициенты a * e1 + b * e2 = 1
+	A, B *big.Int
+	// восстановленный открытый текст
+	Message []byte
+}
+
+// c^k (mod n) для целого k любого знака
+func expSigned(c, k, n *big.Int) (*big.Int, error) {
+	if k.Sign() >= 0 {
+		return new(big.Int).Exp(c, k, n), nil
+	}
+	inv := new(big.Int).ModInverse(c, n)
+	if inv == nil {
+		return nil, fmt.Errorf("блок шифра не обратим по модулю n, НОД(c, n) = %s", new(big.Int).GCD(nil, nil, c, n))
+	}
+	return inv.Exp(inv, new(big.Int).Neg(k), n), nil
+}
+
+// Восстановление открытого текста по двум шифрам в поблочном формате (ShipherBytes, EncryptWriter)
+// одного сообщения, зашифрованного ключами pub1 и pub2 с общим n
+func CommonModulusAttack(pub1, pub2 *PublicKey, chipher1, chipher2 []byte) (*CommonModulusResult, error) {
+	n := pub1.N
+	if n.Cmp(pub2.N) != 0 {
+		return nil, errors.New("у ключей разные модули n, атака на общий модуль неприменима")
+	}
+	gcd, a, b := extendedGCD(pub1.E, pub2.E)
+	if gcd.Cmp(i1) != 0 {
+		return nil, fmt.Errorf("e1 и e2 не взаимно просты, НОД(e1, e2) = %s", gcd)
+	}
+
+	blocks1, total1, err := parseBlocks(chipher1, n)
+	if err != nil {
+		return nil, fmt.Errorf("первый шифр: %w", err)
+	}
+	blocks2, total2, err := parseBlocks(chipher2, n)
+	if err != nil {
+		return nil, fmt.Errorf("второй шифр: %w", err)
+	}
+	if total1 != total2 {
+		return nil, fmt.Errorf("длины открытых текстов шифров различаются: %d и %d байт", total1, total2)
+	}
+
+	// m = c1^a * c2^b (mod n) для каждого блока
+	plain := make([]*big.Int, len(blocks1))
+	for i := range blocks1 {
+		x, err := expSigned(blocks1[i], a, n)
+		if err != nil {
+			return nil, &BlockError{Index: i, Err: err}
+		}
+		y, err := expSigned(blocks2[i], b, n)
+		if err != nil {
+			return nil, &BlockError{Index: i, Err: err}
+		}
+		m := x.Mul(x, y)
+		m.Mod(m, n)
+		// проверяем, что шифры действительно от одного сообщения
+		if pub1.expE(m).Cmp(blocks1[i]) != 0 || pub2.expE(m).Cmp(blocks2[i]) != 0 {
+			return nil, &BlockError{Index: i, Err: errors.New("шифры получены из разных сообщений")}
+		}
+		plain[i] = m
+	}
+
+	message, err := joinBlocks(plain, n, total1)
+	if err != nil {
+		return nil, err
+	}
+	return &CommonModulusResult{A: a, B: b, Message: message}, nil
+}
@@ -0,0 +1,103 @@
+package utils
+
+import (
+	"bytes"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+)
+
+// шифр в поблочном формате, как его пишет -enc -unauthenticated
+func encryptBlocks(tb testing.TB, pubKey *PublicKey, plain []byte) []byte {
+	tb.Helper()
+	var chipher bytes.Buffer
+	ew := NewEncryptWriter(&chipher, pubKey)
+	if _, err := ew.Write(plain); err != nil {
+		tb.Fatal(err)
+	}
+	if err := ew.Close(); err != nil {
+		tb.Fatal(err)
+	}
+	return chipher.Bytes()
+}
+
+// Сообщение из нескольких блоков, зашифрованное ключами с общим n и взаимно простыми e, восстанавливается
+func TestCommonModulusAttack(t *testing.T) {
+	pubKey, _ := testKeyPair(t, 512)
+	n := pubKey.N
+	plain := randomBytes(t, 3*plainBlockSize(n)+5)
+
+	for _, e := range [][2]int64{{65537, 3}, {3, 65537}, {17, 257}} {
+		pub1, pub2 := NewPublicKey(big.NewInt(e[0]), n), NewPublicKey(big.NewInt(e[1]), n)
+		result, err := CommonModulusAttack(pub1, pub2, encryptBlocks(t, pub1, plain), encryptBlocks(t, pub2, plain))
+		if err != nil {
+			t.Fatalf("e1 = %d, e2 = %d: %s", e[0], e[1], err)
+		}
+		if !bytes.Equal(result.Message, plain) {
+			t.Fatalf("e1 = %d, e2 = %d: восстановлен не тот текст", e[0], e[1])
+		}
+		// a * e1 + b * e2 = 1, один из коэффициентов отрицателен
+		sum := new(big.Int).Mul(result.A, pub1.E)
+		sum.Add(sum, new(big.Int).Mul(result.B, pub2.E))
+		if sum.Cmp(i1) != 0 || result.A.Sign()*result.B.Sign() >= 0 {
+			t.Fatalf("e1 = %d, e2 = %d: неверные коэффициенты a = %s, b = %s", e[0], e[1], result.A, result.B)
+		}
+	}
+}
+
+// Разные сообщения, разные модули и не взаимно простые e отвергаются
+func TestCommonModulusAttackReject(t *testing.T) {
+	pubKey, _ := testKeyPair(t, 512)
+	n := pubKey.N
+	size := 2 * plainBlockSize(n)
+	pub1, pub2 := NewPublicKey(big.NewInt(65537), n), NewPublicKey(big.NewInt(3), n)
+
+	_, err := CommonModulusAttack(pub1, pub2, encryptBlocks(t, pub1, randomBytes(t, size)), encryptBlocks(t, pub2, randomBytes(t, size)))
+	var blockErr *BlockError
+	if !errors.As(err, &blockErr) || !strings.Contains(err.Error(), "шифры получены из разных сообщений") {
+		t.Fatalf("разные сообщения: ошибка %v", err)
+	}
+
+	plain := randomBytes(t, size)
+	other, _ := testKeyPair(t, 512)
+	if _, err := CommonModulusAttack(pub1, other, encryptBlocks(t, pub1, plain), encryptBlocks(t, other, plain)); err == nil {
+		t.Fatal("ключи с разными n приняты")
+	}
+
+	pub6, pub9 := NewPublicKey(big.NewInt(6), n), NewPublicKey(big.NewInt(9), n)
+	_, err = CommonModulusAttack(pub6, pub9, encryptBlocks(t, pub6, plain), encryptBlocks(t, pub9, plain))
+	if err == nil || !strings.Contains(err.Error(), "НОД(e1, e2) = 3") {
+		t.Fatalf("e1 = 6, e2 = 9: ошибка %v", err)
+	}
+}
+
+// Отрицательная степень вычисляется через обратный элемент, необратимый блок дает ошибку
+func TestExpSigned(t *testing.T) {
+	n := big.NewInt(3 * 11)
+	for _, k := range []int64{-7, -1, 0, 1, 5} {
+		for c := int64(1); c < 33; c++ {
+			if new(big.Int).GCD(nil, nil, big.NewInt(c), n).Cmp(i1) != 0 {
+				continue
+			}
+			got, err := expSigned(big.NewInt(c), big.NewInt(k), n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// c^k * c^-k = 1 (mod n)
+			back := new(big.Int).Exp(big.NewInt(c), big.NewInt(-k), n)
+			if k < 0 && back.Mul(back, got).Mod(back, n).Cmp(i1) != 0 {
+				t.Fatalf("%d^%d = %s (mod 33) неверно", c, k, got)
+			}
+			if k >= 0 && got.Cmp(new(big.Int).Exp(big.NewInt(c), big.NewInt(k), n)) != 0 {
+				t.Fatalf("%d^%d = %s (mod 33) неверно", c, k, got)
+			}
+		}
+	}
+	if _, err := expSigned(big.NewInt(12), big.NewInt(-1), n); err == nil {
+		t.Fatal("необратимый блок 12 (mod 33) возведен в отрицательную степень")
+	}
+	if _, err := expSigned(big.NewInt(12), big.NewInt(2), n); err != nil {
+		t.Fatalf("необратимый блок в положительной степени: %s", err)
+	}
+}
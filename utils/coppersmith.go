@@ -0,0 +1,172 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"sort"
+)
+
+// Поиск малых корней многочлена по модулю методом Копперсмита в формулировке Хоугрейв-Грэма.
+// Для унитарного f степени d ищутся все x0, |x0| < X, с f(x0) = 0 (mod n); это возможно при X < n^(1/d).
+// Строится решетка из многочленов x^j * n^(m-i) * f^i (i < m, j < d) и x^i * f^m (i < t),
+// у которых x0 - корень по модулю n^m. Коэффициент при x^k умножается на X^k, поэтому
+// короткий вектор решетки после LLL дает многочлен, у которого x0 - корень уже над целыми числами.
+
+// наибольшая размерность решетки при автоматическом выборе параметров
+const maxSmallRootsDimension = 80
+
+// Параметры решетки Копперсмита
+type SmallRootsParams struct {
+	// m - степень n в модуле n^m, 0 - выбрать m и t автоматически по границе корня
+	M int
+	// t - количество дополнительных сдвигов x^i * f^m, учитывается только при заданном m
+	T int
+}
+
+// Результат поиска малых корней
+type SmallRootsResult struct {
+	// найденные корни по возрастанию, пустой список - корней нет или решетка слишком мала
+	Roots []*big.Int
+	// фактически использованные параметры и размерность решетки
+	M, T      int
+	Dimension int
+}
+
+// log2 для больших чисел
+func log2(x *big.Int) float64 {
+	f, _ := new(big.Float).SetInt(x).Float64()
+	if !math.IsInf(f, 0) {
+		return math.Log2(f)
+	}
+	// число вне диапазона float64: берем старшие 64 бита
+	shift := x.BitLen() - 64
+	f, _ = new(big.Float).SetInt(new(big.Int).Rsh(x, uint(shift))).Float64()
+	return math.Log2(f) + float64(shift)
+}
+
+// Подбор наименьшей решетки, для которой LLL гарантированно находит корень.
+// Определитель решетки n^(d*m*(m+1)/2 + t*m) * X^(w*(w-1)/2), w = d*m + t,
+// первый вектор после LLL не длиннее 2^((w-1)/4) * det^(1/w),
+// а по Хоугрейв-Грэму корень по модулю n^m - корень над целыми, если норма меньше n^m / sqrt(w).
+func smallRootsParams(d int, n, bound *big.Int) (int, int, bool) {
+	logN, logX := log2(n), log2(bound)
+	for w := d; w <= maxSmallRootsDimension; w++ {
+		for m := 1; d*m <= w; m++ {
+			t := w - d*m
+			logDet := float64(d*m*(m+1)/2+t*m)*logN + float64(w*(w-1)/2)*logX
+			if float64(w-1)/4+logDet/float64(w)+math.Log2(float64(w))/2 < float64(m)*logN {
+				return m, t, true
+			}
+		}
+	}
+	return 0, 0, false
+}
+
+// Поиск всех корней x0 многочлена f по модулю n с |x0| < bound.
+// Коэффициенты f задаются по возрастанию степени: f[i] - коэффициент при x^i.
+// Если старший коэффициент не обратим по модулю n, возвращается ошибка с найденным делителем n.
+// Долгое LLL-приведение прерывается отменой контекста.
+func SmallRoots(ctx context.Context, f []*big.Int, n, bound *big.Int, params SmallRootsParams) (*SmallRootsResult, error) {
+	// приводим коэффициенты по модулю n и делаем многочлен унитарным
+	poly := make(intPoly, len(f))
+	for i := range f {
+		poly[i] = new(big.Int).Mod(f[i], n)
+	}
+	poly = poly.normalize()
+	d := poly.degree()
+	if d < 1 {
+		return nil, errors.New("степень многочлена по модулю n должна быть не меньше 1")
+	}
+	if bound.Sign() <= 0 {
+		return nil, errors.New("граница корня должна быть положительной")
+	}
+	inv := new(big.Int).ModInverse(poly[d], n)
+	if inv == nil {
+		g := new(big.Int).GCD(nil, nil, poly[d], n)
+		return nil, fmt.Errorf("старший коэффициент многочлена не обратим по модулю n, НОД = %s", g)
+	}
+	for i := range poly {
+		poly[i].Mul(poly[i], inv)
+		poly[i].Mod(poly[i], n)
+	}
+
+	m, t := params.M, params.T
+	if m <= 0 {
+		var ok bool
+		m, t, ok = smallRootsParams(d, n, bound)
+		if !ok {
+			return nil, fmt.Errorf("граница корня %d бит слишком велика для многочлена степени %d по модулю %d бит", bound.BitLen(), d, n.BitLen())
+		}
+	}
+	if t < 0 {
+		t = 0
+	}
+	dim := d*m + t
+	result := &SmallRootsResult{M: m, T: t, Dimension: dim}
+
+	// степени f^0 ... f^m и n^0 ... n^m
+	fPow := make([]intPoly, m+1)
+	fPow[0] = intPoly{big.NewInt(1)}
+	nPow := make([]*big.Int, m+1)
+	nPow[0] = big.NewInt(1)
+	for i := 1; i <= m; i++ {
+		fPow[i] = polyMul(fPow[i-1], poly)
+		nPow[i] = new(big.Int).Mul(nPow[i-1], n)
+	}
+	xPow := make([]*big.Int, dim)
+	xPow[0] = big.NewInt(1)
+	for i := 1; i < dim; i++ {
+		xPow[i] = new(big.Int).Mul(xPow[i-1], bound)
+	}
+
+	// строка k - многочлен степени k: x^j * n^(m-i) * f^i при k = d*i + j < d*m, иначе x^(k-dm) * f^m
+	basis := make([][]*big.Int, dim)
+	for k := 0; k < dim; k++ {
+		i, j := k/d, k%d
+		if i > m {
+			i, j = m, k-d*m
+		}
+		basis[k] = make([]*big.Int, dim)
+		for c := range basis[k] {
+			basis[k][c] = new(big.Int)
+		}
+		for c, coef := range fPow[i] {
+			v := basis[k][c+j]
+			v.Mul(coef, nPow[m-i])
+			v.Mul(v, xPow[c+j])
+		}
+	}
+
+	if err := LLLFloat(ctx, basis, big.NewRat(99, 100)); err != nil {
+		return nil, err
+	}
+
+	// корни коротких векторов проверяем по модулю n: так отсеиваются посторонние корни
+	nmSquared := new(big.Int).Mul(nPow[m], nPow[m])
+	lo := new(big.Int).Neg(bound)
+	seen := make(map[string]bool)
+	for k := range basis {
+		if dot(basis[k], basis[k]).Cmp(nmSquared) >= 0 {
+			continue
+		}
+		// коэффициенты вектора делятся на степени X нацело
+		g := make(intPoly, dim)
+		for c := range g {
+			g[c] = new(big.Int).Quo(basis[k][c], xPow[c])
+		}
+		for _, x := range g.integerRoots(lo, bound) {
+			if seen[x.String()] || x.CmpAbs(bound) >= 0 {
+				continue
+			}
+			if new(big.Int).Mod(poly.eval(x), n).Sign() == 0 {
+				seen[x.String()] = true
+				result.Roots = append(result.Roots, x)
+			}
+		}
+	}
+	sort.Slice(result.Roots, func(i, j int) bool { return result.Roots[i].Cmp(result.Roots[j]) < 0 })
+	return result, nil
+}
@@ -0,0 +1,413 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"sort"
+	"sync"
+)
+
+// Метод эллиптических кривых Ленстры (ECM).
+// Вычисления ведутся на кривой Монтгомери B * y^2 = x^3 + A * x^2 + x по модулю n. По модулю делителя p
+// это настоящая эллиптическая кривая, ее порядок #E(F_p) лежит в [p + 1 - 2 sqrt(p), p + 1 + 2 sqrt(p)]
+// и у разных кривых разный. Если порядок гладкий, то [M] P = O по модулю p, координата Z обращается в 0
+// по модулю p, и p делит НОД(Z, n). В отличие от p - 1 неудачная кривая заменяется другой,
+// поэтому время работы зависит от размера наименьшего делителя p, а не от размера n.
+// Кривые строятся по параметризации Суямы: у них порядок делится на 12, что повышает шанс гладкости.
+// Этап 2 ищет один дополнительный простой делитель r из (B1, B2] порядка точки:
+// r = m * D ± j, и [r] Q = O (mod p) тогда и только тогда, когда x([m * D] Q) = x([j] Q) (mod p).
+
+// шаг этапа 2: 2 * 3 * 5 * 7 * 11, вычетов j <= D/2, взаимно простых с D, - 240
+const ecmD = 2310
+
+// Параметры ECM
+type ECMParams struct {
+	// границы гладкости этапов 1 и 2, при B2 <= B1 этап 2 не выполняется
+	B1, B2 uint64
+	// наибольшее число кривых, 0 - без ограничения, до отмены контекста
+	Curves int
+	// количество горутин, каждая проверяет свои кривые, 0 - по числу ядер
+	Workers int
+	// вызывается после каждой проверенной кривой с общим числом проверенных кривых, может быть nil
+	Progress func(curves int)
+}
+
+// Параметры по умолчанию, подходят для делителей примерно до 30 десятичных знаков
+var DefaultECMParams = ECMParams{B1: 50000, B2: 5000000, Curves: 500}
+
+// Результат ECM
+type ECMResult struct {
+	// множители n, nil - если за отведенное число кривых они не найдены
+	P, Q *big.Int
+	// параметр Суямы кривой и этап, на котором найден множитель
+	Sigma *big.Int
+	Stage int
+	// число проверенных кривых
+	Curves int
+}
+
+// Точка кривой Монтгомери в проективных координатах (X : Z), координата y не нужна
+type ecmPoint struct {
+	X, Z *big.Int
+}
+
+func newECMPoint() ecmPoint {
+	return ecmPoint{new(big.Int), new(big.Int)}
+}
+
+func (p ecmPoint) set(q ecmPoint) {
+	p.X.Set(q.X)
+	p.Z.Set(q.Z)
+}
+
+// Кривая по модулю n, задана величиной a24 = (A + 2) / 4
+type ecmCurve struct {
+	n, a24         *big.Int
+	t1, t2, t3, t4 *big.Int
+}
+
+func newECMCurve(n, a24 *big.Int) *ecmCurve {
+	return &ecmCurve{n: n, a24: a24, t1: new(big.Int), t2: new(big.Int), t3: new(big.Int), t4: new(big.Int)}
+}
+
+func (c *ecmCurve) mulMod(z, x, y *big.Int) {
+	z.Mul(x, y)
+	z.Mod(z, c.n)
+}
+
+// r = [2] p
+func (c *ecmCurve) double(r, p ecmPoint) {
+	// t1 = (X + Z)^2, t2 = (X - Z)^2, t3 = t1 - t2 = 4XZ
+	c.t1.Add(p.X, p.Z)
+	c.mulMod(c.t1, c.t1, c.t1)
+	c.t2.Sub(p.X, p.Z)
+	c.mulMod(c.t2, c.t2, c.t2)
+	c.t3.Sub(c.t1, c.t2)
+	// X' = t1 * t2, Z' = t3 * (t2 + a24 * t3)
+	c.mulMod(r.X, c.t1, c.t2)
+	c.mulMod(c.t4, c.a24, c.t3)
+	c.t4.Add(c.t4, c.t2)
+	c.mulMod(r.Z, c.t3, c.t4)
+}
+
+// r = p + q по известной разности diff = p - q, r не должна совпадать с diff
+func (c *ecmCurve) add(r, p, q, diff ecmPoint) {
+	// u = (Xp - Zp)(Xq + Zq), v = (Xp + Zp)(Xq - Zq)
+	c.t1.Sub(p.X, p.Z)
+	c.t2.Add(q.X, q.Z)
+	c.mulMod(c.t1, c.t1, c.t2)
+	c.t2.Add(p.X, p.Z)
+	c.t3.Sub(q.X, q.Z)
+	c.mulMod(c.t2, c.t2, c.t3)
+	// X' = Zd * (u + v)^2, Z' = Xd * (u - v)^2
+	c.t3.Add(c.t1, c.t2)
+	c.mulMod(c.t3, c.t3, c.t3)
+	c.t4.Sub(c.t1, c.t2)
+	c.mulMod(c.t4, c.t4, c.t4)
+	c.mulMod(r.X, diff.Z, c.t3)
+	c.mulMod(r.Z, diff.X, c.t4)
+}
+
+// [k] p лестницей Монтгомери, k >= 1
+func (c *ecmCurve) multiply(p ecmPoint, k *big.Int) ecmPoint {
+	r0, r1 := newECMPoint(), newECMPoint()
+	r0.set(p)
+	c.double(r1, p)
+	// инвариант: r1 - r0 = p
+	for i := k.BitLen() - 2; i >= 0; i-- {
+		if k.Bit(i) == 1 {
+			c.add(r0, r1, r0, p)
+			c.double(r1, r1)
+		} else {
+			c.add(r1, r1, r0, p)
+			c.double(r0, r0)
+		}
+	}
+	return r0
+}
+
+// Кривая и начальная точка по параметру Суямы sigma:
+// u = sigma^2 - 5, v = 4 * sigma, P = (u^3 : v^3), a24 = (v - u)^3 * (3u + v) / (16 * u^3 * v).
+// Если знаменатель не обратим по модулю n, его НОД с n - уже делитель, он возвращается в factor.
+func suyamaCurve(n, sigma *big.Int) (c *ecmCurve, p ecmPoint, factor *big.Int) {
+	u := new(big.Int).Mul(sigma, sigma)
+	u.Sub(u, big.NewInt(5))
+	u.Mod(u, n)
+	v := new(big.Int).Lsh(sigma, 2)
+	v.Mod(v, n)
+
+	three := big.NewInt(3)
+	p = newECMPoint()
+	p.X.Exp(u, three, n)
+	p.Z.Exp(v, three, n)
+
+	num := new(big.Int).Sub(v, u)
+	num.Exp(num, three, n)
+	t := new(big.Int).Mul(u, three)
+	t.Add(t, v)
+	num.Mul(num, t)
+	num.Mod(num, n)
+
+	den := new(big.Int).Lsh(p.X, 4)
+	den.Mul(den, v)
+	den.Mod(den, n)
+	inv := new(big.Int).ModInverse(den, n)
+	if inv == nil {
+		g, _ := splitGCD(den, n)
+		return nil, p, g
+	}
+	num.Mul(num, inv)
+	num.Mod(num, n)
+	return newECMCurve(n, num), p, nil
+}
+
+// Разложение n методом эллиптических кривых на нескольких горутинах.
+// Если за отведенное число кривых множитель не найден, возвращается результат с P == nil и без ошибки.
+func ECM(ctx context.Context, n *big.Int, params ECMParams) (*ECMResult, error) {
+	if n.Cmp(big.NewInt(4)) < 0 {
+		return nil, errors.New("n должно быть не меньше 4")
+	}
+	if n.ProbablyPrime(20) {
+		return nil, errors.New("n простое, раскладывать нечего")
+	}
+	if params.B1 < 2 {
+		return nil, errors.New("граница B1 должна быть не меньше 2")
+	}
+	if params.Curves < 0 {
+		return nil, errors.New("число кривых не может быть отрицательным")
+	}
+	result := &ECMResult{}
+	if n.Bit(0) == 0 {
+		result.P, result.Q = big.NewInt(2), new(big.Int).Rsh(n, 1)
+		return result, nil
+	}
+	workers := params.Workers
+	if workers < 1 {
+		workers = DefaultJobs()
+	}
+	progress := params.Progress
+	if progress == nil {
+		progress = func(int) {}
+	}
+
+	// первая найденная горутиной пара множителей останавливает остальные
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var mu sync.Mutex
+	started := 0
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for runCtx.Err() == nil {
+				mu.Lock()
+				if params.Curves > 0 && started >= params.Curves {
+					mu.Unlock()
+					return
+				}
+				started++
+				mu.Unlock()
+
+				// sigma из [6, 2^32): при sigma = 0, ±1, ±3, ±5 кривая вырождается
+				sigma, err := rand.Int(rand.Reader, big.NewInt(1<<32-6))
+				if err != nil {
+					return
+				}
+				sigma.Add(sigma, big.NewInt(6))
+				g, stage := ecmCurveFactor(runCtx, n, sigma, params)
+
+				mu.Lock()
+				if runCtx.Err() == nil || g != nil {
+					result.Curves++
+					progress(result.Curves)
+				}
+				if g != nil && result.P == nil {
+					result.P, result.Q = orderedFactors(n, g)
+					result.Sigma, result.Stage = sigma, stage
+					cancel()
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if result.P == nil {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Оба этапа ECM на одной кривой.
+// Возвращает делитель и этап, на котором он найден (0 - при построении кривой), или nil.
+func ecmCurveFactor(ctx context.Context, n, sigma *big.Int, params ECMParams) (*big.Int, int) {
+	c, q, g := suyamaCurve(n, sigma)
+	if c == nil {
+		return g, 0
+	}
+
+	// этап 1: Q = [q^k] Q для всех простых q <= B1, степени пачки перемножаются в один множитель
+	var powers []*big.Int
+	done := false
+	var found *big.Int
+	flush := func() {
+		exponent := big.NewInt(1)
+		for _, pk := range powers {
+			exponent.Mul(exponent, pk)
+		}
+		q = c.multiply(q, exponent)
+		// при НОД = n кривая бесполезна, откат не нужен: следующая кривая будет другой
+		g, all := splitGCD(q.Z, n)
+		found, done = g, g != nil || all
+		powers = powers[:0]
+	}
+	forEachPrime(2, params.B1, func(p uint64) bool {
+		pk := p
+		for pk <= params.B1/p {
+			pk *= p
+		}
+		powers = append(powers, new(big.Int).SetUint64(pk))
+		if len(powers) == pm1Batch {
+			if ctx.Err() != nil {
+				done = true
+				return false
+			}
+			flush()
+		}
+		return !done
+	})
+	if !done && len(powers) > 0 {
+		flush()
+	}
+	if done || params.B2 <= params.B1 {
+		return found, 1
+	}
+
+	// этап 2: малые шаги S_j = [j] Q для нечетных j < D/2, взаимно простых с D
+	baby := make([]ecmPoint, ecmD/2)
+	q2 := newECMPoint()
+	c.double(q2, q)
+	prev, cur := newECMPoint(), newECMPoint()
+	prev.set(q)
+	// cur = [3] Q = [2] Q + Q, разность Q
+	c.add(cur, q2, q, q)
+	baby[1] = q
+	for j := 3; j < ecmD/2; j += 2 {
+		if j > 3 {
+			// [j] Q = [j - 2] Q + [2] Q, разность [j - 4] Q
+			next := newECMPoint()
+			c.add(next, cur, q2, prev)
+			prev, cur = cur, next
+		}
+		if new(big.Int).GCD(nil, nil, big.NewInt(int64(j)), big.NewInt(ecmD)).Cmp(i1) == 0 {
+			p := newECMPoint()
+			p.set(cur)
+			baby[j] = p
+		}
+	}
+
+	// большие шаги R = [m * D] Q, R_{m+1} = R_m + [D] Q по разности R_{m-1}
+	giant := c.multiply(q, big.NewInt(ecmD))
+	m := (params.B1 + 1 + ecmD/2) / ecmD
+	if m < 1 {
+		m = 1
+	}
+	r := c.multiply(q, new(big.Int).SetUint64(m*ecmD))
+	rNext := c.multiply(q, new(big.Int).SetUint64((m+1)*ecmD))
+	step := func() {
+		next := newECMPoint()
+		c.add(next, rNext, giant, r)
+		r, rNext = rNext, next
+		m++
+	}
+
+	// acc = Π (X_R * Z_S - X_S * Z_R) по простым r из (B1, B2]
+	acc := big.NewInt(1)
+	t1, t2 := new(big.Int), new(big.Int)
+	count := 0
+	forEachPrime(params.B1+1, params.B2, func(p uint64) bool {
+		for (p+ecmD/2)/ecmD > m {
+			step()
+		}
+		j := p - m*ecmD
+		if p < m*ecmD {
+			j = m*ecmD - p
+		}
+		// простые делители D и простые меньше D/2 при B1 < D/2 на шаге D не представимы
+		if j >= uint64(len(baby)) || baby[j].X == nil {
+			return true
+		}
+		s := baby[j]
+		c.mulMod(t1, r.X, s.Z)
+		c.mulMod(t2, s.X, r.Z)
+		t1.Sub(t1, t2)
+		c.mulMod(acc, acc, t1)
+		count++
+		if count%pm1Batch == 0 {
+			if ctx.Err() != nil {
+				done = true
+				return false
+			}
+			g, all := splitGCD(acc, n)
+			found, done = g, g != nil || all
+		}
+		return !done
+	})
+	if !done {
+		found, _ = splitGCD(acc, n)
+	}
+	return found, 2
+}
+
+// Результат полного разложения
+type ECMFactorization struct {
+	// найденные простые множители по возрастанию с повторениями
+	Primes []*big.Int
+	// составной делитель, который не удалось разложить за отведенное число кривых, nil - разложение полное
+	Rest *big.Int
+	// общее число проверенных кривых
+	Curves int
+}
+
+// Полное разложение n на простые множители: ECM применяется к составным делителям, пока они не кончатся.
+// Нужно для модулей из нескольких простых, где одного делителя недостаточно для восстановления ключа.
+func ECMFactorize(ctx context.Context, n *big.Int, params ECMParams) (*ECMFactorization, error) {
+	if n.Cmp(i1) <= 0 {
+		return nil, errors.New("n должно быть больше 1")
+	}
+	// Progress получает общее число кривых по всем запускам ECM
+	progress := params.Progress
+	factorization := &ECMFactorization{}
+	composites := []*big.Int{new(big.Int).Set(n)}
+	for len(composites) > 0 {
+		m := composites[len(composites)-1]
+		composites = composites[:len(composites)-1]
+		if m.ProbablyPrime(20) {
+			factorization.Primes = append(factorization.Primes, m)
+			continue
+		}
+		base := factorization.Curves
+		if progress != nil {
+			params.Progress = func(curves int) { progress(base + curves) }
+		}
+		result, err := ECM(ctx, m, params)
+		if err != nil {
+			return nil, err
+		}
+		factorization.Curves += result.Curves
+		if result.P == nil {
+			factorization.Rest = m
+			break
+		}
+		composites = append(composites, result.P, result.Q)
+	}
+	sort.Slice(factorization.Primes, func(i, j int) bool {
+		return factorization.Primes[i].Cmp(factorization.Primes[j]) < 0
+	})
+	return factorization, nil
+}
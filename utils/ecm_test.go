@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// координата x = X / Z точки по простому модулю
+func affineX(p ecmPoint, n *big.Int) *big.Int {
+	x := new(big.Int).ModInverse(p.Z, n)
+	return x.Mul(x, p.X).Mod(x, n)
+}
+
+// Лестница Монтгомери согласована: [a * b] P = [b] ([a] P) и [a + 1] P = [a] P + P
+func TestECMCurveArithmetic(t *testing.T) {
+	random := rand.New(rand.NewSource(4))
+	n := testPrime(t, random, 128)
+	c, p, factor := suyamaCurve(n, big.NewInt(11))
+	if factor != nil || c == nil {
+		t.Fatal("кривая по простому модулю не построена")
+	}
+	for i := 0; i < 20; i++ {
+		a := big.NewInt(2 + random.Int63n(1<<20))
+		b := big.NewInt(2 + random.Int63n(1<<20))
+		ab := new(big.Int).Mul(a, b)
+		if affineX(c.multiply(p, ab), n).Cmp(affineX(c.multiply(c.multiply(p, a), b), n)) != 0 {
+			t.Fatalf("[%s * %s] P != [%s]([%s] P)", a, b, b, a)
+		}
+		pa := c.multiply(p, a)
+		sum := newECMPoint()
+		c.add(sum, pa, p, c.multiply(p, new(big.Int).Sub(a, i1)))
+		if affineX(sum, n).Cmp(affineX(c.multiply(p, new(big.Int).Add(a, i1)), n)) != 0 {
+			t.Fatalf("[%s] P + P != [%s + 1] P", a, a)
+		}
+	}
+}
+
+func TestECM(t *testing.T) {
+	random := rand.New(rand.NewSource(5))
+	ctx := context.Background()
+	params := ECMParams{B1: 2000, B2: 200000, Curves: 500, Workers: 2}
+
+	// делитель из 40 бит у 168-битного n
+	p, q := testPrime(t, random, 40), testPrime(t, random, 128)
+	result, err := ECM(ctx, new(big.Int).Mul(p, q), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.P == nil || result.P.Cmp(p) != 0 || result.Q.Cmp(q) != 0 || result.Curves == 0 || result.Sigma == nil {
+		t.Fatalf("%+v", result)
+	}
+
+	// за одну кривую с B1 = 2 большой n не раскладывается
+	hard := new(big.Int).Mul(testPrime(t, random, 128), q)
+	result, err = ECM(ctx, hard, ECMParams{B1: 2, Curves: 1, Workers: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.P != nil || result.Curves != 1 {
+		t.Fatalf("одна кривая: %+v", result)
+	}
+
+	if _, err := ECM(ctx, q, params); err == nil {
+		t.Fatal("простое n принято")
+	}
+	if _, err := ECM(ctx, hard, ECMParams{B1: 1}); err == nil {
+		t.Fatal("B1 = 1 принято")
+	}
+	if _, err := ECM(ctx, hard, ECMParams{B1: 2000, Curves: -1}); err == nil {
+		t.Fatal("отрицательное число кривых принято")
+	}
+}
+
+// Полное разложение модуля из нескольких простых, в том числе повторяющихся
+func TestECMFactorize(t *testing.T) {
+	random := rand.New(rand.NewSource(6))
+	params := ECMParams{B1: 2000, B2: 200000, Curves: 500, Workers: 2}
+	var primes []*big.Int
+	for i := 0; i < 4; i++ {
+		primes = append(primes, testPrime(t, random, 32))
+	}
+	primes = append(primes, primes[1])
+	n := big.NewInt(1)
+	for _, p := range primes {
+		n.Mul(n, p)
+	}
+
+	var curves int
+	params.Progress = func(c int) { curves = c }
+	factorization, err := ECMFactorize(context.Background(), n, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if factorization.Rest != nil || len(factorization.Primes) != len(primes) {
+		t.Fatalf("%+v", factorization)
+	}
+	product := big.NewInt(1)
+	for i, p := range factorization.Primes {
+		if !p.ProbablyPrime(20) {
+			t.Fatalf("%s не простое", p)
+		}
+		if i > 0 && factorization.Primes[i-1].Cmp(p) > 0 {
+			t.Fatal("простые не упорядочены")
+		}
+		product.Mul(product, p)
+	}
+	if product.Cmp(n) != 0 {
+		t.Fatal("произведение простых не равно n")
+	}
+	if curves != factorization.Curves {
+		t.Fatalf("прогресс %d, всего кривых %d", curves, factorization.Curves)
+	}
+
+	if _, err := ECMFactorize(context.Background(), i1, params); err == nil {
+		t.Fatal("n = 1 принято")
+	}
+}
@@ -0,0 +1,379 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+)
+
+// Конверт для нескольких получателей.
+// Файл шифруется симметрично случайным ключом содержимого (AES-256-CTR),
+// а сам ключ содержимого передается каждому получателю через RSA-KEM (ISO/IEC 18033-2):
+// выбирается случайное z из [0, n), в слот записывается z^e (mod n), а ключ содержимого
+// закрывается ключом, выведенным из z через HKDF-SHA256. Шифруется не сам ключ содержимого,
+// а полноразмерное случайное z, поэтому ни корень степени e при малом e, ни атака Хастада
+// по нескольким слотам с одинаковым e не дают ключа содержимого.
+// Заголовок и зашифрованное содержимое защищены HMAC-SHA256 (схема encrypt-then-MAC),
+// поэтому подмена любого байта или неверный ключ обнаруживаются при расшифровании.
+// Ключи AES и HMAC выводятся из ключа содержимого через HKDF-SHA256 с разными метками.
+//
+// Формат конверта:
+//   - 8 байт сигнатуры "RSAENV01";
+//   - 2 байта - количество получателей (big-endian);
+//   - для каждого получателя: 32 байта отпечатка публичного ключа, 4 байта длины слота
+//     и слот: z^e (mod n) в ceil(bitlen(n) / 8) байт и 32 байта ключа содержимого,
+//     сложенного по модулю 2 с ключом, выведенным из z;
+//   - 16 байт вектора инициализации AES-CTR;
+//   - зашифрованное содержимое;
+//   - 32 байта HMAC-SHA256 от всего, что записано до него.
+
+// длина ключа содержимого в байтах
+const contentKeySize = 32
+
+// максимальный размер слота получателя
+const maxSlotSize = 1 << 20
+
+// сигнатура конверта
+const envelopeMagic = "RSAENV01"
+
+// Длина сигнатуры конверта
+const EnvelopeMagicLen = len(envelopeMagic)
+
+// метки для вывода ключей шифрования, имитовставки и ключа слота получателя
+const (
+	envelopeEncLabel = "RSAENV01 enc"
+	envelopeMacLabel = "RSAENV01 mac"
+	envelopeKEKLabel = "RSAENV01 kek"
+)
+
+// HKDF-SHA256 (RFC 5869): извлечение псевдослучайного ключа из secret и расширение до size байт
+func hkdf(secret, salt, info []byte, size int) []byte {
+	if salt == nil {
+		salt = make([]byte, sha256.Size)
+	}
+	extract := hmac.New(sha256.New, salt)
+	extract.Write(secret)
+	prk := extract.Sum(nil)
+
+	var out, prev []byte
+	expand := hmac.New(sha256.New, prk)
+	for counter := byte(1); len(out) < size; counter++ {
+		expand.Reset()
+		expand.Write(prev)
+		expand.Write(info)
+		expand.Write([]byte{counter})
+		prev = expand.Sum(nil)
+		out = append(out, prev...)
+	}
+	return out[:size]
+}
+
+// вывод ключа из ключа содержимого
+func deriveKey(contentKey []byte, label string) []byte {
+	return hkdf(contentKey, nil, []byte(label), sha256.Size)
+}
+
+// ключ слота получателя из секрета RSA-KEM z, привязанный к отпечатку ключа получателя
+func slotKey(z *big.Int, pubKey *PublicKey) []byte {
+	secret := z.FillBytes(make([]byte, chipherBlockSize(pubKey.N)))
+	info := append([]byte(envelopeKEKLabel), pubKey.Fingerprint()...)
+	return hkdf(secret, nil, info, contentKeySize)
+}
+
+// сложение ключей по модулю 2
+func xorKey(a, b []byte) []byte {
+	out := make([]byte, len(a))
+	for i := range out {
+		out[i] = a[i] ^ b[i]
+	}
+	return out
+}
+
+// Проверка, начинаются ли данные с сигнатуры конверта
+func IsEnvelope(header []byte) bool {
+	return bytes.HasPrefix(header, []byte(envelopeMagic))
+}
+
+// Отпечаток публичного ключа: SHA-256 от его файлового представления "e\nn"
+func (pubKey *PublicKey) Fingerprint() []byte {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s\n%s", pubKey.E, pubKey.N)))
+	return sum[:]
+}
+
+// Секрет RSA-KEM для получателя: случайное z из [0, n)
+func newKEMSecret(pubKey *PublicKey, random io.Reader) (*big.Int, error) {
+	if plainBlockSize(pubKey.N) == 0 {
+		return nil, fmt.Errorf("%w: модуль n слишком мал для шифрования: %d бит", ErrKeyFormat, pubKey.N.BitLen())
+	}
+	return rand.Int(random, pubKey.N)
+}
+
+// Слот получателя: c = z^e (mod n) и ключ содержимого, закрытый ключом из z
+func sealSlot(pubKey *PublicKey, c, z *big.Int, contentKey []byte) []byte {
+	slot := c.FillBytes(make([]byte, chipherBlockSize(pubKey.N)))
+	return append(slot, xorKey(contentKey, slotKey(z, pubKey))...)
+}
+
+// Ключ содержимого из слота получателя: z = c^d (mod n) с ослеплением
+func unwrapContentKey(privKey *PrivateKey, pubKey *PublicKey, slot []byte) ([]byte, error) {
+	size := chipherBlockSize(pubKey.N)
+	if len(slot) != size+contentKeySize {
+		return nil, fmt.Errorf("%w: слот получателя не расшифровывается", ErrAuthentication)
+	}
+	c := new(big.Int).SetBytes(slot[:size])
+	z, err := privKey.Decrypt(c, pubKey)
+	if err != nil {
+		return nil, fmt.Errorf("%w: слот получателя не расшифровывается", ErrAuthentication)
+	}
+	return xorKey(slot[size:], slotKey(z, pubKey)), nil
+}
+
+// Зашифрование конверта для нескольких получателей.
+// Заголовок записывается в w сразу, содержимое шифруется по мере записи.
+// random - источник случайности для ключа содержимого и секретов RSA-KEM, nil - crypto/rand.Reader.
+// Слоты получателей вычисляются параллельно на всех доступных ядрах.
+func NewEnvelopeWriter(w io.Writer, recipients []*PublicKey, random io.Reader) (io.WriteCloser, error) {
+	return NewEnvelopeWriterContext(context.Background(), w, recipients, random, DefaultJobs())
+}
+
+// Зашифрование конверта со слотами получателей, вычисляемыми в jobs горутинах
+// при отмене ctx формирование заголовка и запись содержимого прерываются с ошибкой контекста
+func NewEnvelopeWriterContext(ctx context.Context, w io.Writer, recipients []*PublicKey, random io.Reader, jobs int) (io.WriteCloser, error) {
+	if len(recipients) == 0 {
+		return nil, errors.New("не указан ни один получатель")
+	}
+	if len(recipients) > 0xffff {
+		return nil, fmt.Errorf("слишком много получателей: %d", len(recipients))
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+
+	// генерируем ключ содержимого и вектор инициализации
+	contentKey := make([]byte, contentKeySize)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(random, contentKey); err != nil {
+		return nil, err
+	}
+	if _, err := io.ReadFull(random, iv); err != nil {
+		return nil, err
+	}
+
+	// секреты RSA-KEM генерируем последовательно до запуска горутин,
+	// чтобы источник случайности не читался из нескольких горутин сразу
+	secrets := make([]*big.Int, len(recipients))
+	for i, pubKey := range recipients {
+		z, err := newKEMSecret(pubKey, random)
+		if err != nil {
+			return nil, err
+		}
+		secrets[i] = z
+	}
+	// c = z ^ e (mod n) для каждого получателя
+	encapsulated := make([]*big.Int, len(secrets))
+	copy(encapsulated, secrets)
+	err := processBlocks(ctx, jobs, encapsulated, func(i int, z *big.Int) *big.Int {
+		return recipients[i].expE(z)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// формируем заголовок
+	var header bytes.Buffer
+	header.WriteString(envelopeMagic)
+	binary.Write(&header, binary.BigEndian, uint16(len(recipients)))
+	for i, pubKey := range recipients {
+		slot := sealSlot(pubKey, encapsulated[i], secrets[i], contentKey)
+		header.Write(pubKey.Fingerprint())
+		binary.Write(&header, binary.BigEndian, uint32(len(slot)))
+		header.Write(slot)
+	}
+	header.Write(iv)
+
+	if _, err := w.Write(header.Bytes()); err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(deriveKey(contentKey, envelopeEncLabel))
+	if err != nil {
+		return nil, err
+	}
+
+	// имитовставка вычисляется по всему, что попадает в w, начиная с заголовка
+	mac := hmac.New(sha256.New, deriveKey(contentKey, envelopeMacLabel))
+	mac.Write(header.Bytes())
+	return &envelopeWriter{
+		ctx: ctx,
+		w:   w,
+		mac: mac,
+		sw:  cipher.StreamWriter{S: cipher.NewCTR(block, iv), W: io.MultiWriter(w, mac)},
+	}, nil
+}
+
+// поток зашифрования содержимого конверта
+type envelopeWriter struct {
+	ctx context.Context
+	w   io.Writer
+	mac hash.Hash
+	sw  cipher.StreamWriter
+}
+
+func (ew *envelopeWriter) Write(p []byte) (int, error) {
+	if err := ew.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return ew.sw.Write(p)
+}
+
+// завершение конверта: запись имитовставки
+// нижележащий поток не закрывается
+func (ew *envelopeWriter) Close() error {
+	if err := ew.ctx.Err(); err != nil {
+		return err
+	}
+	_, err := ew.w.Write(ew.mac.Sum(nil))
+	return err
+}
+
+// Расшифрование конверта.
+// Читает заголовок, находит слот получателя по отпечатку pubKey и расшифровывает ключ содержимого privKey.
+// Возвращает поток расшифрованного содержимого.
+// Имитовставка проверяется в конце потока: если она не совпала, последнее чтение вернет ErrAuthentication
+// вместо io.EOF, поэтому все прочитанное до этого момента нельзя считать проверенным.
+func NewEnvelopeReader(r io.Reader, privKey *PrivateKey, pubKey *PublicKey) (io.Reader, error) {
+	return NewEnvelopeReaderContext(context.Background(), r, privKey, pubKey)
+}
+
+// Расшифрование конверта с прерыванием чтения содержимого при отмене ctx
+func NewEnvelopeReaderContext(ctx context.Context, r io.Reader, privKey *PrivateKey, pubKey *PublicKey) (io.Reader, error) {
+	// запоминаем заголовок целиком, он тоже входит в имитовставку
+	var header bytes.Buffer
+	br := bufio.NewReader(r)
+	r = io.TeeReader(br, &header)
+
+	// проверяем сигнатуру
+	magic := make([]byte, EnvelopeMagicLen)
+	if _, err := io.ReadFull(r, magic); err != nil || !IsEnvelope(magic) {
+		return nil, fmt.Errorf("%w: отсутствует сигнатура конверта", ErrMalformedCiphertext)
+	}
+
+	var count uint16
+	if err := binary.Read(r, binary.BigEndian, &count); err != nil {
+		return nil, fmt.Errorf("%w: заголовок конверта обрывается", ErrMalformedCiphertext)
+	}
+
+	// перебираем всех получателей, слот нужно найти, а остальные - пропустить
+	fingerprint := pubKey.Fingerprint()
+	var wrapped []byte
+	for i := 0; i < int(count); i++ {
+		slotFingerprint := make([]byte, sha256.Size)
+		var size uint32
+		if _, err := io.ReadFull(r, slotFingerprint); err != nil {
+			return nil, fmt.Errorf("%w: заголовок конверта обрывается", ErrMalformedCiphertext)
+		}
+		if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+			return nil, fmt.Errorf("%w: заголовок конверта обрывается", ErrMalformedCiphertext)
+		}
+		// защищаемся от выделения памяти под заведомо неверную длину
+		if size > maxSlotSize {
+			return nil, fmt.Errorf("%w: слишком большой слот получателя %d", ErrMalformedCiphertext, i)
+		}
+		slot := make([]byte, size)
+		if _, err := io.ReadFull(r, slot); err != nil {
+			return nil, fmt.Errorf("%w: заголовок конверта обрывается", ErrMalformedCiphertext)
+		}
+		if wrapped == nil && bytes.Equal(slotFingerprint, fingerprint) {
+			wrapped = slot
+		}
+	}
+
+	iv := make([]byte, aes.BlockSize)
+	if _, err := io.ReadFull(r, iv); err != nil {
+		return nil, fmt.Errorf("%w: заголовок конверта обрывается", ErrMalformedCiphertext)
+	}
+
+	if wrapped == nil {
+		return nil, ErrNotRecipient
+	}
+
+	// расшифровываем ключ содержимого своим приватным ключом
+	// несоответствие ключей обнаруживается сразу, а испорченный слот - как неверная имитовставка
+	if err := privKey.Check(pubKey); err != nil {
+		return nil, err
+	}
+	contentKey, err := unwrapContentKey(privKey, pubKey, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(deriveKey(contentKey, envelopeEncLabel))
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, deriveKey(contentKey, envelopeMacLabel))
+	mac.Write(header.Bytes())
+
+	return &envelopeReader{
+		ctx:    ctx,
+		r:      br,
+		mac:    mac,
+		stream: cipher.NewCTR(block, iv),
+	}, nil
+}
+
+// поток расшифрования содержимого конверта
+// последние 32 байта потока всегда удерживаются, пока не станет ясно, что это имитовставка
+type envelopeReader struct {
+	ctx    context.Context
+	r      *bufio.Reader
+	mac    hash.Hash
+	stream cipher.Stream
+	err    error
+}
+
+func (er *envelopeReader) Read(p []byte) (int, error) {
+	if er.err != nil {
+		return 0, er.err
+	}
+	if err := er.ctx.Err(); err != nil {
+		return 0, err
+	}
+
+	// смотрим, сколько байт доступно сверх имитовставки
+	ahead, err := er.r.Peek(sha256.Size + 1)
+	if len(ahead) <= sha256.Size {
+		if err != io.EOF {
+			er.err = err
+			return 0, err
+		}
+		// остались только последние байты - сверяем имитовставку
+		if len(ahead) != sha256.Size || !hmac.Equal(ahead, er.mac.Sum(nil)) {
+			er.err = ErrAuthentication
+		} else {
+			er.err = io.EOF
+		}
+		return 0, er.err
+	}
+
+	// отдаем не больше, чем гарантированно не относится к имитовставке
+	n := er.r.Buffered() - sha256.Size
+	if n > len(p) {
+		n = len(p)
+	}
+	n, _ = er.r.Read(p[:n])
+	er.mac.Write(p[:n])
+	er.stream.XORKeyStream(p[:n], p[:n])
+	return n, nil
+}
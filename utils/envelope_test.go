@@ -0,0 +1,145 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"math/big"
+	"testing"
+)
+
+// Конверт для трех получателей с e = 3 расшифровывается каждым из них,
+// а подмена любого байта дает ErrAuthentication
+func TestEnvelopeRoundTrip(t *testing.T) {
+	plain := randomBytes(t, 10000)
+	var pubKeys []*PublicKey
+	var privKeys []*PrivateKey
+	for i := 0; i < 3; i++ {
+		pubKey, privKey := testKeyPairE(t, 1024, 3)
+		pubKeys = append(pubKeys, pubKey)
+		privKeys = append(privKeys, privKey)
+	}
+
+	var envelope bytes.Buffer
+	ew, err := NewEnvelopeWriter(&envelope, pubKeys, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ew.Write(plain); err != nil {
+		t.Fatal(err)
+	}
+	if err := ew.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	open := func(data []byte, i int) ([]byte, error) {
+		r, err := NewEnvelopeReader(bytes.NewReader(data), privKeys[i], pubKeys[i])
+		if err != nil {
+			return nil, err
+		}
+		return io.ReadAll(r)
+	}
+	for i := range pubKeys {
+		got, err := open(envelope.Bytes(), i)
+		if err != nil {
+			t.Fatalf("получатель %d: %s", i, err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Fatalf("получатель %d: содержимое не совпадает", i)
+		}
+	}
+
+	// чужой ключ
+	other, otherPriv := testKeyPairE(t, 1024, 3)
+	if _, err := NewEnvelopeReader(bytes.NewReader(envelope.Bytes()), otherPriv, other); !errors.Is(err, ErrNotRecipient) {
+		t.Fatalf("чужой ключ: ошибка %v, ожидалась ErrNotRecipient", err)
+	}
+
+	// подмена байта в слоте первого получателя, в содержимом и в имитовставке
+	slot := EnvelopeMagicLen + 2 + 32 + 4
+	for _, pos := range []int{slot, slot + chipherBlockSize(pubKeys[0].N) + 1, envelope.Len() - 1000, envelope.Len() - 1} {
+		data := append([]byte(nil), envelope.Bytes()...)
+		data[pos] ^= 1
+		if _, err := open(data, 0); !errors.Is(err, ErrAuthentication) {
+			t.Fatalf("подмена байта %d: ошибка %v, ожидалась ErrAuthentication", pos, err)
+		}
+	}
+}
+
+// целый кубический корень двоичным поиском и признак точного куба
+func cubeRoot(x *big.Int) (*big.Int, bool) {
+	lo, hi := new(big.Int), new(big.Int).Lsh(i1, uint(x.BitLen()/3+1))
+	mid, cube := new(big.Int), new(big.Int)
+	for lo.Cmp(hi) < 0 {
+		mid.Add(lo, hi).Add(mid, i1).Rsh(mid, 1)
+		if cube.Exp(mid, big.NewInt(3), nil).Cmp(x) > 0 {
+			hi.Sub(mid, i1)
+		} else {
+			lo.Set(mid)
+		}
+	}
+	return lo, cube.Exp(lo, big.NewInt(3), nil).Cmp(x) == 0
+}
+
+// Слоты получателей с e = 3 не раскрываются ни кубическим корнем, ни атакой Хастада:
+// в них зашифровано полноразмерное случайное z, а не ключ содержимого
+func TestEnvelopeSlotsResistSmallExponent(t *testing.T) {
+	var pubKeys []*PublicKey
+	for i := 0; i < 3; i++ {
+		pubKey, _ := testKeyPairE(t, 1024, 3)
+		pubKeys = append(pubKeys, pubKey)
+	}
+	contentKey := randomBytes(t, contentKeySize)
+
+	// x = c_i (mod n_i) по китайской теореме об остатках и его точный кубический корень
+	product := big.NewInt(1)
+	for _, pubKey := range pubKeys {
+		product.Mul(product, pubKey.N)
+	}
+	hastad := func(residues []*big.Int) (*big.Int, bool) {
+		x := new(big.Int)
+		for i, pubKey := range pubKeys {
+			rest := new(big.Int).Quo(product, pubKey.N)
+			term := new(big.Int).ModInverse(rest, pubKey.N)
+			term.Mul(term, residues[i]).Mul(term, rest)
+			x.Add(x, term)
+		}
+		return cubeRoot(x.Mod(x, product))
+	}
+
+	slots := make([]*big.Int, len(pubKeys))
+	direct := make([]*big.Int, len(pubKeys))
+	m := new(big.Int).SetBytes(contentKey)
+	for i, pubKey := range pubKeys {
+		z, err := newKEMSecret(pubKey, rand.Reader)
+		if err != nil {
+			t.Fatal(err)
+		}
+		slot := sealSlot(pubKey, pubKey.expE(z), z, contentKey)
+		slots[i] = new(big.Int).SetBytes(slot[:chipherBlockSize(pubKey.N)])
+		if _, exact := cubeRoot(slots[i]); exact {
+			t.Fatalf("слот %d - точный куб", i)
+		}
+		direct[i] = pubKey.expE(m)
+	}
+	if _, exact := hastad(slots); exact {
+		t.Fatal("атака Хастада восстановила секрет слотов")
+	}
+	// проверка самого приема: ключ содержимого, зашифрованный напрямую, восстанавливается
+	if root, exact := hastad(direct); !exact || root.Cmp(m) != 0 {
+		t.Fatal("атака Хастада не восстановила ключ, зашифрованный без RSA-KEM")
+	}
+}
+
+// HKDF-SHA256 на примере A.1 из RFC 5869
+func TestHKDF(t *testing.T) {
+	secret := bytes.Repeat([]byte{0x0b}, 22)
+	salt, _ := hex.DecodeString("000102030405060708090a0b0c")
+	info, _ := hex.DecodeString("f0f1f2f3f4f5f6f7f8f9")
+	want := "3cb25f25faacd57a90434f64d0362f2a2d2d0a90cf1a5a4c5db02d56ecc4c5bf34007208d5b887185865"
+	if got := hex.EncodeToString(hkdf(secret, salt, info, 42)); got != want {
+		t.Fatalf("hkdf = %s, ожидалось %s", got, want)
+	}
+}
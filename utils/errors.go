@@ -0,0 +1,43 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Ошибки пакета.
+// Конкретная причина добавляется к ним через fmt.Errorf("%w: ..."),
+// поэтому проверять их нужно через errors.Is.
+var (
+	// шифр поврежден: неверная длина, блок вне диапазона, неверная длина открытого текста
+	ErrMalformedCiphertext = errors.New("неверный формат шифра")
+	// приватный ключ не соответствует публичному
+	ErrKeyMismatch = errors.New("приватный ключ не соответствует публичному")
+	// ключ не удалось разобрать или он непригоден для работы
+	ErrKeyFormat = errors.New("неверный формат ключа")
+	// сообщение не помещается в блок, значение должно быть меньше n
+	ErrMessageTooLong = errors.New("сообщение слишком длинное для ключа")
+	// среди получателей конверта нет владельца указанного ключа
+	ErrNotRecipient = errors.New("файл зашифрован не для этого ключа")
+	// имитовставка конверта не совпала: неверный ключ или шифр подделан
+	ErrAuthentication = errors.New("неверный ключ или шифр поврежден (подделан)")
+	// подпись не соответствует сообщению и публичному ключу
+	ErrVerification = errors.New("подпись неверна")
+	// векторы базиса решетки линейно зависимы
+	ErrLinearlyDependent = errors.New("векторы базиса линейно зависимы")
+)
+
+// Ошибка в конкретном блоке шифра
+type BlockError struct {
+	// номер блока, начиная с 0
+	Index int
+	Err   error
+}
+
+func (e *BlockError) Error() string {
+	return fmt.Sprintf("блок %d: %s", e.Index, e.Err)
+}
+
+func (e *BlockError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,81 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"math/big"
+)
+
+// Метод факторизации Ферма.
+// Нечетное n = p * q представляется разностью квадратов n = a^2 - b^2, a = (p + q) / 2, b = (q - p) / 2.
+// Перебираем a от ceil(sqrt(n)), пока a^2 - n не станет точным квадратом b^2, тогда p = a - b, q = a + b.
+// Число итераций около (q - p)^2 / (8 * sqrt(n)): при |p - q| < n^(1/4) хватает одной итерации,
+// поэтому для стойкого ключа |p - q| должно быть намного больше n^(1/4), а не только больше нуля.
+
+// Количество итераций по умолчанию
+const DefaultFermatIterations = 1 << 24
+
+// как часто проверять отмену контекста
+const fermatCtxCheck = 1 << 12
+
+// Результат метода Ферма
+type FermatResult struct {
+	// множители n, p <= q, nil - если за отведенное число итераций они не найдены
+	P, Q *big.Int
+	// выполненное число итераций
+	Iterations int64
+}
+
+// Разложение n методом Ферма не более чем за maxIterations итераций.
+// Если множители не найдены, возвращается результат с P == nil и без ошибки.
+func FermatFactor(ctx context.Context, n *big.Int, maxIterations int64) (*FermatResult, error) {
+	if n.Cmp(big.NewInt(4)) < 0 {
+		return nil, errors.New("n должно быть не меньше 4")
+	}
+	result := &FermatResult{}
+
+	// четное n раскладывается сразу
+	if n.Bit(0) == 0 {
+		result.P, result.Q = big.NewInt(2), new(big.Int).Rsh(n, 1)
+		return result, nil
+	}
+
+	a := isqrt(n)
+	// r = a^2 - n
+	r := new(big.Int).Mul(a, a)
+	r.Sub(r, n)
+	if r.Sign() == 0 {
+		result.P, result.Q = a, new(big.Int).Set(a)
+		return result, nil
+	}
+	// начинаем с ceil(sqrt(n)): r = r + 2a + 1, a = a + 1
+	step := new(big.Int)
+	next := func() {
+		step.Lsh(a, 1)
+		step.Add(step, i1)
+		r.Add(r, step)
+		a.Add(a, i1)
+	}
+	next()
+
+	for result.Iterations < maxIterations {
+		if result.Iterations%fermatCtxCheck == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+		result.Iterations++
+
+		if b, ok := perfectSquareRoot(r); ok {
+			p := new(big.Int).Sub(a, b)
+			// p = 1 означает, что n простое: дальше перебирать бессмысленно
+			if p.Cmp(i1) == 0 {
+				return result, nil
+			}
+			result.P, result.Q = p, new(big.Int).Add(a, b)
+			return result, nil
+		}
+		next()
+	}
+	return result, nil
+}
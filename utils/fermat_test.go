@@ -0,0 +1,128 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+// Целый корень и признак точного квадрата на краях: 0, 1, k^2 - 1, k^2, k^2 + 1 и большие числа
+func TestIsqrt(t *testing.T) {
+	for _, n := range []int64{0, 1, 2, 3, 4, 15, 16, 17, 99, 100, 101, 1 << 40, 1<<40 + 1, 1<<62 - 1} {
+		root := isqrt(big.NewInt(n))
+		r := root.Int64()
+		if r*r > n || (r+1)*(r+1) <= n {
+			t.Fatalf("isqrt(%d) = %d", n, r)
+		}
+		sq, ok := perfectSquareRoot(big.NewInt(n))
+		if ok != (r*r == n) || (ok && sq.Int64() != r) {
+			t.Fatalf("perfectSquareRoot(%d) = %v, %v", n, sq, ok)
+		}
+	}
+	if isqrt(big.NewInt(-4)).Sign() != 0 {
+		t.Fatal("isqrt отрицательного числа не 0")
+	}
+	if _, ok := perfectSquareRoot(big.NewInt(-4)); ok {
+		t.Fatal("отрицательное число - точный квадрат")
+	}
+
+	for i := 0; i < 100; i++ {
+		x, err := rand.Int(rand.Reader, new(big.Int).Lsh(i1, 1024))
+		if err != nil {
+			t.Fatal(err)
+		}
+		square := new(big.Int).Mul(x, x)
+		if root := isqrt(square); root.Cmp(x) != 0 {
+			t.Fatalf("isqrt(x^2) = %s, ожидалось %s", root, x)
+		}
+		if root, ok := perfectSquareRoot(square); !ok || root.Cmp(x) != 0 {
+			t.Fatalf("perfectSquareRoot(x^2) = %v, %v", root, ok)
+		}
+		if x.Sign() == 0 {
+			continue
+		}
+		square.Sub(square, i1)
+		if root := isqrt(square); new(big.Int).Add(root, i1).Cmp(x) != 0 {
+			t.Fatalf("isqrt(x^2 - 1) = %s, ожидалось %s - 1", root, x)
+		}
+		if _, ok := perfectSquareRoot(square); ok && x.Cmp(i1) != 0 {
+			t.Fatal("x^2 - 1 - точный квадрат")
+		}
+	}
+}
+
+// следующее простое после x
+func nextPrime(x *big.Int) *big.Int {
+	p := new(big.Int).Add(x, i1)
+	for !p.ProbablyPrime(20) {
+		p.Add(p, i1)
+	}
+	return p
+}
+
+func TestFermatFactor(t *testing.T) {
+	ctx := context.Background()
+
+	// близкие простые по 512 бит раскладываются за одну итерацию
+	base, err := rand.Prime(rand.Reader, 512)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := nextPrime(base)
+	q := nextPrime(p)
+	result, err := FermatFactor(ctx, new(big.Int).Mul(p, q), DefaultFermatIterations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.P == nil || result.P.Cmp(p) != 0 || result.Q.Cmp(q) != 0 || result.Iterations != 1 {
+		t.Fatalf("близкие простые: %+v", result)
+	}
+
+	// n = p^2
+	result, err = FermatFactor(ctx, new(big.Int).Mul(p, p), DefaultFermatIterations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.P == nil || result.P.Cmp(p) != 0 || result.Q.Cmp(p) != 0 || result.Iterations != 0 {
+		t.Fatalf("квадрат простого: %+v", result)
+	}
+
+	// простое n: перебор доходит до n = 1 * n и останавливается без множителей
+	result, err = FermatFactor(ctx, big.NewInt(10007), DefaultFermatIterations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.P != nil || result.Iterations == DefaultFermatIterations {
+		t.Fatalf("простое n: %+v", result)
+	}
+
+	// далекие простые не находятся за 1000 итераций
+	small, err := rand.Prime(rand.Reader, 200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = FermatFactor(ctx, new(big.Int).Mul(small, q), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.P != nil || result.Iterations != 1000 {
+		t.Fatalf("исчерпанный бюджет: %+v", result)
+	}
+
+	// четное n и слишком малое n
+	result, err = FermatFactor(ctx, big.NewInt(2*10007), 1)
+	if err != nil || result.P.Int64() != 2 || result.Q.Int64() != 10007 {
+		t.Fatalf("четное n: %+v, %v", result, err)
+	}
+	if _, err := FermatFactor(ctx, big.NewInt(3), 1); err == nil {
+		t.Fatal("n = 3 принято")
+	}
+
+	// отмененный контекст
+	cancelled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := FermatFactor(cancelled, new(big.Int).Mul(small, q), 1000); err == nil {
+		t.Fatal("отмененный контекст не остановил перебор")
+	}
+}
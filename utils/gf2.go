@@ -0,0 +1,60 @@
+package utils
+
+import "math/bits"
+
+// Линейная алгебра над GF(2) для методов факторизации с базой множителей (квадратичное решето).
+
+// Поиск линейных зависимостей строк матрицы над GF(2) методом Гаусса.
+// rows[i] - номера столбцов с единицами в строке i (повторяющиеся номера сокращаются по модулю 2),
+// cols - число столбцов. Возвращает наборы номеров строк, сумма которых по модулю 2 равна нулю.
+func gf2Dependencies(rows [][]int, cols int) [][]int {
+	n := len(rows)
+	// строка матрицы: words слов данных и hist слов истории - какие исходные строки в нее сложены
+	words, hist := (cols+63)/64, (n+63)/64
+	mat := make([][]uint64, n)
+	for i, row := range rows {
+		mat[i] = make([]uint64, words+hist)
+		for _, c := range row {
+			mat[i][c/64] ^= 1 << (c % 64)
+		}
+		mat[i][words+i/64] |= 1 << (i % 64)
+	}
+
+	pivot := 0
+	for c := 0; c < cols && pivot < n; c++ {
+		w, bit := c/64, uint64(1)<<(c%64)
+		r := pivot
+		for r < n && mat[r][w]&bit == 0 {
+			r++
+		}
+		if r == n {
+			continue
+		}
+		mat[pivot], mat[r] = mat[r], mat[pivot]
+		// у строк ниже опорной единицы левее столбца c уже исключены, поэтому складываем начиная со слова w
+		pr := mat[pivot]
+		for r := pivot + 1; r < n; r++ {
+			row := mat[r]
+			if row[w]&bit == 0 {
+				continue
+			}
+			for k := w; k < len(row); k++ {
+				row[k] ^= pr[k]
+			}
+		}
+		pivot++
+	}
+
+	// строки ниже последней опорной нулевые, их история - зависимость
+	var deps [][]int
+	for _, row := range mat[pivot:] {
+		var dep []int
+		for k, word := range row[words:] {
+			for ; word != 0; word &= word - 1 {
+				dep = append(dep, k*64+bits.TrailingZeros64(word))
+			}
+		}
+		deps = append(deps, dep)
+	}
+	return deps
+}
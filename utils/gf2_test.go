@@ -0,0 +1,82 @@
+package utils
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+	"testing"
+)
+
+// сумма строк dep по модулю 2 равна нулю
+func zeroSum(rows [][]int, dep []int) bool {
+	parity := make(map[int]int)
+	for _, i := range dep {
+		for _, c := range rows[i] {
+			parity[c] ^= 1
+		}
+	}
+	for _, p := range parity {
+		if p != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Зависимости небольшой матрицы с известным ядром
+func TestGF2DependenciesSmall(t *testing.T) {
+	rows := [][]int{
+		{0, 1},
+		{1, 2},
+		{0, 2},
+		// повторяющийся столбец сокращается: строка равна {3}
+		{3, 1, 1},
+		{3},
+	}
+	deps := gf2Dependencies(rows, 4)
+	if len(deps) != 2 {
+		t.Fatalf("%d зависимостей, ожидалось 2: %v", len(deps), deps)
+	}
+	var got []string
+	for _, dep := range deps {
+		if !zeroSum(rows, dep) {
+			t.Fatalf("зависимость %v не дает нуля", dep)
+		}
+		sort.Ints(dep)
+		got = append(got, fmt.Sprint(dep))
+	}
+	sort.Strings(got)
+	if got[0] != "[0 1 2]" || got[1] != "[3 4]" {
+		t.Fatalf("зависимости %v, ожидалось [0 1 2] и [3 4]", got)
+	}
+
+	// независимые строки не дают зависимостей
+	if deps := gf2Dependencies([][]int{{0}, {1}, {0, 1, 2}}, 3); len(deps) != 0 {
+		t.Fatalf("лишние зависимости %v", deps)
+	}
+}
+
+// Разреженная случайная матрица с числом строк больше числа столбцов, как в решете
+func TestGF2DependenciesRandom(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	for _, size := range []struct{ rows, cols int }{{70, 64}, {200, 130}, {520, 500}} {
+		rows := make([][]int, size.rows)
+		for i := range rows {
+			for k := 0; k < 1+random.Intn(12); k++ {
+				rows[i] = append(rows[i], random.Intn(size.cols))
+			}
+		}
+		deps := gf2Dependencies(rows, size.cols)
+		if len(deps) < size.rows-size.cols {
+			t.Fatalf("%dx%d: %d зависимостей, ожидалось не меньше %d", size.rows, size.cols, len(deps), size.rows-size.cols)
+		}
+		for _, dep := range deps {
+			if len(dep) == 0 {
+				t.Fatalf("%dx%d: пустая зависимость", size.rows, size.cols)
+			}
+			if !zeroSum(rows, dep) {
+				t.Fatalf("%dx%d: зависимость %v не дает нуля", size.rows, size.cols, dep)
+			}
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Широковещательная атака Хастада.
+// Если одно сообщение m без дополнения зашифровано k ключами с одинаковым e и попарно взаимно простыми n_i,
+// то по китайской теореме об остатках из c_i = m^e (mod n_i) получается m^e (mod n_1 * ... * n_k).
+// При k >= e выполняется m^e < n_1 * ... * n_k, поэтому это число равно m^e в целых числах,
+// и m - точный целый корень степени e. Короткие сообщения восстанавливаются и при меньшем k.
+
+// наибольшая степень e, для которой вычисляется корень
+const maxHastadExponent = 1 << 16
+
+// Восстановление открытого текста по шифрам в поблочном формате (ShipherBytes, EncryptWriter)
+// одного сообщения, зашифрованного ключами pubKeys; chiphers[i] зашифрован ключом pubKeys[i].
+// Ключи должны иметь одинаковые e и размер блока, чтобы сообщение разбивалось на блоки одинаково.
+func HastadAttack(pubKeys []*PublicKey, chiphers [][]byte) ([]byte, error) {
+	if len(pubKeys) < 2 || len(pubKeys) != len(chiphers) {
+		return nil, errors.New("нужно не меньше двух ключей и по одному шифру на каждый ключ")
+	}
+	e := pubKeys[0].E
+	if e.Cmp(i2) < 0 {
+		return nil, fmt.Errorf("%w: e = %s, для атаки нужно e >= 2", ErrKeyFormat, e)
+	}
+	if !e.IsInt64() || e.Int64() > maxHastadExponent {
+		return nil, fmt.Errorf("e = %s слишком велико для атаки, поддерживается e <= %d", e, maxHastadExponent)
+	}
+	plainSize := plainBlockSize(pubKeys[0].N)
+	moduli := make([]*big.Int, len(pubKeys))
+	for i, pubKey := range pubKeys {
+		if pubKey.E.Cmp(e) != 0 {
+			return nil, fmt.Errorf("у ключа %d e = %s, а у первого e = %s: показатели должны совпадать", i+1, pubKey.E, e)
+		}
+		if plainBlockSize(pubKey.N) != plainSize {
+			return nil, fmt.Errorf("размер блока ключа %d отличается от первого: блоки шифров не соответствуют друг другу", i+1)
+		}
+		moduli[i] = pubKey.N
+	}
+
+	// блоки всех шифров: blocks[i][j] - j-й блок i-го шифра
+	blocks := make([][]*big.Int, len(chiphers))
+	var total uint64
+	for i, chipher := range chiphers {
+		b, t, err := parseBlocks(chipher, moduli[i])
+		if err != nil {
+			return nil, fmt.Errorf("шифр %d: %w", i+1, err)
+		}
+		if i > 0 && t != total {
+			return nil, fmt.Errorf("длины открытых текстов шифров различаются: %d и %d байт", total, t)
+		}
+		blocks[i], total = b, t
+	}
+
+	k := int(e.Int64())
+	plain := make([]*big.Int, len(blocks[0]))
+	residues := make([]*big.Int, len(blocks))
+	for j := range plain {
+		for i := range blocks {
+			residues[i] = blocks[i][j]
+		}
+		// x = m^e (mod Π n_i)
+		x, _, err := crt(residues, moduli)
+		if err != nil {
+			return nil, err
+		}
+		m, exact := iroot(x, k)
+		if !exact {
+			return nil, &BlockError{Index: j, Err: fmt.Errorf("m^e больше произведения модулей, нужно не меньше e = %d шифров", k)}
+		}
+		// проверяем, что шифры действительно от одного сообщения
+		for i, pubKey := range pubKeys {
+			if pubKey.expE(m).Cmp(blocks[i][j]) != 0 {
+				return nil, &BlockError{Index: j, Err: errors.New("шифры получены из разных сообщений")}
+			}
+		}
+		plain[j] = m
+	}
+	return joinBlocks(plain, moduli[0], total)
+}
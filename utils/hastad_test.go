@@ -0,0 +1,162 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// Целый корень: точные степени, соседние с ними числа и k < 1 без паники
+func TestIroot(t *testing.T) {
+	cases := []struct {
+		n     int64
+		k     int
+		root  int64
+		exact bool
+	}{
+		{0, 3, 0, true},
+		{1, 3, 1, true},
+		{-8, 3, 0, false},
+		{26, 3, 2, false},
+		{27, 3, 3, true},
+		{28, 3, 3, false},
+		{1 << 40, 2, 1 << 20, true},
+		{1<<40 - 1, 2, 1<<20 - 1, false},
+		{7, 1, 7, true},
+		{8, 0, 0, false},
+		{8, -3, 0, false},
+	}
+	for _, c := range cases {
+		root, exact := iroot(big.NewInt(c.n), c.k)
+		if root.Cmp(big.NewInt(c.root)) != 0 || exact != c.exact {
+			t.Errorf("iroot(%d, %d) = %s, %t, ожидалось %d, %t", c.n, c.k, root, exact, c.root, c.exact)
+		}
+	}
+
+	for _, k := range []int{2, 3, 5, 17} {
+		for i := 0; i < 10; i++ {
+			x, err := rand.Int(rand.Reader, new(big.Int).Lsh(i1, 300))
+			if err != nil {
+				t.Fatal(err)
+			}
+			x.Add(x, i2)
+			n := new(big.Int).Exp(x, big.NewInt(int64(k)), nil)
+			if root, exact := iroot(n, k); root.Cmp(x) != 0 || !exact {
+				t.Fatalf("корень степени %d из x^%d: %s, %t", k, k, root, exact)
+			}
+			// x^k - 1 лежит между (x - 1)^k и x^k
+			n.Sub(n, i1)
+			if root, exact := iroot(n, k); root.Cmp(new(big.Int).Sub(x, i1)) != 0 || exact {
+				t.Fatalf("корень степени %d из x^%d - 1: %s, %t", k, k, root, exact)
+			}
+		}
+	}
+}
+
+// Китайская теорема об остатках на взаимно простых модулях и отказ на общих множителях
+func TestCRT(t *testing.T) {
+	moduli := []*big.Int{big.NewInt(7), big.NewInt(11), big.NewInt(13)}
+	for v := int64(0); v < 7*11*13; v += 37 {
+		residues := []*big.Int{big.NewInt(v % 7), big.NewInt(v%11 + 11), big.NewInt(v%13 - 13)}
+		x, m, err := crt(residues, moduli)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if x.Int64() != v || m.Int64() != 7*11*13 {
+			t.Fatalf("crt для %d: x = %s, M = %s", v, x, m)
+		}
+	}
+
+	pubKeys := make([]*big.Int, 3)
+	residues := make([]*big.Int, 3)
+	for i := range pubKeys {
+		pubKey, _ := testKeyPair(t, 256)
+		pubKeys[i] = pubKey.N
+		residues[i] = randomBelow(t, pubKey.N)
+	}
+	x, m, err := crt(residues, pubKeys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range pubKeys {
+		if new(big.Int).Mod(x, pubKeys[i]).Cmp(residues[i]) != 0 {
+			t.Fatalf("x не сравнимо с остатком %d", i)
+		}
+	}
+	if x.Cmp(m) >= 0 || x.Sign() < 0 {
+		t.Fatal("x вне [0, M)")
+	}
+
+	if _, _, err := crt([]*big.Int{i1, i2}, []*big.Int{big.NewInt(6), big.NewInt(9)}); err == nil {
+		t.Fatal("модули 6 и 9 приняты как взаимно простые")
+	}
+	if _, _, err := crt([]*big.Int{i1}, []*big.Int{big.NewInt(6), big.NewInt(9)}); err == nil {
+		t.Fatal("разное количество остатков и модулей принято")
+	}
+}
+
+// Сообщение, зашифрованное тремя ключами с e = 3, восстанавливается, а двух шифров полноразмерного блока мало
+func TestHastadAttack(t *testing.T) {
+	var pubKeys []*PublicKey
+	for i := 0; i < 3; i++ {
+		pubKey, _ := testKeyPairE(t, 512, 3)
+		pubKeys = append(pubKeys, pubKey)
+	}
+	plain := randomBytes(t, 2*plainBlockSize(pubKeys[0].N)+7)
+	chiphers := make([][]byte, len(pubKeys))
+	for i, pubKey := range pubKeys {
+		chiphers[i] = encryptBlocks(t, pubKey, plain)
+	}
+
+	got, err := HastadAttack(pubKeys, chiphers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(got, plain) {
+		t.Fatal("восстановлен не тот текст")
+	}
+
+	// m^3 полноразмерного блока больше произведения двух модулей
+	var blockErr *BlockError
+	if _, err := HastadAttack(pubKeys[:2], chiphers[:2]); !errors.As(err, &blockErr) {
+		t.Fatalf("два шифра: ошибка %v, ожидалась ошибка блока", err)
+	}
+
+	// короткое сообщение восстанавливается и по двум шифрам
+	short := []byte("короткое сообщение")
+	got, err = HastadAttack(pubKeys[:2], [][]byte{encryptBlocks(t, pubKeys[0], short), encryptBlocks(t, pubKeys[1], short)})
+	if err != nil || !bytes.Equal(got, short) {
+		t.Fatalf("короткое сообщение по двум шифрам: %q, %v", got, err)
+	}
+
+	// шифры разных сообщений одной длины: по КТО получается не куб
+	other := append([]byte(nil), plain...)
+	other[0] ^= 1
+	mixed := [][]byte{chiphers[0], chiphers[1], encryptBlocks(t, pubKeys[2], other)}
+	if _, err := HastadAttack(pubKeys, mixed); !errors.As(err, &blockErr) || blockErr.Index != 0 {
+		t.Fatalf("разные сообщения: ошибка %v, ожидалась ошибка блока 0", err)
+	}
+}
+
+// Показатели e < 2, разные e и неверное количество шифров отвергаются без паники
+func TestHastadAttackReject(t *testing.T) {
+	pubKey, _ := testKeyPairE(t, 512, 3)
+	chipher := encryptBlocks(t, pubKey, []byte("сообщение"))
+
+	for _, e := range []int64{0, 1, -3} {
+		keys := []*PublicKey{NewPublicKey(big.NewInt(e), pubKey.N), NewPublicKey(big.NewInt(e), pubKey.N)}
+		if _, err := HastadAttack(keys, [][]byte{chipher, chipher}); !errors.Is(err, ErrKeyFormat) {
+			t.Errorf("e = %d: ошибка %v, ожидалась ErrKeyFormat", e, err)
+		}
+	}
+
+	other, _ := testKeyPairE(t, 512, 5)
+	if _, err := HastadAttack([]*PublicKey{pubKey, other}, [][]byte{chipher, chipher}); err == nil {
+		t.Error("ключи с разными e приняты")
+	}
+	if _, err := HastadAttack([]*PublicKey{pubKey, pubKey}, [][]byte{chipher}); err == nil {
+		t.Error("шифров меньше, чем ключей, - принято")
+	}
+}
@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+// Чтение публичных ключей RSA из файлов других программ для пакетных атак:
+// PEM (OpenSSL, сертификаты X.509) и OpenSSH (id_rsa.pub, authorized_keys).
+
+// Публичные ключи из содержимого файла. Формат определяется по содержимому:
+//   - PEM: блоки PUBLIC KEY (PKIX), RSA PUBLIC KEY (PKCS#1) и CERTIFICATE, остальные блоки пропускаются;
+//   - OpenSSH: строки вида "ssh-rsa AAAA... комментарий", ключи других типов пропускаются;
+//   - иначе - формат этой программы: e и n в десятичном виде на двух строках.
+func ParsePublicKeys(data []byte) ([]*PublicKey, error) {
+	trimmed := bytes.TrimSpace(data)
+	switch {
+	case bytes.HasPrefix(trimmed, []byte("-----BEGIN")):
+		return parsePEMPublicKeys(trimmed)
+	case bytes.Contains(trimmed, []byte("ssh-rsa ")):
+		return parseSSHPublicKeys(trimmed)
+	}
+	pubKey, err := ParsePublicKey(data)
+	if err != nil {
+		return nil, err
+	}
+	return []*PublicKey{pubKey}, nil
+}
+
+func parsePEMPublicKeys(data []byte) ([]*PublicKey, error) {
+	var keys []*PublicKey
+	for {
+		block, rest := pem.Decode(data)
+		if block == nil {
+			break
+		}
+		data = rest
+
+		var key interface{}
+		var err error
+		switch block.Type {
+		case "PUBLIC KEY":
+			key, err = x509.ParsePKIXPublicKey(block.Bytes)
+		case "RSA PUBLIC KEY":
+			key, err = x509.ParsePKCS1PublicKey(block.Bytes)
+		case "CERTIFICATE":
+			var cert *x509.Certificate
+			if cert, err = x509.ParseCertificate(block.Bytes); err == nil {
+				key = cert.PublicKey
+			}
+		default:
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%w: блок PEM %s: %s", ErrKeyFormat, block.Type, err)
+		}
+		if rsaKey, ok := key.(*rsa.PublicKey); ok {
+			keys = append(keys, NewPublicKey(big.NewInt(int64(rsaKey.E)), rsaKey.N))
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: в файле PEM нет публичных ключей RSA", ErrKeyFormat)
+	}
+	return keys, nil
+}
+
+func parseSSHPublicKeys(data []byte) ([]*PublicKey, error) {
+	var keys []*PublicKey
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	// строки authorized_keys с ключами 16384 бит длиннее буфера по умолчанию
+	scanner.Buffer(nil, 1<<20)
+	for line := 1; scanner.Scan(); line++ {
+		// в authorized_keys перед типом ключа могут идти параметры, ищем поле ssh-rsa
+		fields := strings.Fields(scanner.Text())
+		for i := 0; i+1 < len(fields); i++ {
+			if fields[i] != "ssh-rsa" {
+				continue
+			}
+			blob, err := base64.StdEncoding.DecodeString(fields[i+1])
+			if err != nil {
+				return nil, fmt.Errorf("%w: строка %d: %s", ErrKeyFormat, line, err)
+			}
+			key, err := parseSSHRSABlob(blob)
+			if err != nil {
+				return nil, fmt.Errorf("%w: строка %d: %s", ErrKeyFormat, line, err)
+			}
+			keys = append(keys, key)
+			break
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("%w: в файле нет ключей ssh-rsa", ErrKeyFormat)
+	}
+	return keys, nil
+}
+
+// Ключ ssh-rsa (RFC 4253, 6.6): строка "ssh-rsa", mpint e, mpint n, каждое поле с 4-байтовой длиной
+func parseSSHRSABlob(blob []byte) (*PublicKey, error) {
+	var fields [3][]byte
+	for i := range fields {
+		if len(blob) < 4 {
+			return nil, errors.New("ключ ssh-rsa обрывается")
+		}
+		size := binary.BigEndian.Uint32(blob)
+		if uint64(len(blob)-4) < uint64(size) {
+			return nil, errors.New("ключ ssh-rsa обрывается")
+		}
+		fields[i], blob = blob[4:4+size], blob[4+size:]
+	}
+	if string(fields[0]) != "ssh-rsa" {
+		return nil, fmt.Errorf("тип ключа %q вместо ssh-rsa", fields[0])
+	}
+	// mpint положительные, старший бит знака дает лишний нулевой байт, SetBytes его не замечает
+	e, n := new(big.Int).SetBytes(fields[1]), new(big.Int).SetBytes(fields[2])
+	if e.Sign() == 0 || n.Sign() == 0 {
+		return nil, errors.New("нулевые e или n")
+	}
+	return NewPublicKey(e, n), nil
+}
@@ -0,0 +1,177 @@
+package utils
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+)
+
+// поле ключа OpenSSH: 4-байтовая длина и содержимое
+func sshField(data []byte) []byte {
+	field := make([]byte, 4, 4+len(data))
+	binary.BigEndian.PutUint32(field, uint32(len(data)))
+	return append(field, data...)
+}
+
+// mpint OpenSSH: положительное число со старшим нулевым байтом, если установлен знаковый бит
+func sshMPInt(x *big.Int) []byte {
+	b := x.Bytes()
+	if len(b) > 0 && b[0]&0x80 != 0 {
+		b = append([]byte{0}, b...)
+	}
+	return sshField(b)
+}
+
+func sshBlob(keyType string, e, n *big.Int) []byte {
+	blob := sshField([]byte(keyType))
+	blob = append(blob, sshMPInt(e)...)
+	return append(blob, sshMPInt(n)...)
+}
+
+func sshLine(blob []byte) string {
+	return "ssh-rsa " + base64.StdEncoding.EncodeToString(blob)
+}
+
+func sameKey(pubKey *PublicKey, std *rsa.PublicKey) bool {
+	return pubKey.E.Cmp(big.NewInt(int64(std.E))) == 0 && pubKey.N.Cmp(std.N) == 0
+}
+
+// Поля ключа ssh-rsa: обрывы, длина больше блоба и чужой тип ключа
+func TestParseSSHRSABlob(t *testing.T) {
+	std, _, _ := testStdKey(t, 1024)
+	e, n := big.NewInt(int64(std.E)), std.N
+	valid := sshBlob("ssh-rsa", e, n)
+
+	pubKey, err := parseSSHRSABlob(valid)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !sameKey(pubKey, &std.PublicKey) {
+		t.Fatal("ключ не совпадает")
+	}
+
+	oversized := append([]byte(nil), valid...)
+	binary.BigEndian.PutUint32(oversized[len(sshField([]byte("ssh-rsa")))+len(sshMPInt(e)):], 1<<31)
+	cases := []struct {
+		name string
+		blob []byte
+	}{
+		{"пустой", nil},
+		{"обрыв в длине поля", valid[:2]},
+		{"обрыв в типе", valid[:6]},
+		{"обрыв в n", valid[:len(valid)-1]},
+		{"нет n", valid[:len(sshField([]byte("ssh-rsa")))+len(sshMPInt(e))]},
+		{"длина n больше блоба", oversized},
+		{"длина 0xffffffff", []byte{0xff, 0xff, 0xff, 0xff, 's'}},
+		{"тип ssh-dss", sshBlob("ssh-dss", e, n)},
+		{"нулевой модуль", sshBlob("ssh-rsa", e, new(big.Int))},
+		{"нулевой e", sshBlob("ssh-rsa", new(big.Int), n)},
+	}
+	for _, c := range cases {
+		if _, err := parseSSHRSABlob(c.blob); err == nil {
+			t.Errorf("%s: ключ принят", c.name)
+		}
+	}
+}
+
+// самоподписанный сертификат на ключ std
+func testCertificate(t *testing.T, std *rsa.PrivateKey) []byte {
+	t.Helper()
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &std.PublicKey, std)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert
+}
+
+// Определение формата файла и разбор PEM, OpenSSH и собственного формата
+func TestParsePublicKeys(t *testing.T) {
+	var stds []*rsa.PublicKey
+	var privs []*rsa.PrivateKey
+	for i := 0; i < 3; i++ {
+		std, _, _ := testStdKey(t, 1024)
+		stds = append(stds, &std.PublicKey)
+		privs = append(privs, std)
+	}
+	der, err := x509.MarshalPKIXPublicKey(stds[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var multi bytes.Buffer
+	pem.Encode(&multi, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	// блоки других типов пропускаются
+	pem.Encode(&multi, &pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privs[2])})
+	pem.Encode(&multi, &pem.Block{Type: "RSA PUBLIC KEY", Bytes: x509.MarshalPKCS1PublicKey(stds[1])})
+	pem.Encode(&multi, &pem.Block{Type: "CERTIFICATE", Bytes: testCertificate(t, privs[2])})
+
+	blob := func(i int) []byte {
+		return sshBlob("ssh-rsa", big.NewInt(int64(stds[i].E)), stds[i].N)
+	}
+	authorizedKeys := strings.Join([]string{
+		"# комментарий",
+		sshLine(blob(0)) + " user@host",
+		`from="10.0.0.0/8",no-pty ` + sshLine(blob(1)) + " backup",
+		"ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl other",
+		"",
+		`command="/bin/true" ` + sshLine(blob(2)),
+	}, "\n")
+
+	cases := []struct {
+		name string
+		data string
+		want []int
+	}{
+		{"PEM из нескольких блоков", multi.String(), []int{0, 1, 2}},
+		{"authorized_keys с параметрами", authorizedKeys, []int{0, 1, 2}},
+		{"id_rsa.pub", sshLine(blob(1)) + " user@host\n", []int{1}},
+		{"формат программы", big.NewInt(int64(stds[2].E)).String() + "\n" + stds[2].N.String(), []int{2}},
+	}
+	for _, c := range cases {
+		keys, err := ParsePublicKeys([]byte(c.data))
+		if err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+		if len(keys) != len(c.want) {
+			t.Fatalf("%s: %d ключей, ожидалось %d", c.name, len(keys), len(c.want))
+		}
+		for i, k := range c.want {
+			if !sameKey(keys[i], stds[k]) {
+				t.Fatalf("%s: ключ %d не совпадает", c.name, i)
+			}
+		}
+	}
+
+	invalid := []struct {
+		name string
+		data string
+	}{
+		{"PEM без ключей RSA", string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(privs[0])}))},
+		{"поврежденный блок PEM", string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der[:len(der)/2]}))},
+		{"неверный base64", "ssh-rsa AAAA*** user@host"},
+		{"обрезанный ключ ssh-rsa", sshLine(blob(0)[:100])},
+		{"тип ssh-dss внутри ssh-rsa", sshLine(sshBlob("ssh-dss", big.NewInt(3), stds[0].N))},
+		{"ssh-rsa без ключа", "ssh-rsa "},
+		{"три строки", "3\n33\n333"},
+	}
+	for _, c := range invalid {
+		if _, err := ParsePublicKeys([]byte(c.data)); !errors.Is(err, ErrKeyFormat) {
+			t.Errorf("%s: ошибка %v, ожидалась ErrKeyFormat", c.name, err)
+		}
+	}
+}
@@ -0,0 +1,428 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Приведение базиса решетки алгоритмом LLL (Ленстра-Ленстра-Ловас) и ортогонализация Грама-Шмидта.
+// Базис задается строками: basis[i] - i-й вектор, все векторы одной длины.
+// Базис называется LLL-приведенным с параметром δ, если для коэффициентов Грама-Шмидта μ_ij
+// и ортогонализованных векторов b*_i выполнено:
+//   - |μ_ij| <= 1/2 для всех j < i (приведенность по размеру);
+//   - |b*_k|^2 >= (δ - μ_k,k-1^2) * |b*_{k-1}|^2 (условие Ловаса).
+// Первый вектор такого базиса не более чем в (1 / (δ - 1/4))^((n-1)/2) раз длиннее кратчайшего вектора решетки.
+//
+// Есть два варианта приведения:
+//   - LLL - точный, в целых числах без округлений, результат - строго LLL-приведенный базис;
+//   - LLLFloat - быстрее на порядки для больших решеток (атаки Копперсмита), коэффициенты Грама-Шмидта
+//     вычисляются приближенно, а условие |μ_ij| <= 1/2 ослаблено до |μ_ij| <= LLLFloatEta.
+// В обоих вариантах все изменения базиса целочисленные, поэтому результат - точный базис той же решетки.
+
+// Граница |μ_ij| для LLLFloat: запас над 1/2 учитывает погрешность вычислений
+var LLLFloatEta = big.NewRat(51, 100)
+
+// скалярное произведение
+func dot(a, b []*big.Int) *big.Int {
+	r, t := new(big.Int), new(big.Int)
+	for i := range a {
+		r.Add(r, t.Mul(a[i], b[i]))
+	}
+	return r
+}
+
+// скалярное произведение рациональных векторов
+func ratDot(a, b []*big.Rat) *big.Rat {
+	r, t := new(big.Rat), new(big.Rat)
+	for i := range a {
+		r.Add(r, t.Mul(a[i], b[i]))
+	}
+	return r
+}
+
+// проверка базиса и параметра δ: δ из (1/4, 1], а для приближенного варианта из (1/4, 1)
+func checkLLLArgs(basis [][]*big.Int, delta *big.Rat, exact bool) error {
+	if err := checkBasis(basis); err != nil {
+		return err
+	}
+	if delta.Cmp(big.NewRat(1, 4)) <= 0 || delta.Cmp(big.NewRat(1, 1)) > 0 || (!exact && delta.Cmp(big.NewRat(1, 1)) == 0) {
+		return fmt.Errorf("параметр δ = %s вне допустимого интервала", delta.RatString())
+	}
+	return nil
+}
+
+// проверка, что базис не пустой и все векторы одной длины
+func checkBasis(basis [][]*big.Int) error {
+	if len(basis) == 0 {
+		return errors.New("пустой базис решетки")
+	}
+	for i := range basis {
+		if len(basis[i]) != len(basis[0]) {
+			return fmt.Errorf("длина вектора %d базиса %d, ожидается %d", i, len(basis[i]), len(basis[0]))
+		}
+	}
+	// векторов больше, чем размерность пространства - они заведомо зависимы
+	if len(basis) > len(basis[0]) {
+		return ErrLinearlyDependent
+	}
+	return nil
+}
+
+// Ортогонализация Грама-Шмидта в рациональных числах без округлений.
+// Возвращает ортогонализованные векторы b*_i = b_i - Σ_{j<i} μ_ij * b*_j
+// и коэффициенты μ_ij = <b_i, b*_j> / <b*_j, b*_j> (μ_ii = 1, μ_ij = 0 при j > i).
+// Если векторы линейно зависимы, возвращается ErrLinearlyDependent.
+func GramSchmidt(basis [][]*big.Int) ([][]*big.Rat, [][]*big.Rat, error) {
+	if err := checkBasis(basis); err != nil {
+		return nil, nil, err
+	}
+	n := len(basis)
+	bstar := make([][]*big.Rat, n)
+	mu := make([][]*big.Rat, n)
+	// квадраты длин b*_j
+	norms := make([]*big.Rat, n)
+	t := new(big.Rat)
+	for i := 0; i < n; i++ {
+		bstar[i] = make([]*big.Rat, len(basis[i]))
+		for c := range basis[i] {
+			bstar[i][c] = new(big.Rat).SetInt(basis[i][c])
+		}
+		b := bstar[i]
+		mu[i] = make([]*big.Rat, n)
+		for j := 0; j < n; j++ {
+			mu[i][j] = new(big.Rat)
+		}
+		mu[i][i].SetInt64(1)
+		for j := 0; j < i; j++ {
+			// <b_i, b*_j> = <b_i - Σ_{l<j} μ_il b*_l, b*_j>, так как b*_l ортогональны b*_j
+			mu[i][j].Quo(ratDot(b, bstar[j]), norms[j])
+			for c := range b {
+				b[c].Sub(b[c], t.Mul(mu[i][j], bstar[j][c]))
+			}
+		}
+		norms[i] = ratDot(b, b)
+		if norms[i].Sign() == 0 {
+			return nil, nil, ErrLinearlyDependent
+		}
+	}
+	return bstar, mu, nil
+}
+
+// Проверка, что базис LLL-приведен с параметром delta и границей |μ_ij| <= eta.
+// Для результата LLL eta = 1/2, для результата LLLFloat - LLLFloatEta.
+// Для линейно зависимых векторов возвращается false.
+func IsLLLReduced(basis [][]*big.Int, delta, eta *big.Rat) bool {
+	bstar, mu, err := GramSchmidt(basis)
+	if err != nil {
+		return false
+	}
+	t := new(big.Rat)
+	for i := range mu {
+		for j := 0; j < i; j++ {
+			if t.Abs(mu[i][j]).Cmp(eta) > 0 {
+				return false
+			}
+		}
+	}
+	for k := 1; k < len(bstar); k++ {
+		// |b*_k|^2 >= (δ - μ_k,k-1^2) * |b*_{k-1}|^2
+		rhs := new(big.Rat).Mul(mu[k][k-1], mu[k][k-1])
+		rhs.Sub(delta, rhs)
+		rhs.Mul(rhs, ratDot(bstar[k-1], bstar[k-1]))
+		if ratDot(bstar[k], bstar[k]).Cmp(rhs) < 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Точное приведение базиса basis на месте с параметром delta из (1/4, 1].
+// Используется целочисленный вариант (Коэн, "A Course in Computational Algebraic Number Theory", алгоритм 2.6.7):
+// вместо рациональных коэффициентов Грама-Шмидта хранятся целые d_i (определители Грама) и λ_ij = d_j * μ_ij,
+// все деления в нем точные, поэтому результат совпадает с LLL в рациональных числах.
+// Векторы базиса должны быть линейно независимы, иначе возвращается ErrLinearlyDependent.
+// При отмене контекста возвращается ошибка контекста, базис остается частично приведенным.
+func LLL(ctx context.Context, basis [][]*big.Int, delta *big.Rat) error {
+	if err := checkLLLArgs(basis, delta, true); err != nil {
+		return err
+	}
+	n := len(basis)
+	// delta = dp / dq
+	dp, dq := delta.Num(), delta.Denom()
+
+	// индексы как у Коэна - с 1, b(k) - k-й вектор
+	b := func(k int) []*big.Int { return basis[k-1] }
+	d := make([]*big.Int, n+1)
+	lambda := make([][]*big.Int, n+1)
+	for i := range lambda {
+		lambda[i] = make([]*big.Int, n+1)
+	}
+	d[0] = big.NewInt(1)
+	d[1] = dot(b(1), b(1))
+	if d[1].Sign() == 0 {
+		return ErrLinearlyDependent
+	}
+
+	t := new(big.Int)
+
+	// уменьшение b(k) на кратное b(l), чтобы |μ_kl| <= 1/2
+	red := func(k, l int) {
+		twice := new(big.Int).Lsh(lambda[k][l], 1)
+		if twice.CmpAbs(d[l]) <= 0 {
+			return
+		}
+		// q = round(λ_kl / d_l) = floor((2λ_kl + d_l) / (2d_l))
+		q := twice.Add(twice, d[l])
+		q.Div(q, new(big.Int).Lsh(d[l], 1))
+		bk, bl := b(k), b(l)
+		for i := range bk {
+			bk[i].Sub(bk[i], t.Mul(q, bl[i]))
+		}
+		lambda[k][l].Sub(lambda[k][l], t.Mul(q, d[l]))
+		for i := 1; i < l; i++ {
+			lambda[k][i].Sub(lambda[k][i], t.Mul(q, lambda[l][i]))
+		}
+	}
+
+	kmax := 1
+	// перестановка b(k) и b(k-1) с пересчетом d и λ
+	swap := func(k int) {
+		basis[k-1], basis[k-2] = basis[k-2], basis[k-1]
+		for j := 1; j <= k-2; j++ {
+			lambda[k][j], lambda[k-1][j] = lambda[k-1][j], lambda[k][j]
+		}
+		l := lambda[k][k-1]
+		// B = (d_{k-2} * d_k + λ^2) / d_{k-1}
+		B := new(big.Int).Mul(d[k-2], d[k])
+		B.Add(B, t.Mul(l, l))
+		B.Quo(B, d[k-1])
+		for i := k + 1; i <= kmax; i++ {
+			old := lambda[i][k]
+			// λ_ik = (d_k * λ_i,k-1 - λ * old) / d_{k-1}
+			nk := new(big.Int).Mul(d[k], lambda[i][k-1])
+			nk.Sub(nk, t.Mul(l, old))
+			nk.Quo(nk, d[k-1])
+			// λ_i,k-1 = (B * old + λ * λ_ik) / d_k
+			nk1 := new(big.Int).Mul(B, old)
+			nk1.Add(nk1, t.Mul(l, nk))
+			nk1.Quo(nk1, d[k])
+			lambda[i][k], lambda[i][k-1] = nk, nk1
+		}
+		d[k-1] = B
+	}
+
+	lhs, rhs := new(big.Int), new(big.Int)
+	for k := 2; k <= n; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// пошаговое вычисление Грама-Шмидта для нового вектора
+		if k > kmax {
+			kmax = k
+			for j := 1; j <= k; j++ {
+				u := dot(b(k), b(j))
+				for i := 1; i < j; i++ {
+					// u = (d_i * u - λ_ki * λ_ji) / d_{i-1}
+					u.Mul(u, d[i])
+					u.Sub(u, t.Mul(lambda[k][i], lambda[j][i]))
+					u.Quo(u, d[i-1])
+				}
+				if j < k {
+					lambda[k][j] = u
+				} else {
+					if u.Sign() == 0 {
+						return ErrLinearlyDependent
+					}
+					d[k] = u
+				}
+			}
+		}
+
+		// условие Ловаса: dq * d_k * d_{k-2} >= dp * d_{k-1}^2 - dq * λ_k,k-1^2
+		red(k, k-1)
+		lhs.Mul(d[k], d[k-2])
+		lhs.Mul(lhs, dq)
+		rhs.Mul(d[k-1], d[k-1])
+		rhs.Mul(rhs, dp)
+		rhs.Sub(rhs, t.Mul(t.Mul(lambda[k][k-1], lambda[k][k-1]), dq))
+		if lhs.Cmp(rhs) < 0 {
+			swap(k)
+			if k > 2 {
+				k--
+			}
+			continue
+		}
+
+		for l := k - 2; l >= 1; l-- {
+			red(k, l)
+		}
+		k++
+	}
+	return nil
+}
+
+// точность вычислений Грама-Шмидта для решетки размерности n
+// для δ < 1 достаточно примерно 1.6n бит, берем с запасом
+func lllPrecision(n int) uint {
+	return uint(2*n + 64)
+}
+
+// Приближенное приведение базиса basis на месте с параметром delta из (1/4, 1).
+// Используется вариант Шнорра-Эйхнера: базис и матрица Грама хранятся точно в целых числах,
+// а коэффициенты Грама-Шмидта вычисляются по матрице Грама в числах с плавающей точкой
+// повышенной точности, поэтому для решеток размерности в десятки он намного быстрее LLL.
+// Векторы базиса должны быть линейно независимы.
+// При отмене контекста возвращается ошибка контекста, базис остается частично приведенным.
+func LLLFloat(ctx context.Context, basis [][]*big.Int, delta *big.Rat) error {
+	if err := checkLLLArgs(basis, delta, false); err != nil {
+		return err
+	}
+	n := len(basis)
+	prec := lllPrecision(n)
+	newFloat := func() *big.Float { return new(big.Float).SetPrec(prec) }
+	deltaF := newFloat().SetRat(delta)
+	// |μ| <= 1/2 + η, η > 0 учитывает погрешность вычислений
+	eta := newFloat().SetRat(LLLFloatEta)
+	half := newFloat().SetFloat64(0.5)
+
+	// точная матрица Грама
+	gram := make([][]*big.Int, n)
+	for i := range gram {
+		gram[i] = make([]*big.Int, n)
+	}
+	for i := 0; i < n; i++ {
+		for j := 0; j <= i; j++ {
+			gram[i][j] = dot(basis[i], basis[j])
+			gram[j][i] = gram[i][j]
+		}
+	}
+
+	// μ_kj, r_kj = <b_k, b*_j> и квадраты длин ортогонализованных векторов
+	mu := make([][]*big.Float, n)
+	r := make([][]*big.Float, n)
+	for i := range mu {
+		mu[i] = make([]*big.Float, n)
+		r[i] = make([]*big.Float, n)
+		for j := range mu[i] {
+			mu[i][j] = newFloat()
+			r[i][j] = newFloat()
+		}
+	}
+	bstar := make([]*big.Float, n)
+	for i := range bstar {
+		bstar[i] = newFloat()
+	}
+
+	// пересчет строки k коэффициентов Грама-Шмидта по матрице Грама
+	// если |b*_k|^2 намного меньше |b_k|^2, он вычисляется с большой потерей точности и может выйти
+	// даже неположительным, но тогда условие Ловаса все равно нарушено и векторы переставляются
+	t := newFloat()
+	gsRow := func(k int) {
+		for j := 0; j < k; j++ {
+			r[k][j].SetInt(gram[k][j])
+			for i := 0; i < j; i++ {
+				r[k][j].Sub(r[k][j], t.Mul(mu[j][i], r[k][i]))
+			}
+			mu[k][j].Quo(r[k][j], bstar[j])
+		}
+		bstar[k].SetInt(gram[k][k])
+		for j := 0; j < k; j++ {
+			bstar[k].Sub(bstar[k], t.Mul(mu[k][j], r[k][j]))
+		}
+	}
+
+	// b_k = b_k - q * b_j с пересчетом матрицы Грама
+	tmp := new(big.Int)
+	subMultiple := func(k, j int, q *big.Int) {
+		bk, bj := basis[k], basis[j]
+		for i := range bk {
+			bk[i].Sub(bk[i], tmp.Mul(q, bj[i]))
+		}
+		// <b_k, b_k> = <b_k, b_k> - 2q<b_k, b_j> + q^2<b_j, b_j>
+		kk := new(big.Int).Mul(q, gram[j][j])
+		kk.Sub(kk, new(big.Int).Lsh(gram[k][j], 1))
+		kk.Mul(kk, q)
+		kk.Add(kk, gram[k][k])
+		for i := 0; i < n; i++ {
+			if i == k {
+				continue
+			}
+			v := new(big.Int).Sub(gram[k][i], tmp.Mul(q, gram[j][i]))
+			gram[k][i], gram[i][k] = v, v
+		}
+		gram[k][k] = kk
+	}
+
+	// перестановка b_k и b_{k-1}
+	swap := func(k int) {
+		basis[k], basis[k-1] = basis[k-1], basis[k]
+		gram[k], gram[k-1] = gram[k-1], gram[k]
+		for i := 0; i < n; i++ {
+			gram[i][k], gram[i][k-1] = gram[i][k-1], gram[i][k]
+		}
+	}
+
+	// ближайшее целое к x
+	round := func(x *big.Float) *big.Int {
+		y := newFloat().Abs(x)
+		y.Add(y, half)
+		q, _ := y.Int(nil)
+		if x.Sign() < 0 {
+			q.Neg(q)
+		}
+		return q
+	}
+
+	gsRow(0)
+	if bstar[0].Sign() <= 0 {
+		return ErrLinearlyDependent
+	}
+	lhs, rhs := newFloat(), newFloat()
+	for k := 1; k < n; {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// уменьшение размера, повторяется, пока все |μ_kj| не станут малыми
+		for {
+			gsRow(k)
+			reduced := false
+			for j := k - 1; j >= 0; j-- {
+				if t.Abs(mu[k][j]).Cmp(eta) <= 0 {
+					continue
+				}
+				q := round(mu[k][j])
+				subMultiple(k, j, q)
+				qf := newFloat().SetInt(q)
+				for i := 0; i < j; i++ {
+					mu[k][i].Sub(mu[k][i], t.Mul(qf, mu[j][i]))
+				}
+				mu[k][j].Sub(mu[k][j], qf)
+				reduced = true
+			}
+			if !reduced {
+				break
+			}
+		}
+		// условие Ловаса: |b*_k|^2 >= (δ - μ_k,k-1^2) * |b*_{k-1}|^2
+		lhs.Set(bstar[k])
+		rhs.Mul(mu[k][k-1], mu[k][k-1])
+		rhs.Sub(deltaF, rhs)
+		rhs.Mul(rhs, bstar[k-1])
+		if lhs.Cmp(rhs) < 0 {
+			swap(k)
+			if k > 1 {
+				k--
+			} else if gsRow(0); bstar[0].Sign() <= 0 {
+				// у зависимых векторов при приведении появляется нулевой вектор
+				return ErrLinearlyDependent
+			}
+			continue
+		}
+		k++
+	}
+	return nil
+}
@@ -0,0 +1,255 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"testing"
+)
+
+// базис из целых чисел
+func intBasis(rows ...[]int64) [][]*big.Int {
+	basis := make([][]*big.Int, len(rows))
+	for i, row := range rows {
+		basis[i] = make([]*big.Int, len(row))
+		for j, v := range row {
+			basis[i][j] = big.NewInt(v)
+		}
+	}
+	return basis
+}
+
+// копия базиса, LLL приводит его на месте
+func copyBasis(basis [][]*big.Int) [][]*big.Int {
+	c := make([][]*big.Int, len(basis))
+	for i := range basis {
+		c[i] = make([]*big.Int, len(basis[i]))
+		for j := range basis[i] {
+			c[i][j] = new(big.Int).Set(basis[i][j])
+		}
+	}
+	return c
+}
+
+// совпадение базисов с точностью до порядка и знака векторов
+func sameVectors(a, b [][]*big.Int) bool {
+	used := make([]bool, len(b))
+	for _, u := range a {
+		found := false
+		for j, v := range b {
+			if used[j] {
+				continue
+			}
+			if equalBasis([][]*big.Int{u}, [][]*big.Int{v}) || equalBasis([][]*big.Int{u}, [][]*big.Int{negVector(v)}) {
+				used[j], found = true, true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	return len(a) == len(b)
+}
+
+func negVector(v []*big.Int) []*big.Int {
+	w := make([]*big.Int, len(v))
+	for i := range v {
+		w[i] = new(big.Int).Neg(v[i])
+	}
+	return w
+}
+
+func equalBasis(a, b [][]*big.Int) bool {
+	for i := range a {
+		for j := range a[i] {
+			if a[i][j].Cmp(b[i][j]) != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// определитель квадратной матрицы методом Гаусса в рациональных числах
+func ratDet(basis [][]*big.Int) *big.Rat {
+	n := len(basis)
+	m := make([][]*big.Rat, n)
+	for i := range basis {
+		m[i] = make([]*big.Rat, n)
+		for j := range basis[i] {
+			m[i][j] = new(big.Rat).SetInt(basis[i][j])
+		}
+	}
+	det := big.NewRat(1, 1)
+	for c := 0; c < n; c++ {
+		p := c
+		for p < n && m[p][c].Sign() == 0 {
+			p++
+		}
+		if p == n {
+			return new(big.Rat)
+		}
+		if p != c {
+			m[p], m[c] = m[c], m[p]
+			det.Neg(det)
+		}
+		det.Mul(det, m[c][c])
+		for r := c + 1; r < n; r++ {
+			f := new(big.Rat).Quo(m[r][c], m[c][c])
+			for k := c; k < n; k++ {
+				m[r][k].Sub(m[r][k], new(big.Rat).Mul(f, m[c][k]))
+			}
+		}
+	}
+	return det
+}
+
+var lllDelta = big.NewRat(3, 4)
+
+// Известные базисы с известным результатом приведения при δ = 3/4.
+// Порядок и знаки векторов в источниках зависят от варианта алгоритма,
+// поэтому точное совпадение требуется только там, где exact отмечен.
+func TestLLLKnownBases(t *testing.T) {
+	cases := []struct {
+		name  string
+		basis [][]*big.Int
+		want  [][]*big.Int
+		exact bool
+	}{
+		{
+			// пример из статьи Википедии "Lenstra–Lenstra–Lovász lattice basis reduction algorithm"
+			name:  "Википедия, Z^3",
+			basis: intBasis([]int64{1, 1, 1}, []int64{-1, 0, 2}, []int64{3, 5, 6}),
+			want:  intBasis([]int64{0, 1, 0}, []int64{1, 0, 1}, []int64{-1, 0, 2}),
+			exact: true,
+		},
+		{
+			// пример Гауссова приведения из Хоффстейна, Пайфера и Сильвермана,
+			// "An Introduction to Mathematical Cryptography", пример 6.63
+			name:  "Хоффстейн-Пайфер-Сильверман, 2 x 2",
+			basis: intBasis([]int64{66586820, 65354729}, []int64{6513996, 6393464}),
+			want:  intBasis([]int64{2280, -1001}, []int64{-1324, -2376}),
+		},
+		{
+			// решетка Z^3, заданная унимодулярным базисом: приведение дает единичные векторы
+			name:  "унимодулярный базис Z^3",
+			basis: intBasis([]int64{1, 2, 3}, []int64{2, 5, 7}, []int64{3, 7, 11}),
+			want:  intBasis([]int64{0, 1, 0}, []int64{1, 0, 0}, []int64{0, 0, 1}),
+		},
+	}
+
+	for _, c := range cases {
+		exact := copyBasis(c.basis)
+		if err := LLL(context.Background(), exact, lllDelta); err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+		if c.exact && !equalBasis(exact, c.want) || !sameVectors(exact, c.want) {
+			t.Errorf("%s: LLL дает %v, ожидалось %v", c.name, exact, c.want)
+		}
+		if !IsLLLReduced(exact, lllDelta, big.NewRat(1, 2)) {
+			t.Errorf("%s: результат LLL не LLL-приведен", c.name)
+		}
+
+		float := copyBasis(c.basis)
+		if err := LLLFloat(context.Background(), float, lllDelta); err != nil {
+			t.Fatalf("%s: %s", c.name, err)
+		}
+		if !equalBasis(float, exact) {
+			t.Errorf("%s: LLLFloat дает %v, LLL - %v", c.name, float, exact)
+		}
+	}
+}
+
+// На случайных базисах оба варианта дают приведенный базис той же решетки
+func TestLLLExactAndFloatAgree(t *testing.T) {
+	for n := 2; n <= 12; n += 5 {
+		basis := make([][]*big.Int, n)
+		for i := range basis {
+			basis[i] = make([]*big.Int, n)
+			for j := range basis[i] {
+				v, err := rand.Int(rand.Reader, new(big.Int).Lsh(i1, 40))
+				if err != nil {
+					t.Fatal(err)
+				}
+				basis[i][j] = v
+			}
+		}
+		det := ratDet(basis)
+		if det.Sign() == 0 {
+			continue
+		}
+
+		exact, float := copyBasis(basis), copyBasis(basis)
+		if err := LLL(context.Background(), exact, lllDelta); err != nil {
+			t.Fatal(err)
+		}
+		if err := LLLFloat(context.Background(), float, lllDelta); err != nil {
+			t.Fatal(err)
+		}
+		if !IsLLLReduced(exact, lllDelta, big.NewRat(1, 2)) {
+			t.Errorf("n = %d: результат LLL не LLL-приведен", n)
+		}
+		if !IsLLLReduced(float, lllDelta, LLLFloatEta) {
+			t.Errorf("n = %d: результат LLLFloat не LLL-приведен", n)
+		}
+		// целочисленные унимодулярные преобразования сохраняют |det|
+		abs := new(big.Rat).Abs(det)
+		if d := ratDet(exact); new(big.Rat).Abs(d).Cmp(abs) != 0 {
+			t.Errorf("n = %d: LLL изменил определитель решетки", n)
+		}
+		if d := ratDet(float); new(big.Rat).Abs(d).Cmp(abs) != 0 {
+			t.Errorf("n = %d: LLLFloat изменил определитель решетки", n)
+		}
+		// первые векторы обоих результатов не длиннее 2^((n-1)/2) кратчайшего, сравниваем их нормы с этим запасом
+		na, nb := dot(exact[0], exact[0]), dot(float[0], float[0])
+		bound := new(big.Int).Lsh(na, uint(n-1))
+		if nb.Cmp(bound) > 0 || na.Cmp(new(big.Int).Lsh(nb, uint(n-1))) > 0 {
+			t.Errorf("n = %d: первые векторы LLL и LLLFloat несопоставимы по длине", n)
+		}
+	}
+}
+
+// Ортогонализация Грама-Шмидта на базисе (3, 1), (2, 2): μ_21 = 4/5, b*_2 = (-2/5, 6/5)
+func TestGramSchmidt(t *testing.T) {
+	bstar, mu, err := GramSchmidt(intBasis([]int64{3, 1}, []int64{2, 2}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mu[1][0].Cmp(big.NewRat(4, 5)) != 0 {
+		t.Errorf("μ_21 = %s, ожидалось 4/5", mu[1][0].RatString())
+	}
+	if bstar[0][0].Cmp(big.NewRat(3, 1)) != 0 || bstar[0][1].Cmp(big.NewRat(1, 1)) != 0 {
+		t.Errorf("b*_1 = (%s, %s), ожидалось (3, 1)", bstar[0][0].RatString(), bstar[0][1].RatString())
+	}
+	if bstar[1][0].Cmp(big.NewRat(-2, 5)) != 0 || bstar[1][1].Cmp(big.NewRat(6, 5)) != 0 {
+		t.Errorf("b*_2 = (%s, %s), ожидалось (-2/5, 6/5)", bstar[1][0].RatString(), bstar[1][1].RatString())
+	}
+	if ratDot(bstar[0], bstar[1]).Sign() != 0 {
+		t.Error("b*_1 и b*_2 не ортогональны")
+	}
+}
+
+// Линейно зависимые векторы дают ErrLinearlyDependent во всех функциях
+func TestLLLLinearlyDependent(t *testing.T) {
+	bases := [][][]*big.Int{
+		intBasis([]int64{1, 2, 3}, []int64{2, 4, 6}, []int64{0, 0, 1}),
+		intBasis([]int64{1, 0, 1}, []int64{0, 1, 1}, []int64{1, 1, 2}),
+		intBasis([]int64{1, 2}, []int64{3, 4}, []int64{5, 6}),
+	}
+	for i, basis := range bases {
+		if _, _, err := GramSchmidt(basis); !errors.Is(err, ErrLinearlyDependent) {
+			t.Errorf("базис %d: GramSchmidt вернул %v", i, err)
+		}
+		if err := LLL(context.Background(), copyBasis(basis), lllDelta); !errors.Is(err, ErrLinearlyDependent) {
+			t.Errorf("базис %d: LLL вернул %v", i, err)
+		}
+		if err := LLLFloat(context.Background(), copyBasis(basis), lllDelta); !errors.Is(err, ErrLinearlyDependent) {
+			t.Errorf("базис %d: LLLFloat вернул %v", i, err)
+		}
+		if IsLLLReduced(basis, lllDelta, big.NewRat(1, 2)) {
+			t.Errorf("базис %d: зависимые векторы признаны LLL-приведенными", i)
+		}
+	}
+}
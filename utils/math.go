@@ -2,9 +2,9 @@ package utils
 
 import (
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"math/big"
-	"strings"
 )
 
 var (
@@ -15,35 +15,32 @@ var (
 )
 
 // возведение в степень больших числе по модулю
+// для нечетного модуля используется умножение Монтгомери со скользящим окном (utils/montgomery.go),
+// для четного - обычный бинарный метод
 func exp(integer, pow, modulus *big.Int) *big.Int {
-	// получаем битовое представление степени
-	integerBinary := strings.Split(fmt.Sprintf("%b", pow), "")
-
-	// ининиализируем a, b
-	A := new(big.Int).Set(integer)
-	b := new(big.Int).Set(i1)
-
-	// если старший бит равен 0 или модуль равен 1 - возврат b (1)
-	if integerBinary[0] == "0" || modulus.Cmp(big.NewInt(1)) == 0 {
-		return b
+	// по модулю 1 любое число равно 0
+	if modulus.Cmp(i1) == 0 {
+		return new(big.Int)
 	}
 
-	// если старший бит равен 1
-	// копируем в b значение а
-	if integerBinary[len(integerBinary)-1] == "1" {
-		b = new(big.Int).Add(A, big.NewInt(0)) // копирую
+	if modulus.Bit(0) == 1 {
+		return newMontContext(modulus).exp(integer, pow)
 	}
 
-	// итерируемся по битовой строке modulus
-	for idx := len(integerBinary) - 2; idx >= 0; idx-- {
-		// a = a * 2 (mod n)
-		A = new(big.Int).Mul(A, A)
-		A.Mod(A, modulus)
+	// ининиализируем a, b
+	A := new(big.Int).Mod(integer, modulus)
+	b := new(big.Int).Set(i1)
+
+	// итерируемся по битам степени от старшего к младшему
+	for idx := pow.BitLen() - 1; idx >= 0; idx-- {
+		// b = b ^ 2 (mod n)
+		b.Mul(b, b)
+		b.Mod(b, modulus)
 		// если текущий бит == 1
-		if integerBinary[idx] == "1" {
+		if pow.Bit(idx) == 1 {
 			// b = a * b (mod n)
-			b = new(big.Int).Mul(A, b)
-			b = new(big.Int).Mod(b, modulus)
+			b.Mul(b, A)
+			b.Mod(b, modulus)
 		}
 	}
 
@@ -53,7 +50,8 @@ func exp(integer, pow, modulus *big.Int) *big.Int {
 
 // тест на простоту
 // принимает число для тестирования и количество тестов
-func testForPrime(n *big.Int, count int) bool {
+// ошибка возвращается только если не удалось получить случайное число
+func testForPrime(n *big.Int, count int) (bool, error) {
 	// создаем хранилище для записи уже сгенерированных значений
 	// чтобы исключить повторение при рандомном выборе числа
 	history := make(map[string]bool, count)
@@ -62,14 +60,24 @@ func testForPrime(n *big.Int, count int) bool {
 	// pow = n - 1
 	pow := new(big.Int).Sub(n, i1)
 
+	// контекст Монтгомери создаем один раз на все проверки
+	var mc *montContext
+	if n.Bit(0) == 1 && n.Cmp(i1) > 0 {
+		mc = newMontContext(n)
+	}
+
 	// инициализируем переменную, в которой будем хранить кандидата (сгенерированное число из от 2 до n-1)
 	candidate := new(big.Int)
+	var err error
 
 	// повторяем количество раз из count
 	for i := 0; i < count; i++ {
 		// генерируем случайное число-кандидат от 0 до n-3 (включительно)
 		// от 0, потому что программная реализация не позволяет указать нижнюю границу
-		candidate, _ = rand.Int(rand.Reader, new(big.Int).Sub(n, i2))
+		candidate, err = rand.Int(rand.Reader, new(big.Int).Sub(n, i2))
+		if err != nil {
+			return false, err
+		}
 		// добавляем к получившему-ся числу 2, такие образом из от 0 до n-3
 		// получаем от 2 до n-1
 		candidate = new(big.Int).Add(candidate, i2)
@@ -78,10 +86,15 @@ func testForPrime(n *big.Int, count int) bool {
 			i--
 		} else {
 			// возводим кандидата в степень n -1 по модулю n
-			c := exp(candidate, pow, n)
+			var c *big.Int
+			if mc != nil {
+				c = mc.exp(candidate, pow)
+			} else {
+				c = exp(candidate, pow, n)
+			}
 			// если резульат != 1, возвращаем false, тест провален
 			if c.Cmp(i1) != 0 {
-				return false
+				return false, nil
 			}
 			// сохраняем информацию о протестированном кандидате
 			history[candidate.String()] = true
@@ -89,32 +102,10 @@ func testForPrime(n *big.Int, count int) bool {
 	}
 
 	// если прошел нужное количество проверок - возвращаем true, тест пройден
-	return true
+	return true, nil
 }
 
-// log2(n)
-func log2(num *big.Int) int64 {
-	//инициализируем счетчик
-	var i int64 = 1
-
-	// начинаем с 2х
-	res := new(big.Int).Set(i2)
-
-	for {
-		// умножаем на 2
-		res = new(big.Int).Mul(res, i2)
-		// если получившийся результат больше числа
-		// возвращаем счетчик
-		if res.Cmp(num) >= 0 {
-			return i
-		}
-
-		// увеличиваем счетчик
-		i++
-	}
-}
-
-func generatePrimeNumber(bits int) *big.Int {
+func generatePrimeNumber(bits int) (*big.Int, error) {
 	for {
 		// создаем новое число, устанавливаем в нужный бит единицу
 		// гарантирует что число будет не меньше нужно битовой длины
@@ -123,10 +114,13 @@ func generatePrimeNumber(bits int) *big.Int {
 		// генерируем число в диапазоне от 0 до прошлое число - 1
 		// так как прошлое число это 10000...000 (по количеству бит)
 		// -1 от него гарантировано даст битовую длину меньше на единицу от заданой
-		rand, _ := rand.Int(rand.Reader, prime)
+		random, err := rand.Int(rand.Reader, prime)
+		if err != nil {
+			return nil, err
+		}
 
 		// ксорим первое число (где был установлен 1 бит) со сгенерированным значением и получаем кандидата
-		prime = new(big.Int).Xor(prime, rand)
+		prime = new(big.Int).Xor(prime, random)
 
 		// если кандидат четный, перезапускаем процедуру генерации
 		if new(big.Int).Mod(prime, i2).Cmp(i1) != 0 {
@@ -135,8 +129,12 @@ func generatePrimeNumber(bits int) *big.Int {
 			// проводим тест на простоту 1024 раза
 			// если пройден - возвращаем число
 			// если нет - процедура перезапускается заного
-			if passed := testForPrime(prime, 1024); passed {
-				return prime
+			passed, err := testForPrime(prime, 1024)
+			if err != nil {
+				return nil, err
+			}
+			if passed {
+				return prime, nil
 			}
 		}
 	}
@@ -178,3 +176,115 @@ func extendedGCD(a_src, b_src *big.Int) (*big.Int, *big.Int, *big.Int) {
 	}
 	return a, x2, y2
 }
+
+// Целая часть квадратного корня методом Ньютона
+// x_{k+1} = (x_k + n / x_k) / 2, начиная с x_0 >= sqrt(n) последовательность убывает до floor(sqrt(n))
+func isqrt(n *big.Int) *big.Int {
+	if n.Sign() <= 0 {
+		return new(big.Int)
+	}
+	// 2^ceil(bitlen / 2) не меньше корня
+	x := new(big.Int).Lsh(i1, uint(n.BitLen()+1)/2)
+	y := new(big.Int)
+	for {
+		// y = (x + n / x) / 2
+		y.Quo(n, x)
+		y.Add(y, x)
+		y.Rsh(y, 1)
+		if y.Cmp(x) >= 0 {
+			return x
+		}
+		x.Set(y)
+	}
+}
+
+// квадратичные вычеты по модулям 64, 63, 65 и 11 для быстрого отсева неквадратов
+var squareResidues = func() map[int64][]bool {
+	res := make(map[int64][]bool)
+	for _, m := range []int64{64, 63, 65, 11} {
+		res[m] = make([]bool, m)
+		for i := int64(0); i < m; i++ {
+			res[m][i*i%m] = true
+		}
+	}
+	return res
+}()
+
+// Проверка, что n - точный квадрат, и его корень
+// большинство неквадратов отсеиваются по вычетам без вычисления корня
+func perfectSquareRoot(n *big.Int) (*big.Int, bool) {
+	switch n.Sign() {
+	case -1:
+		return nil, false
+	case 0:
+		return new(big.Int), true
+	}
+	if !squareResidues[64][n.Bits()[0]&63] {
+		return nil, false
+	}
+	// 45045 = 63 * 65 * 11
+	r := new(big.Int).Mod(n, big.NewInt(45045)).Int64()
+	if !squareResidues[63][r%63] || !squareResidues[65][r%65] || !squareResidues[11][r%11] {
+		return nil, false
+	}
+	root := isqrt(n)
+	if new(big.Int).Mul(root, root).Cmp(n) != 0 {
+		return nil, false
+	}
+	return root, true
+}
+
+// Целая часть корня степени k >= 1 методом Ньютона и признак того, что корень точный
+// x_{j+1} = ((k - 1) * x_j + n / x_j^(k-1)) / k, начиная с x_0 >= n^(1/k) последовательность убывает до floor(n^(1/k))
+// при k < 1 корень не определен, возвращается 0 и false
+func iroot(n *big.Int, k int) (*big.Int, bool) {
+	if k < 1 {
+		return new(big.Int), false
+	}
+	if n.Sign() <= 0 {
+		return new(big.Int), n.Sign() == 0
+	}
+	if k == 1 {
+		return new(big.Int).Set(n), true
+	}
+	bk, bk1 := big.NewInt(int64(k)), big.NewInt(int64(k-1))
+	// 2^ceil(bitlen / k) не меньше корня
+	x := new(big.Int).Lsh(i1, uint((n.BitLen()+k-1)/k))
+	y, t := new(big.Int), new(big.Int)
+	for {
+		// y = ((k - 1) * x + n / x^(k-1)) / k
+		t.Exp(x, bk1, nil)
+		y.Quo(n, t)
+		t.Mul(x, bk1)
+		y.Add(y, t)
+		y.Quo(y, bk)
+		if y.Cmp(x) >= 0 {
+			break
+		}
+		x.Set(y)
+	}
+	return x, t.Exp(x, bk, nil).Cmp(n) == 0
+}
+
+// Китайская теорема об остатках: x = residues[i] (mod moduli[i]) для попарно взаимно простых модулей.
+// Возвращает x из [0, M) и M - произведение модулей.
+func crt(residues, moduli []*big.Int) (*big.Int, *big.Int, error) {
+	if len(residues) != len(moduli) || len(moduli) == 0 {
+		return nil, nil, errors.New("количество остатков и модулей должно совпадать и быть больше 0")
+	}
+	x := new(big.Int).Mod(residues[0], moduli[0])
+	m := new(big.Int).Set(moduli[0])
+	for i := 1; i < len(moduli); i++ {
+		// x' = x + m * ((r_i - x) * m^-1 mod m_i) удовлетворяет обоим сравнениям
+		inv := new(big.Int).ModInverse(m, moduli[i])
+		if inv == nil {
+			return nil, nil, fmt.Errorf("модуль %d не взаимно прост с предыдущими, НОД = %s", i+1, new(big.Int).GCD(nil, nil, m, moduli[i]))
+		}
+		t := new(big.Int).Sub(residues[i], x)
+		t.Mul(t, inv)
+		t.Mod(t, moduli[i])
+		x.Add(x, t.Mul(t, m))
+		m.Mul(m, moduli[i])
+	}
+	return x, m, nil
+}
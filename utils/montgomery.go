@@ -0,0 +1,280 @@
+package utils
+
+import (
+	"encoding/binary"
+	"math/big"
+	"math/bits"
+	"sync"
+)
+
+// Контекст умножения Монтгомери для фиксированного нечетного модуля n.
+// Числа хранятся массивами 64-битных слов (младшее слово первым),
+// R = 2^(64 * количество слов n). После создания контекст не изменяется,
+// поэтому им можно пользоваться из нескольких горутин одновременно.
+type montContext struct {
+	modulus *big.Int
+	// слова модуля
+	n []uint64
+	// -n^-1 (mod 2^64)
+	n0inv uint64
+	// R^2 (mod n), для перевода в представление Монтгомери
+	rr []uint64
+	// R (mod n), единица в представлении Монтгомери
+	one []uint64
+}
+
+// "Конструктор" контекста, модуль должен быть нечетным и больше 1
+func newMontContext(modulus *big.Int) *montContext {
+	s := (modulus.BitLen() + 63) / 64
+	mc := &montContext{
+		modulus: new(big.Int).Set(modulus),
+	}
+	mc.n = mc.limbs(modulus, s)
+
+	// обратный к n[0] по модулю 2^64 методом Ньютона:
+	// каждая итерация удваивает количество верных бит, n[0] * n[0] = 1 (mod 8) дает 3 бита
+	inv := mc.n[0]
+	for i := 0; i < 5; i++ {
+		inv *= 2 - mc.n[0]*inv
+	}
+	mc.n0inv = -inv
+
+	// R (mod n) и R^2 (mod n)
+	r := new(big.Int).Lsh(i1, uint(64*s))
+	mc.one = mc.limbs(new(big.Int).Mod(r, modulus), s)
+	mc.rr = mc.limbs(new(big.Int).Mod(new(big.Int).Mul(r, r), modulus), s)
+	return mc
+}
+
+// перевод неотрицательного числа меньше R в s слов
+func (mc *montContext) limbs(x *big.Int, s int) []uint64 {
+	buf := x.FillBytes(make([]byte, 8*s))
+	z := make([]uint64, s)
+	for i := range z {
+		z[i] = binary.BigEndian.Uint64(buf[8*(s-1-i):])
+	}
+	return z
+}
+
+// перевод слов обратно в число
+func (mc *montContext) number(x []uint64) *big.Int {
+	buf := make([]byte, 8*len(x))
+	for i, w := range x {
+		binary.BigEndian.PutUint64(buf[8*(len(x)-1-i):], w)
+	}
+	return new(big.Int).SetBytes(buf)
+}
+
+// Умножение Монтгомери: z = x * y * R^-1 (mod n) (метод CIOS).
+// t - рабочий буфер длиной len(n) + 2, z может совпадать с x или y.
+// Количество операций и обращения к памяти не зависят от значений x и y,
+// поэтому умножение выполняется за постоянное время.
+func (mc *montContext) mul(z, x, y, t []uint64) {
+	n := mc.n
+	s := len(n)
+	for i := range t {
+		t[i] = 0
+	}
+
+	for i := 0; i < s; i++ {
+		// t = t + x[i] * y
+		var c, cc uint64
+		for j := 0; j < s; j++ {
+			hi, lo := bits.Mul64(x[i], y[j])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j], c = lo, hi
+		}
+		t[s], cc = bits.Add64(t[s], c, 0)
+		t[s+1] += cc
+
+		// t = (t + m * n) / 2^64, где m подобрано так, чтобы младшее слово обнулилось
+		m := t[0] * mc.n0inv
+		hi, lo := bits.Mul64(m, n[0])
+		_, cc = bits.Add64(lo, t[0], 0)
+		c = hi + cc
+		for j := 1; j < s; j++ {
+			hi, lo = bits.Mul64(m, n[j])
+			lo, cc = bits.Add64(lo, t[j], 0)
+			hi += cc
+			lo, cc = bits.Add64(lo, c, 0)
+			hi += cc
+			t[j-1], c = lo, hi
+		}
+		t[s-1], cc = bits.Add64(t[s], c, 0)
+		t[s] = t[s+1] + cc
+		t[s+1] = 0
+	}
+
+	// результат меньше 2n: вычитаем n всегда, без ветвлений
+	var b uint64
+	for j := 0; j < s; j++ {
+		t[j], b = bits.Sub64(t[j], n[j], b)
+	}
+	_, b = bits.Sub64(t[s], 0, b)
+
+	// заем b == 1 означает, что результат был меньше n - прибавляем n обратно по маске
+	mask := -b
+	var c uint64
+	for j := 0; j < s; j++ {
+		z[j], c = bits.Add64(t[j], n[j]&mask, c)
+	}
+}
+
+// условный обмен x и y за постоянное время: при bit == 1 значения меняются местами
+func condSwap(x, y []uint64, bit uint64) {
+	mask := -bit
+	for i := range x {
+		d := mask & (x[i] ^ y[i])
+		x[i] ^= d
+		y[i] ^= d
+	}
+}
+
+// размер окна для возведения в степень в зависимости от битовой длины степени
+// подобран так, чтобы предвычисление таблицы окупалось экономией умножений
+func windowSize(bitLen int) int {
+	switch {
+	case bitLen > 671:
+		return 6
+	case bitLen > 239:
+		return 5
+	case bitLen > 79:
+		return 4
+	case bitLen > 23:
+		return 3
+	case bitLen > 6:
+		return 2
+	default:
+		return 1
+	}
+}
+
+// Возведение в степень x ^ pow (mod n) скользящим окном.
+// Степень просматривается по битам напрямую, от старшего к младшему.
+func (mc *montContext) exp(x, pow *big.Int) *big.Int {
+	s := len(mc.n)
+	t := make([]uint64, s+2)
+
+	// переводим основание в представление Монтгомери: a = x * R (mod n)
+	base := new(big.Int).Mod(x, mc.modulus)
+	a := mc.limbs(base, s)
+	mc.mul(a, a, mc.rr, t)
+
+	// таблица нечетных степеней: table[i] = a ^ (2i + 1)
+	w := windowSize(pow.BitLen())
+	table := make([][]uint64, 1<<(w-1))
+	table[0] = a
+	if len(table) > 1 {
+		a2 := make([]uint64, s)
+		mc.mul(a2, a, a, t)
+		for i := 1; i < len(table); i++ {
+			table[i] = make([]uint64, s)
+			mc.mul(table[i], table[i-1], a2, t)
+		}
+	}
+
+	// b = 1 в представлении Монтгомери
+	b := make([]uint64, s)
+	copy(b, mc.one)
+
+	for i := pow.BitLen() - 1; i >= 0; {
+		// нулевой бит - только возводим в квадрат
+		if pow.Bit(i) == 0 {
+			mc.mul(b, b, b, t)
+			i--
+			continue
+		}
+
+		// ищем самое длинное окно не длиннее w бит, которое заканчивается единицей
+		l := i - w + 1
+		if l < 0 {
+			l = 0
+		}
+		for pow.Bit(l) == 0 {
+			l++
+		}
+
+		// значение окна
+		var val uint
+		for j := i; j >= l; j-- {
+			val = val<<1 | pow.Bit(j)
+		}
+
+		// b = b ^ (2 ^ длина окна) * a ^ val
+		for j := i; j >= l; j-- {
+			mc.mul(b, b, b, t)
+		}
+		mc.mul(b, b, table[val>>1], t)
+		i = l - 1
+	}
+
+	// переводим результат из представления Монтгомери: b * 1 * R^-1
+	one := make([]uint64, s)
+	one[0] = 1
+	mc.mul(b, b, one, t)
+	return mc.number(b)
+}
+
+// Возведение в степень x ^ pow (mod n) лестницей Монтгомери за постоянное время.
+// Используется для всех операций с секретной степенью:
+// на каждом бите выполняются ровно одно умножение и одно возведение в квадрат,
+// а выбор операндов делается условным обменом без ветвлений.
+// Количество итераций зависит только от длины модуля (и от длины степени, если она длиннее модуля),
+// поэтому время работы не зависит от значения степени.
+func (mc *montContext) ladder(x, pow *big.Int) *big.Int {
+	s := len(mc.n)
+	t := make([]uint64, s+2)
+
+	// степень в виде слов фиксированной ширины, не меньше ширины модуля
+	width := (pow.BitLen() + 63) / 64
+	if width < s {
+		width = s
+	}
+	d := mc.limbs(pow, width)
+
+	// r1 = x * R (mod n), r0 = 1 * R (mod n)
+	r1 := mc.limbs(new(big.Int).Mod(x, mc.modulus), s)
+	mc.mul(r1, r1, mc.rr, t)
+	r0 := make([]uint64, s)
+	copy(r0, mc.one)
+
+	// инвариант: r1 = r0 * x
+	for i := 64*width - 1; i >= 0; i-- {
+		bit := (d[i/64] >> uint(i%64)) & 1
+		// при bit == 1 работаем с парой (r1, r0) вместо (r0, r1)
+		condSwap(r0, r1, bit)
+		mc.mul(r1, r0, r1, t)
+		mc.mul(r0, r0, r0, t)
+		condSwap(r0, r1, bit)
+	}
+
+	// переводим результат из представления Монтгомери
+	one := make([]uint64, s)
+	one[0] = 1
+	mc.mul(r0, r0, one, t)
+	return mc.number(r0)
+}
+
+// Кэш контекста Монтгомери для ключа.
+// Контекст создается при первом использовании и пересоздается, если модуль изменился.
+type montCache struct {
+	mu  sync.Mutex
+	ctx *montContext
+}
+
+// контекст для модуля n
+// при отсутствии кэша (ключ создан без конструктора) контекст создается заново
+func (c *montCache) get(n *big.Int) *montContext {
+	if c == nil {
+		return newMontContext(n)
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.ctx == nil || c.ctx.modulus.Cmp(n) != 0 {
+		c.ctx = newMontContext(n)
+	}
+	return c.ctx
+}
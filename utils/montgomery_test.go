@@ -0,0 +1,213 @@
+package utils
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math"
+	"math/big"
+	mrand "math/rand"
+	"runtime"
+	"sort"
+	"testing"
+	"time"
+)
+
+// случайный нечетный модуль ровно из bits бит
+func randomOddModulus(tb testing.TB, bits int) *big.Int {
+	tb.Helper()
+	n, err := rand.Int(rand.Reader, new(big.Int).Lsh(i1, uint(bits-1)))
+	if err != nil {
+		tb.Fatal(err)
+	}
+	n.SetBit(n, bits-1, 1)
+	return n.SetBit(n, 0, 1)
+}
+
+// случайное число от 0 до max - 1
+func randomBelow(tb testing.TB, max *big.Int) *big.Int {
+	tb.Helper()
+	x, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return x
+}
+
+// Умножение Монтгомери совпадает с x * y * R^-1 (mod n) в арифметике big.Int
+func TestMontMul(t *testing.T) {
+	for _, bits := range []int{2, 63, 64, 65, 127, 512, 1024, 2048} {
+		n := randomOddModulus(t, bits)
+		mc := newMontContext(n)
+		s := len(mc.n)
+		rInv := new(big.Int).ModInverse(new(big.Int).Lsh(i1, uint(64*s)), n)
+		tmp := make([]uint64, s+2)
+
+		// граничные значения и случайные
+		values := []*big.Int{big.NewInt(0), big.NewInt(1), new(big.Int).Sub(n, i1)}
+		for i := 0; i < 20; i++ {
+			values = append(values, randomBelow(t, n))
+		}
+		for _, x := range values {
+			for _, y := range values {
+				z := make([]uint64, s)
+				mc.mul(z, mc.limbs(x, s), mc.limbs(y, s), tmp)
+
+				want := new(big.Int).Mul(x, y)
+				want.Mul(want, rInv).Mod(want, n)
+				if got := mc.number(z); got.Cmp(want) != 0 {
+					t.Fatalf("%d бит: mul(%s, %s) = %s, ожидалось %s", bits, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+// Скользящее окно, лестница и exp совпадают с big.Int.Exp
+func TestMontExp(t *testing.T) {
+	for _, bits := range []int{2, 64, 65, 521, 1024, 2048} {
+		n := randomOddModulus(t, bits)
+		mc := newMontContext(n)
+
+		bases := []*big.Int{big.NewInt(0), big.NewInt(1), new(big.Int).Sub(n, i1), new(big.Int).Add(n, i2)}
+		pows := []*big.Int{big.NewInt(0), big.NewInt(1), big.NewInt(2), big.NewInt(65537), new(big.Int).Lsh(n, 3)}
+		for i := 0; i < 5; i++ {
+			bases = append(bases, randomBelow(t, n))
+			pows = append(pows, randomBelow(t, n))
+		}
+		for _, x := range bases {
+			for _, pow := range pows {
+				want := new(big.Int).Exp(x, pow, n)
+				if got := mc.exp(x, pow); got.Cmp(want) != 0 {
+					t.Fatalf("%d бит: exp(%s, %s) = %s, ожидалось %s", bits, x, pow, got, want)
+				}
+				if got := mc.ladder(x, pow); got.Cmp(want) != 0 {
+					t.Fatalf("%d бит: ladder(%s, %s) = %s, ожидалось %s", bits, x, pow, got, want)
+				}
+				if got := exp(x, pow, n); got.Cmp(want) != 0 {
+					t.Fatalf("%d бит: exp(%s, %s) = %s, ожидалось %s", bits, x, pow, got, want)
+				}
+			}
+		}
+	}
+
+	// четный модуль обрабатывается бинарным методом
+	n := new(big.Int).Lsh(randomOddModulus(t, 256), 3)
+	x, pow := randomBelow(t, n), randomBelow(t, n)
+	if got, want := exp(x, pow, n), new(big.Int).Exp(x, pow, n); got.Cmp(want) != 0 {
+		t.Fatalf("четный модуль: exp = %s, ожидалось %s", got, want)
+	}
+}
+
+// t-статистика Уэлча для двух выборок
+func welchT(a, b []float64) float64 {
+	mean := func(x []float64) float64 {
+		var sum float64
+		for _, v := range x {
+			sum += v
+		}
+		return sum / float64(len(x))
+	}
+	variance := func(x []float64, m float64) float64 {
+		var sum float64
+		for _, v := range x {
+			sum += (v - m) * (v - m)
+		}
+		return sum / float64(len(x)-1)
+	}
+	ma, mb := mean(a), mean(b)
+	return (ma - mb) / math.Sqrt(variance(a, ma)/float64(len(a))+variance(b, mb)/float64(len(b)))
+}
+
+// Статистический тест постоянного времени лестницы в духе dudect:
+// время на фиксированной степени (единица - крайний случай по весу и длине)
+// сравнивается со временем на случайных полноразмерных степенях.
+// Классы чередуются случайно, чтобы помехи от планировщика и сборщика мусора ложились на оба класса,
+// самые долгие замеры отбрасываются. При зависимости времени от степени |t| быстро растет с числом замеров
+// (скользящее окно exp на этом тесте дает |t| > 100), поэтому порог взят как в dudect: |t| > 10 - явная утечка.
+func TestLadderConstantTime(t *testing.T) {
+	if testing.Short() {
+		t.Skip("статистический тест времени пропускается в режиме -short")
+	}
+	const (
+		measurements = 3000
+		threshold    = 10
+	)
+	n := randomOddModulus(t, 512)
+	mc := newMontContext(n)
+	x := randomBelow(t, n)
+	fixed := big.NewInt(1)
+	random := make([]*big.Int, measurements)
+	for i := range random {
+		random[i] = randomBelow(t, n)
+	}
+
+	classes := mrand.New(mrand.NewSource(1))
+	type sample struct {
+		class    int
+		duration float64
+	}
+	samples := make([]sample, 0, measurements)
+	runtime.GC()
+	for i := 0; i < measurements; i++ {
+		class, pow := 0, fixed
+		if classes.Intn(2) == 1 {
+			class, pow = 1, random[i]
+		}
+		start := time.Now()
+		mc.ladder(x, pow)
+		samples = append(samples, sample{class, float64(time.Since(start))})
+	}
+
+	// отбрасываем 10% самых долгих замеров: это прерывания, а не работа лестницы
+	durations := make([]float64, len(samples))
+	for i, s := range samples {
+		durations[i] = s.duration
+	}
+	sort.Float64s(durations)
+	cutoff := durations[len(durations)*9/10]
+	var groups [2][]float64
+	for _, s := range samples {
+		if s.duration <= cutoff {
+			groups[s.class] = append(groups[s.class], s.duration)
+		}
+	}
+
+	tStat := welchT(groups[0], groups[1])
+	t.Logf("замеров: %d и %d, t = %.2f", len(groups[0]), len(groups[1]), tStat)
+	if math.Abs(tStat) > threshold {
+		t.Fatalf("время лестницы зависит от степени: |t| = %.2f > %d", math.Abs(tStat), threshold)
+	}
+}
+
+// Возведение в полную степень по модулю из 1024, 2048 и 4096 бит:
+// скользящее окно и лестница с готовым контекстом против math/big.Exp
+func BenchmarkMontExp(b *testing.B) {
+	for _, bits := range []int{1024, 2048, 4096} {
+		n := randomOddModulus(b, bits)
+		x, pow := randomBelow(b, n), randomBelow(b, n)
+		mc := newMontContext(n)
+		b.Run(fmt.Sprintf("%d", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				mc.exp(x, pow)
+			}
+		})
+		b.Run(fmt.Sprintf("%d/ladder", bits), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				mc.ladder(x, pow)
+			}
+		})
+	}
+}
+
+func BenchmarkBigExp(b *testing.B) {
+	for _, bits := range []int{1024, 2048, 4096} {
+		n := randomOddModulus(b, bits)
+		x, pow := randomBelow(b, n), randomBelow(b, n)
+		b.Run(fmt.Sprintf("%d", bits), func(b *testing.B) {
+			z := new(big.Int)
+			for i := 0; i < b.N; i++ {
+				z.Exp(x, pow, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,70 @@
+package utils
+
+import (
+	"context"
+	"math/big"
+	"runtime"
+	"sync"
+)
+
+// количество блоков, которое обрабатывается за один раз на каждую горутину
+// ограничивает расход памяти потокового шифрования
+const blocksPerJob = 64
+
+// Количество горутин по умолчанию - по числу доступных ядер
+func DefaultJobs() int {
+	return runtime.GOMAXPROCS(0)
+}
+
+// Поблочная обработка в пуле из jobs горутин.
+// Результат обработки blocks[i] записывается в blocks[i], поэтому порядок блоков сохраняется.
+// f получает номер блока в пачке, чтобы можно было использовать заранее подготовленные для него данные.
+// При отмене контекста обработка прекращается и возвращается ошибка контекста.
+func processBlocks(ctx context.Context, jobs int, blocks []*big.Int, f func(int, *big.Int) *big.Int) error {
+	if jobs < 1 {
+		jobs = 1
+	}
+	if jobs > len(blocks) {
+		jobs = len(blocks)
+	}
+
+	// при одной горутине обходимся без каналов
+	if jobs <= 1 {
+		for i := range blocks {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			blocks[i] = f(i, blocks[i])
+		}
+		return nil
+	}
+
+	// раздаем горутинам индексы блоков
+	indexes := make(chan int)
+	var wg sync.WaitGroup
+	for j := 0; j < jobs; j++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range indexes {
+				blocks[i] = f(i, blocks[i])
+			}
+		}()
+	}
+
+	// прекращаем раздачу, как только контекст отменен
+	var err error
+Feed:
+	for i := range blocks {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			break Feed
+		case indexes <- i:
+		}
+	}
+	close(indexes)
+	wg.Wait()
+
+	return err
+}
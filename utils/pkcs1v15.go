@@ -0,0 +1,94 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/subtle"
+	"fmt"
+	"math/big"
+)
+
+// Подпись RSASSA-PKCS1-v1_5 по RFC 8017 (раздел 8.2) с кодированием EMSA-PKCS1-v1_5 (раздел 9.2).
+// EM = 0x00 || 0x01 || 0xff ... 0xff || 0x00 || DigestInfo, где DigestInfo - DER-структура
+// с идентификатором хэш-функции и самим хэшем.
+// При проверке ожидаемое EM строится заново и сравнивается целиком, а не разбирается из подписи:
+// так исключаются подделки Блейхенбахера (2006) для малых e, основанные на нестрогом разборе DigestInfo.
+
+// DER-префиксы DigestInfo (RFC 8017, раздел 9.2, примечание 1), за ними следует хэш
+var digestInfoPrefixes = map[crypto.Hash][]byte{
+	crypto.SHA256: {0x30, 0x31, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x01, 0x05, 0x00, 0x04, 0x20},
+	crypto.SHA384: {0x30, 0x41, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x02, 0x05, 0x00, 0x04, 0x30},
+	crypto.SHA512: {0x30, 0x51, 0x30, 0x0d, 0x06, 0x09, 0x60, 0x86, 0x48, 0x01, 0x65, 0x03, 0x04, 0x02, 0x03, 0x05, 0x00, 0x04, 0x40},
+}
+
+// Кодирование EMSA-PKCS1-v1_5 хэша digest в emLen байт
+func emsaPKCS1v15Encode(hash crypto.Hash, digest []byte, emLen int) ([]byte, error) {
+	prefix, ok := digestInfoPrefixes[hash]
+	if !ok {
+		return nil, fmt.Errorf("хэш-функция %v не поддерживается для подписи PKCS#1 v1.5", hash)
+	}
+	if len(digest) != hash.Size() {
+		return nil, fmt.Errorf("длина хэша сообщения %d не совпадает с длиной хэш-функции %d", len(digest), hash.Size())
+	}
+
+	// не меньше 8 байт 0xff
+	tLen := len(prefix) + len(digest)
+	if emLen < tLen+11 {
+		return nil, fmt.Errorf("%w: ключ слишком мал для подписи PKCS#1 v1.5 с хэшем %v", ErrMessageTooLong, hash)
+	}
+
+	em := make([]byte, emLen)
+	em[1] = 0x01
+	for i := 2; i < emLen-tLen-1; i++ {
+		em[i] = 0xff
+	}
+	copy(em[emLen-tLen:], prefix)
+	copy(em[emLen-len(digest):], digest)
+	return em, nil
+}
+
+// Подпись RSASSA-PKCS1-v1_5 хэша сообщения digest.
+// Операция с приватным ключом выполняется с ослеплением.
+func (privKey *PrivateKey) SignPKCS1v15(pubKey *PublicKey, hash crypto.Hash, digest []byte) ([]byte, error) {
+	k := chipherBlockSize(pubKey.N)
+	em, err := emsaPKCS1v15Encode(hash, digest, k)
+	if err != nil {
+		return nil, err
+	}
+
+	// s = m ^ d (mod n)
+	s, err := privKey.blindedExpD(new(big.Int).SetBytes(em), pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.FillBytes(make([]byte, k)), nil
+}
+
+// Проверка подписи RSASSA-PKCS1-v1_5 хэша сообщения digest.
+// Возвращает nil, если подпись верна, иначе ErrVerification.
+func (pubKey *PublicKey) VerifyPKCS1v15(hash crypto.Hash, digest, sig []byte) error {
+	k := chipherBlockSize(pubKey.N)
+
+	// ожидаемое кодирование строится до проверки, ошибки параметров сообщаются отдельно
+	expected, err := emsaPKCS1v15Encode(hash, digest, k)
+	if err != nil {
+		return err
+	}
+
+	// длина подписи должна совпадать с длиной модуля
+	if len(sig) != k {
+		return ErrVerification
+	}
+	s := new(big.Int).SetBytes(sig)
+	if s.Cmp(pubKey.N) >= 0 {
+		return ErrVerification
+	}
+
+	// m = s ^ e (mod n), m < n, поэтому помещается в k байт
+	em := pubKey.expE(s).FillBytes(make([]byte, k))
+
+	// сравниваем кодирование целиком
+	if subtle.ConstantTimeCompare(em, expected) != 1 {
+		return ErrVerification
+	}
+	return nil
+}
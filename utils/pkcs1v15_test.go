@@ -0,0 +1,101 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// подпись произвольного кодирования em приватным ключом crypto/rsa без проверок формата
+func rawSign(std *rsa.PrivateKey, em []byte) []byte {
+	s := new(big.Int).Exp(new(big.Int).SetBytes(em), std.D, std.N)
+	return s.FillBytes(make([]byte, len(em)))
+}
+
+var pkcs1v15Hashes = []crypto.Hash{crypto.SHA256, crypto.SHA384, crypto.SHA512}
+
+// Подписи этого пакета проверяются crypto/rsa и наоборот, а сами подписи совпадают байт в байт
+func TestPKCS1v15CrossVerify(t *testing.T) {
+	std, pubKey, privKey := testStdKey(t, 2048)
+	for _, hash := range pkcs1v15Hashes {
+		digest := testDigest(hash, "сообщение для подписи")
+
+		sig, err := privKey.SignPKCS1v15(pubKey, hash, digest)
+		if err != nil {
+			t.Fatalf("%v: %s", hash, err)
+		}
+		if err := rsa.VerifyPKCS1v15(&std.PublicKey, hash, digest, sig); err != nil {
+			t.Fatalf("%v: crypto/rsa не принимает подпись: %s", hash, err)
+		}
+
+		stdSig, err := rsa.SignPKCS1v15(rand.Reader, std, hash, digest)
+		if err != nil {
+			t.Fatalf("%v: %s", hash, err)
+		}
+		if err := pubKey.VerifyPKCS1v15(hash, digest, stdSig); err != nil {
+			t.Fatalf("%v: подпись crypto/rsa не принимается: %s", hash, err)
+		}
+		if !bytes.Equal(sig, stdSig) {
+			t.Fatalf("%v: подписи различаются", hash)
+		}
+	}
+}
+
+// Измененный хэш, чужой OID, мусор после хэша и обрезанная подпись отвергаются с ErrVerification
+func TestPKCS1v15Reject(t *testing.T) {
+	std, pubKey, privKey := testStdKey(t, 2048)
+	k := chipherBlockSize(pubKey.N)
+	for _, hash := range pkcs1v15Hashes {
+		digest := testDigest(hash, "сообщение для подписи")
+		sig, err := privKey.SignPKCS1v15(pubKey, hash, digest)
+		if err != nil {
+			t.Fatalf("%v: %s", hash, err)
+		}
+
+		tampered := append([]byte(nil), digest...)
+		tampered[0] ^= 1
+
+		// тот же хэш под идентификатором SHA-224 (последний байт OID 0x04)
+		em, err := emsaPKCS1v15Encode(hash, digest, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		wrongOID := append([]byte(nil), em...)
+		wrongOID[k-len(digest)-len(digestInfoPrefixes[hash])+14] = 0x04
+
+		// подделка в духе Блейхенбахера: DigestInfo сдвинут влево, за хэшем - мусор
+		garbage := make([]byte, k)
+		copy(garbage, em[:2])
+		copy(garbage[2:], em[10:])
+		copy(garbage[k-8:], bytes.Repeat([]byte{0xab}, 8))
+
+		cases := []struct {
+			name   string
+			digest []byte
+			sig    []byte
+		}{
+			{"измененный хэш", tampered, sig},
+			{"чужой OID", digest, rawSign(std, wrongOID)},
+			{"мусор после хэша", digest, rawSign(std, garbage)},
+			{"обрезанная подпись", digest, sig[:len(sig)-1]},
+			{"подпись без первого байта", digest, sig[1:]},
+			{"пустая подпись", digest, nil},
+		}
+		for _, c := range cases {
+			if err := pubKey.VerifyPKCS1v15(hash, c.digest, c.sig); !errors.Is(err, ErrVerification) {
+				t.Errorf("%v, %s: ошибка %v, ожидалась ErrVerification", hash, c.name, err)
+			}
+			// crypto/rsa отвергает те же подписи
+			if err := rsa.VerifyPKCS1v15(&std.PublicKey, hash, c.digest, c.sig); err == nil {
+				t.Errorf("%v, %s: crypto/rsa принимает подпись", hash, c.name)
+			}
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"math/big"
+)
+
+// Метод Полларда p - 1.
+// Пусть M - произведение всех степеней простых q^k <= B1. Если p - 1 гладкое (все его простые делители
+// в степенях не больше B1), то p - 1 делит M и по малой теореме Ферма a^M = 1 (mod p),
+// поэтому p делит НОД(a^M - 1, n) - это этап 1.
+// Этап 2 (стандартное продолжение) находит p, у которого p - 1 = s * r, где s гладкое до B1,
+// а r - простое из (B1, B2]: перебираем простые r, домножая a^(M * r) на a^(r' - r) для соседних простых,
+// и накапливаем произведение (a^(M * r) - 1) по модулю n.
+// Поэтому простые для RSA должны быть такими, чтобы у p - 1 был большой простой делитель.
+
+// сколько простых обрабатывается между проверками НОД, отмены и прогресса
+const pm1Batch = 1024
+
+// наибольшая разность соседних простых, для которой a^d кэшируется на этапе 2
+const pm1MaxCachedStep = 1 << 12
+
+// Параметры метода p - 1
+type PollardPM1Params struct {
+	// границы гладкости этапов 1 и 2, при B2 <= B1 этап 2 не выполняется
+	B1, B2 uint64
+	// вызывается периодически с номером этапа (1 или 2) и долей выполненной работы этапа от 0 до 1, может быть nil
+	Progress func(stage int, done float64)
+}
+
+// Параметры по умолчанию
+var DefaultPollardPM1Params = PollardPM1Params{B1: 1000000, B2: 100000000}
+
+// Результат метода p - 1
+type PollardPM1Result struct {
+	// множители n, nil - если не найдены
+	P, Q *big.Int
+	// этап, на котором найден множитель, 0 - множитель не найден
+	Stage int
+}
+
+// проверка НОД(x, n): factor - нетривиальный делитель n, all - НОД равен n и нужен откат
+func splitGCD(x, n *big.Int) (factor *big.Int, all bool) {
+	g := new(big.Int).GCD(nil, nil, x, n)
+	switch {
+	case g.Cmp(i1) == 0:
+		return nil, false
+	case g.Cmp(n) == 0:
+		return nil, true
+	}
+	return g, false
+}
+
+// множители n по делителю g: меньший первым
+func orderedFactors(n, g *big.Int) (*big.Int, *big.Int) {
+	q := new(big.Int).Quo(n, g)
+	if g.Cmp(q) > 0 {
+		return q, g
+	}
+	return g, q
+}
+
+// Разложение n методом Полларда p - 1.
+// Если множитель не найден, возвращается результат с P == nil и без ошибки.
+func PollardPM1(ctx context.Context, n *big.Int, params PollardPM1Params) (*PollardPM1Result, error) {
+	if n.Cmp(big.NewInt(4)) < 0 || n.Bit(0) == 0 {
+		return nil, errors.New("n должно быть нечетным и больше 3")
+	}
+	if params.B1 < 2 {
+		return nil, errors.New("граница B1 должна быть не меньше 2")
+	}
+	progress := params.Progress
+	if progress == nil {
+		progress = func(int, float64) {}
+	}
+	result := &PollardPM1Result{}
+	xm1 := new(big.Int)
+
+	// этап 1: a = a^(q^k) для всех простых q <= B1, степени пачки перемножаются в один показатель
+	a := big.NewInt(2)
+	checkpoint := new(big.Int).Set(a)
+	var powers []*big.Int
+	var err error
+	done := false
+	// возведение в степени пачки и проверка НОД
+	flush := func() {
+		exponent := big.NewInt(1)
+		for _, pk := range powers {
+			exponent.Mul(exponent, pk)
+		}
+		a.Exp(a, exponent, n)
+		g, all := splitGCD(xm1.Sub(a, i1), n)
+		if all {
+			// все множители n найдены сразу: повторяем пачку с проверкой после каждой степени
+			a.Set(checkpoint)
+			for _, pk := range powers {
+				a.Exp(a, pk, n)
+				if g, all = splitGCD(xm1.Sub(a, i1), n); g != nil || all {
+					break
+				}
+			}
+		}
+		switch {
+		case g != nil:
+			result.P, result.Q = orderedFactors(n, g)
+			result.Stage = 1
+			done = true
+		case all:
+			// разделить множители не удалось, этап 2 тоже не поможет
+			done = true
+		}
+		checkpoint.Set(a)
+		powers = powers[:0]
+	}
+	forEachPrime(2, params.B1, func(q uint64) bool {
+		pk := q
+		for pk <= params.B1/q {
+			pk *= q
+		}
+		powers = append(powers, new(big.Int).SetUint64(pk))
+		if len(powers) == pm1Batch {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+			flush()
+			progress(1, float64(q)/float64(params.B1))
+		}
+		return !done
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !done && len(powers) > 0 {
+		flush()
+	}
+	if !done {
+		progress(1, 1)
+	}
+	if done || params.B2 <= params.B1 {
+		return result, nil
+	}
+
+	// этап 2: x = a^r для простых r из (B1, B2], acc = Π (x - 1)
+	// x переходит к следующему простому умножением на a^d, d - разность соседних простых,
+	// такие d небольшие и повторяются, поэтому a^d кэшируются
+	steps := make(map[uint64]*big.Int)
+	x := big.NewInt(1)
+	var prev uint64
+	next := func(r uint64) {
+		d := r - prev
+		step, ok := steps[d]
+		if !ok {
+			step = new(big.Int).Exp(a, new(big.Int).SetUint64(d), n)
+			if d <= pm1MaxCachedStep {
+				steps[d] = step
+			}
+		}
+		x.Mul(x, step)
+		x.Mod(x, n)
+		prev = r
+	}
+	acc := big.NewInt(1)
+	// состояние перед текущей пачкой для отката
+	xCheckpoint, prevCheckpoint := new(big.Int).Set(x), prev
+	var batch []uint64
+	check := func() {
+		g, all := splitGCD(acc, n)
+		if all {
+			// повторяем пачку с проверкой после каждого простого
+			x.Set(xCheckpoint)
+			prev = prevCheckpoint
+			for _, r := range batch {
+				next(r)
+				if g, all = splitGCD(xm1.Sub(x, i1), n); g != nil || all {
+					break
+				}
+			}
+		}
+		switch {
+		case g != nil:
+			result.P, result.Q = orderedFactors(n, g)
+			result.Stage = 2
+			done = true
+		case all:
+			done = true
+		}
+		xCheckpoint.Set(x)
+		prevCheckpoint = prev
+		batch = batch[:0]
+		acc.SetInt64(1)
+	}
+	forEachPrime(params.B1+1, params.B2, func(r uint64) bool {
+		next(r)
+		batch = append(batch, r)
+		acc.Mul(acc, xm1.Sub(x, i1))
+		acc.Mod(acc, n)
+		if len(batch) == pm1Batch {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+			check()
+			progress(2, float64(r-params.B1)/float64(params.B2-params.B1))
+		}
+		return !done
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !done && len(batch) > 0 {
+		check()
+	}
+	if !done {
+		progress(2, 1)
+	}
+	return result, nil
+}
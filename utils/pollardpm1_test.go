@@ -0,0 +1,100 @@
+package utils
+
+import (
+	"context"
+	crand "crypto/rand"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// Простое p = k * r + sign из bits бит, где k - произведение 2 и различных случайных простых до bound,
+// так что p - sign гладкое до bound с точностью до множителя r
+func smoothPrime(t *testing.T, random *rand.Rand, bound uint64, bits int, r, sign int64) *big.Int {
+	t.Helper()
+	primes := primesUpTo(bound)
+	for attempt := 0; attempt < 10000; attempt++ {
+		used := map[uint64]bool{2: true}
+		k := big.NewInt(2 * r)
+		for k.BitLen() < bits {
+			q := primes[random.Intn(len(primes))]
+			if used[q] {
+				continue
+			}
+			used[q] = true
+			k.Mul(k, new(big.Int).SetUint64(q))
+		}
+		p := k.Add(k, big.NewInt(sign))
+		if p.ProbablyPrime(20) {
+			return p
+		}
+	}
+	t.Fatal("не удалось построить гладкое простое")
+	return nil
+}
+
+// случайное простое из bits бит из детерминированного источника
+func testPrime(t *testing.T, random *rand.Rand, bits int) *big.Int {
+	t.Helper()
+	p, err := crand.Prime(random, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestPollardPM1(t *testing.T) {
+	random := rand.New(rand.NewSource(1))
+	ctx := context.Background()
+	params := PollardPM1Params{B1: 1000, B2: 100000}
+	q := testPrime(t, random, 128)
+
+	// этап 1: p - 1 гладкое до B1
+	p := smoothPrime(t, random, 1000, 96, 1, 1)
+	result, err := PollardPM1(ctx, new(big.Int).Mul(p, q), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.P == nil || result.P.Cmp(p) != 0 || result.Q.Cmp(q) != 0 || result.Stage != 1 {
+		t.Fatalf("этап 1: %+v", result)
+	}
+
+	// этап 2: p - 1 = s * r, r - простое из (B1, B2]
+	r := nextPrime(big.NewInt(50000)).Int64()
+	p = smoothPrime(t, random, 1000, 96, r, 1)
+	n := new(big.Int).Mul(p, q)
+	result, err = PollardPM1(ctx, n, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.P == nil || result.P.Cmp(p) != 0 || result.Stage != 2 {
+		t.Fatalf("этап 2: %+v", result)
+	}
+
+	// без этапа 2 тот же n не раскладывается
+	result, err = PollardPM1(ctx, n, PollardPM1Params{B1: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.P != nil || result.Stage != 0 {
+		t.Fatalf("без этапа 2: %+v", result)
+	}
+
+	// оба p - 1 и q - 1 гладкие: НОД = n, множитель находится откатом пачки
+	p1, p2 := smoothPrime(t, random, 1000, 96, 1, 1), smoothPrime(t, random, 1000, 96, 1, 1)
+	n = new(big.Int).Mul(p1, p2)
+	result, err = PollardPM1(ctx, n, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.P == nil || new(big.Int).Mul(result.P, result.Q).Cmp(n) != 0 {
+		t.Fatalf("оба множителя гладкие: %+v", result)
+	}
+
+	if _, err := PollardPM1(ctx, big.NewInt(100), params); err == nil {
+		t.Fatal("четное n принято")
+	}
+	if _, err := PollardPM1(ctx, n, PollardPM1Params{B1: 1}); err == nil {
+		t.Fatal("B1 = 1 принято")
+	}
+}
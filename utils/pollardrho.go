@@ -0,0 +1,161 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"math/big"
+	"sync"
+	"sync/atomic"
+)
+
+// Ро-метод Полларда с поиском цикла по Бренту.
+// Последовательность x_{i+1} = x_i^2 + c (mod n) по модулю делителя p зацикливается примерно через sqrt(p) шагов,
+// и тогда НОД(x_i - x_j, n) = p. Брент сравнивает x_j с x_i, где i - последняя степень двойки перед j,
+// а разности |x_i - x_j| перемножаются по пачкам, так что один НОД приходится на целую пачку шагов.
+// Время работы около sqrt(p), поэтому метод годится для учебных модулей и небольших делителей,
+// но не для p из сотен бит.
+
+// Параметры ро-метода
+type PollardRhoParams struct {
+	// количество горутин, каждая со своими случайными c и x_0, 0 - по числу ядер
+	Workers int
+	// количество шагов на один НОД, 0 - значение по умолчанию
+	Batch int
+}
+
+// размер пачки по умолчанию
+const defaultRhoBatch = 128
+
+// Результат ро-метода
+type PollardRhoResult struct {
+	// множители n, nil - если не найдены до истечения срока контекста
+	P, Q *big.Int
+	// общее число шагов всех горутин
+	Iterations int64
+}
+
+// Разложение n ро-методом Полларда-Брента на нескольких горутинах.
+// Работает, пока одна из горутин не найдет делитель или не истечет срок контекста:
+// по истечении срока возвращается результат с P == nil и без ошибки, при отмене - ошибка контекста.
+func PollardRho(ctx context.Context, n *big.Int, params PollardRhoParams) (*PollardRhoResult, error) {
+	if n.Cmp(big.NewInt(4)) < 0 {
+		return nil, errors.New("n должно быть не меньше 4")
+	}
+	if n.ProbablyPrime(20) {
+		return nil, errors.New("n простое, раскладывать нечего")
+	}
+	result := &PollardRhoResult{}
+	if n.Bit(0) == 0 {
+		result.P, result.Q = big.NewInt(2), new(big.Int).Rsh(n, 1)
+		return result, nil
+	}
+	workers, batch := params.Workers, params.Batch
+	if workers < 1 {
+		workers = DefaultJobs()
+	}
+	if batch < 1 {
+		batch = defaultRhoBatch
+	}
+
+	// первая найденная горутиной пара множителей останавливает остальные
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	var iterations int64
+	var once sync.Once
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for runCtx.Err() == nil {
+				// при неудаче (НОД = n) пробуем другие c и x_0
+				g := brentRho(runCtx, n, batch, &iterations)
+				if g != nil {
+					once.Do(func() {
+						result.P, result.Q = orderedFactors(n, g)
+						cancel()
+					})
+					return
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	result.Iterations = atomic.LoadInt64(&iterations)
+
+	if result.P == nil {
+		if err := ctx.Err(); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// Одна попытка Брента со случайными c и x_0.
+// Возвращает нетривиальный делитель n или nil, если НОД оказался равен n или контекст отменен.
+func brentRho(ctx context.Context, n *big.Int, batch int, iterations *int64) *big.Int {
+	// c от 1 до n - 3: при c = 0 и c = -2 последовательность вырождается
+	c, err := rand.Int(rand.Reader, new(big.Int).Sub(n, big.NewInt(3)))
+	if err != nil {
+		return nil
+	}
+	c.Add(c, i1)
+	y, err := rand.Int(rand.Reader, n)
+	if err != nil {
+		return nil
+	}
+
+	// y = y^2 + c (mod n)
+	f := func(v *big.Int) {
+		v.Mul(v, v)
+		v.Add(v, c)
+		v.Mod(v, n)
+	}
+
+	x, ys := new(big.Int), new(big.Int)
+	q := big.NewInt(1)
+	diff := new(big.Int)
+	g := big.NewInt(1)
+	for r := 1; g.Cmp(i1) == 0; r *= 2 {
+		// x - значение на последней степени двойки, y уходит на r шагов вперед
+		x.Set(y)
+		for i := 0; i < r; i++ {
+			f(y)
+		}
+		atomic.AddInt64(iterations, int64(r))
+		for k := 0; k < r && g.Cmp(i1) == 0; k += batch {
+			if ctx.Err() != nil {
+				return nil
+			}
+			ys.Set(y)
+			steps := batch
+			if r-k < steps {
+				steps = r - k
+			}
+			// q = Π |x - y| (mod n) по шагам пачки
+			for i := 0; i < steps; i++ {
+				f(y)
+				q.Mul(q, diff.Sub(x, y).Abs(diff))
+				q.Mod(q, n)
+			}
+			atomic.AddInt64(iterations, int64(steps))
+			g.GCD(nil, nil, q, n)
+		}
+	}
+
+	// НОД пачки равен n: повторяем ее по одному шагу от сохраненного ys
+	if g.Cmp(n) == 0 {
+		for {
+			f(ys)
+			g.GCD(nil, nil, diff.Sub(x, ys).Abs(diff), n)
+			if g.Cmp(i1) != 0 {
+				break
+			}
+		}
+	}
+	if g.Cmp(n) == 0 {
+		return nil
+	}
+	return g
+}
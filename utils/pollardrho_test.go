@@ -0,0 +1,52 @@
+package utils
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+	"time"
+)
+
+func TestPollardRho(t *testing.T) {
+	random := rand.New(rand.NewSource(2))
+	p, q := testPrime(t, random, 32), testPrime(t, random, 64)
+	n := new(big.Int).Mul(p, q)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	for _, params := range []PollardRhoParams{{Workers: 1}, {Workers: 4, Batch: 16}} {
+		result, err := PollardRho(ctx, n, params)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.P == nil || result.P.Cmp(p) != 0 || result.Q.Cmp(q) != 0 || result.Iterations == 0 {
+			t.Fatalf("%+v: %+v", params, result)
+		}
+	}
+
+	// малое n из двух одинаковых простых
+	result, err := PollardRho(ctx, big.NewInt(10007*10007), PollardRhoParams{})
+	if err != nil || result.P == nil || result.P.Int64() != 10007 || result.Q.Int64() != 10007 {
+		t.Fatalf("квадрат простого: %+v, %v", result, err)
+	}
+
+	if _, err := PollardRho(ctx, q, PollardRhoParams{}); err == nil {
+		t.Fatal("простое n принято")
+	}
+
+	// по истечении срока - результат без множителей, при отмене - ошибка
+	big1, big2 := testPrime(t, random, 128), testPrime(t, random, 128)
+	hard := new(big.Int).Mul(big1, big2)
+	expired, cancelExpired := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancelExpired()
+	result, err = PollardRho(expired, hard, PollardRhoParams{Workers: 2})
+	if err != nil || result.P != nil {
+		t.Fatalf("истекший срок: %+v, %v", result, err)
+	}
+	cancelled, cancelNow := context.WithCancel(context.Background())
+	cancelNow()
+	if _, err := PollardRho(cancelled, hard, PollardRhoParams{Workers: 2}); err == nil {
+		t.Fatal("отмена не вернула ошибку")
+	}
+}
@@ -0,0 +1,324 @@
+package utils
+
+import (
+	"math/big"
+	"sort"
+)
+
+// Многочлен от одной переменной с целыми коэффициентами.
+// Коэффициенты хранятся по возрастанию степени: p[i] - коэффициент при x^i.
+type intPoly []*big.Int
+
+// удаление нулевых старших коэффициентов
+func (p intPoly) normalize() intPoly {
+	for len(p) > 0 && p[len(p)-1].Sign() == 0 {
+		p = p[:len(p)-1]
+	}
+	return p
+}
+
+// степень многочлена, у нулевого многочлена -1
+func (p intPoly) degree() int {
+	return len(p.normalize()) - 1
+}
+
+// значение многочлена в точке x по схеме Горнера
+func (p intPoly) eval(x *big.Int) *big.Int {
+	r := new(big.Int)
+	for i := len(p) - 1; i >= 0; i-- {
+		r.Mul(r, x)
+		r.Add(r, p[i])
+	}
+	return r
+}
+
+// производная
+func (p intPoly) derivative() intPoly {
+	if len(p) <= 1 {
+		return nil
+	}
+	d := make(intPoly, len(p)-1)
+	for i := 1; i < len(p); i++ {
+		d[i-1] = new(big.Int).Mul(p[i], big.NewInt(int64(i)))
+	}
+	return d.normalize()
+}
+
+// a + b
+func polyAdd(a, b intPoly) intPoly {
+	if len(a) < len(b) {
+		a, b = b, a
+	}
+	r := make(intPoly, len(a))
+	for i := range a {
+		r[i] = new(big.Int).Set(a[i])
+		if i < len(b) {
+			r[i].Add(r[i], b[i])
+		}
+	}
+	return r.normalize()
+}
+
+// a - b
+func polySub(a, b intPoly) intPoly {
+	nb := make(intPoly, len(b))
+	for i := range b {
+		nb[i] = new(big.Int).Neg(b[i])
+	}
+	return polyAdd(a, nb)
+}
+
+// a * b
+func polyMul(a, b intPoly) intPoly {
+	a, b = a.normalize(), b.normalize()
+	if len(a) == 0 || len(b) == 0 {
+		return nil
+	}
+	r := make(intPoly, len(a)+len(b)-1)
+	for i := range r {
+		r[i] = new(big.Int)
+	}
+	t := new(big.Int)
+	for i := range a {
+		if a[i].Sign() == 0 {
+			continue
+		}
+		for j := range b {
+			r[i+j].Add(r[i+j], t.Mul(a[i], b[j]))
+		}
+	}
+	return r.normalize()
+}
+
+// точное деление a / b, ok == false, если a не делится на b нацело
+func polyExactDiv(a, b intPoly) (intPoly, bool) {
+	a, b = a.normalize(), b.normalize()
+	if len(b) == 0 {
+		return nil, false
+	}
+	if len(a) == 0 {
+		return nil, true
+	}
+	if len(a) < len(b) {
+		return nil, false
+	}
+
+	rem := make(intPoly, len(a))
+	for i := range a {
+		rem[i] = new(big.Int).Set(a[i])
+	}
+	q := make(intPoly, len(a)-len(b)+1)
+	lead := b[len(b)-1]
+	t, m := new(big.Int), new(big.Int)
+	for i := len(q) - 1; i >= 0; i-- {
+		// очередной коэффициент частного: старший коэффициент остатка / старший коэффициент делителя
+		q[i], m = new(big.Int).QuoRem(rem[i+len(b)-1], lead, m)
+		if m.Sign() != 0 {
+			return nil, false
+		}
+		for j := range b {
+			rem[i+j].Sub(rem[i+j], t.Mul(q[i], b[j]))
+		}
+	}
+	if len(rem.normalize()) != 0 {
+		return nil, false
+	}
+	return q.normalize(), true
+}
+
+// Определитель матрицы с элементами из Z[y] методом Барейса без дробей.
+// Все деления в методе точные, поэтому коэффициенты остаются целыми.
+func polyDeterminant(m [][]intPoly) intPoly {
+	n := len(m)
+	a := make([][]intPoly, n)
+	for i := range m {
+		a[i] = append([]intPoly(nil), m[i]...)
+	}
+
+	sign := 1
+	prev := intPoly{big.NewInt(1)}
+	for k := 0; k < n-1; k++ {
+		// ведущий элемент должен быть ненулевым, иначе меняем строки местами
+		if a[k][k].degree() < 0 {
+			swap := -1
+			for i := k + 1; i < n; i++ {
+				if a[i][k].degree() >= 0 {
+					swap = i
+					break
+				}
+			}
+			if swap < 0 {
+				return nil
+			}
+			a[k], a[swap] = a[swap], a[k]
+			sign = -sign
+		}
+		for i := k + 1; i < n; i++ {
+			for j := k + 1; j < n; j++ {
+				// a[i][j] = (a[i][j] * a[k][k] - a[i][k] * a[k][j]) / prev
+				num := polySub(polyMul(a[i][j], a[k][k]), polyMul(a[i][k], a[k][j]))
+				a[i][j], _ = polyExactDiv(num, prev)
+			}
+		}
+		prev = a[k][k]
+	}
+
+	det := a[n-1][n-1].normalize()
+	if sign < 0 {
+		det = polySub(nil, det)
+	}
+	return det
+}
+
+// Многочлен от двух переменных x и y: p[i] - многочлен от y при x^i
+type xyPoly []intPoly
+
+// степень по x
+func (p xyPoly) degreeX() int {
+	for i := len(p) - 1; i >= 0; i-- {
+		if p[i].degree() >= 0 {
+			return i
+		}
+	}
+	return -1
+}
+
+// значение при фиксированном y: многочлен от x
+func (p xyPoly) evalY(y *big.Int) intPoly {
+	r := make(intPoly, len(p))
+	for i := range p {
+		r[i] = p[i].eval(y)
+	}
+	return r.normalize()
+}
+
+// Исключение x из системы p(x, y) = 0, q(x, y) = 0.
+// Возвращает многочлен от y, среди корней которого есть y всех общих корней:
+// результант по x, а если один из многочленов не зависит от x - сам этот многочлен.
+func eliminateX(p, q xyPoly) intPoly {
+	dp, dq := p.degreeX(), q.degreeX()
+	switch {
+	case dp < 0 || dq < 0:
+		return nil
+	case dp == 0:
+		return p[0].normalize()
+	case dq == 0:
+		return q[0].normalize()
+	}
+
+	// матрица Сильвестра: dq строк сдвигов p и dp строк сдвигов q, коэффициенты по убыванию степени
+	size := dp + dq
+	sylvester := make([][]intPoly, size)
+	for r := 0; r < size; r++ {
+		sylvester[r] = make([]intPoly, size)
+	}
+	for r := 0; r < dq; r++ {
+		for i := 0; i <= dp; i++ {
+			sylvester[r][r+i] = p[dp-i]
+		}
+	}
+	for r := 0; r < dp; r++ {
+		for i := 0; i <= dq; i++ {
+			sylvester[dq+r][r+i] = q[dq-i]
+		}
+	}
+	return polyDeterminant(sylvester)
+}
+
+// знак значения многочлена в точке
+func (p intPoly) signAt(x *big.Int) int {
+	return p.eval(x).Sign()
+}
+
+// Целые корни многочлена на отрезке [lo, hi] в порядке возрастания
+func (p intPoly) integerRoots(lo, hi *big.Int) []*big.Int {
+	if p.degree() <= 0 {
+		return nil
+	}
+	var roots []*big.Int
+	for _, c := range p.rootFloors(lo, hi) {
+		if p.signAt(c) == 0 {
+			roots = append(roots, c)
+		}
+	}
+	return roots
+}
+
+// Целые части вещественных корней многочлена на отрезке [lo, hi], возможно с лишними значениями.
+// Между целыми частями корней производной многочлен монотонен, поэтому корень на таком участке
+// один и находится двоичным поиском. Около корня производной может быть два близких корня,
+// поэтому целая часть корня производной добавляется в результат без проверки.
+func (p intPoly) rootFloors(lo, hi *big.Int) []*big.Int {
+	p = p.normalize()
+	if lo.Cmp(hi) > 0 {
+		return nil
+	}
+
+	switch p.degree() {
+	case -1, 0:
+		return nil
+	case 1:
+		// a1 * x + a0 = 0, x = -a0 / a1, целая часть - деление с округлением вниз
+		num, den := new(big.Int).Neg(p[0]), new(big.Int).Set(p[1])
+		if den.Sign() < 0 {
+			num.Neg(num)
+			den.Neg(den)
+		}
+		r := num.Div(num, den)
+		if r.Cmp(lo) < 0 || r.Cmp(hi) > 0 {
+			return nil
+		}
+		return []*big.Int{r}
+	}
+
+	var floors []*big.Int
+	start := lo
+	for _, c := range p.derivative().rootFloors(lo, hi) {
+		// на [start, c] многочлен монотонен
+		floors = append(floors, p.monotoneRootFloor(start, c)...)
+		floors = append(floors, c)
+		start = new(big.Int).Add(c, i1)
+	}
+	floors = append(floors, p.monotoneRootFloor(start, hi)...)
+
+	// сортируем и убираем повторы
+	sort.Slice(floors, func(i, j int) bool { return floors[i].Cmp(floors[j]) < 0 })
+	unique := floors[:0]
+	for _, f := range floors {
+		if len(unique) == 0 || unique[len(unique)-1].Cmp(f) != 0 {
+			unique = append(unique, f)
+		}
+	}
+	return unique
+}
+
+// Целая часть корня монотонного на [u, v] многочлена, если он там есть
+func (p intPoly) monotoneRootFloor(u, v *big.Int) []*big.Int {
+	if u.Cmp(v) > 0 {
+		return nil
+	}
+	su := p.signAt(u)
+	if su == 0 {
+		return []*big.Int{new(big.Int).Set(u)}
+	}
+	if p.signAt(v) == su {
+		return nil
+	}
+
+	// ищем наименьшее w из (u, v], в котором знак уже не su
+	left, right := new(big.Int).Set(u), new(big.Int).Set(v)
+	mid := new(big.Int)
+	for new(big.Int).Sub(right, left).Cmp(i1) > 0 {
+		mid.Add(left, right)
+		mid.Rsh(mid, 1)
+		if p.signAt(mid) == su {
+			left.Set(mid)
+		} else {
+			right.Set(mid)
+		}
+	}
+	if p.signAt(right) == 0 {
+		return []*big.Int{right}
+	}
+	return []*big.Int{left}
+}
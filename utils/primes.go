@@ -0,0 +1,90 @@
+package utils
+
+import "math"
+
+// Перебор малых простых чисел для методов факторизации (p - 1, p + 1, ECM, квадратичное решето).
+
+// размер сегмента решета
+const sieveSegment = 1 << 18
+
+// Целая часть квадратного корня для uint64
+func isqrt64(n uint64) uint64 {
+	r := uint64(math.Sqrt(float64(n)))
+	// у n около 2^64 округление дает r = 2^32, и r * r переполняется
+	if r > math.MaxUint32 {
+		r = math.MaxUint32
+	}
+	// float64 точен только до 2^53, поправляем результат
+	for r*r > n {
+		r--
+	}
+	for r < math.MaxUint32 && (r+1)*(r+1) <= n {
+		r++
+	}
+	return r
+}
+
+// Все простые числа до limit включительно (решето Эратосфена)
+func primesUpTo(limit uint64) []uint64 {
+	if limit < 2 {
+		return nil
+	}
+	composite := make([]bool, limit+1)
+	var primes []uint64
+	for i := uint64(2); i <= limit; i++ {
+		if composite[i] {
+			continue
+		}
+		primes = append(primes, i)
+		for j := i * i; j <= limit; j += i {
+			composite[j] = true
+		}
+	}
+	return primes
+}
+
+// Перебор простых чисел из [lo, hi] по возрастанию сегментированным решетом Эратосфена,
+// поэтому память не зависит от hi. Перебор останавливается, если f возвращает false.
+func forEachPrime(lo, hi uint64, f func(p uint64) bool) {
+	if lo < 2 {
+		lo = 2
+	}
+	if lo > hi {
+		return
+	}
+	base := primesUpTo(isqrt64(hi))
+	composite := make([]bool, sieveSegment)
+	for start := lo; start <= hi; start += sieveSegment {
+		end := start + sieveSegment - 1
+		if end > hi || end < start {
+			end = hi
+		}
+		for i := range composite {
+			composite[i] = false
+		}
+		for _, p := range base {
+			if p*p > end {
+				break
+			}
+			// первое кратное p в сегменте, но не меньше p^2
+			m := (start + p - 1) / p * p
+			if m < p*p {
+				m = p * p
+			}
+			for ; m <= end; m += p {
+				composite[m-start] = true
+			}
+		}
+		for i := start; ; i++ {
+			if !composite[i-start] && !f(i) {
+				return
+			}
+			if i == end {
+				break
+			}
+		}
+		if end == hi {
+			return
+		}
+	}
+}
@@ -0,0 +1,62 @@
+package utils
+
+import (
+	"math"
+	"testing"
+)
+
+func TestIsqrt64(t *testing.T) {
+	for _, n := range []uint64{0, 1, 2, 3, 4, 8, 9, 10, 1<<53 + 1, 1<<62 - 1, 1 << 62, math.MaxUint32 * math.MaxUint32, math.MaxUint64} {
+		r := isqrt64(n)
+		// (r + 1)^2 может переполниться только при r = 2^32 - 1
+		if r*r > n || (r < math.MaxUint32 && (r+1)*(r+1) <= n) {
+			t.Fatalf("isqrt64(%d) = %d", n, r)
+		}
+	}
+}
+
+// Сегментированное решето совпадает с обычным на отрезках, пересекающих границы сегментов
+func TestForEachPrime(t *testing.T) {
+	all := primesUpTo(3*sieveSegment + 1000)
+	ranges := [][2]uint64{
+		{0, 100},
+		{0, 3*sieveSegment + 1000},
+		{sieveSegment - 100, sieveSegment + 100},
+		{2*sieveSegment - 1, 2*sieveSegment + 1},
+		{sieveSegment, sieveSegment},
+		{17, 17},
+		{18, 18},
+		{100, 50},
+	}
+	for _, r := range ranges {
+		var want []uint64
+		for _, p := range all {
+			if p >= r[0] && p <= r[1] {
+				want = append(want, p)
+			}
+		}
+		var got []uint64
+		forEachPrime(r[0], r[1], func(p uint64) bool {
+			got = append(got, p)
+			return true
+		})
+		if len(got) != len(want) {
+			t.Fatalf("[%d, %d]: %d простых, ожидалось %d", r[0], r[1], len(got), len(want))
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("[%d, %d]: простое %d: %d, ожидалось %d", r[0], r[1], i, got[i], want[i])
+			}
+		}
+	}
+
+	// остановка перебора по возврату false
+	count := 0
+	forEachPrime(0, 3*sieveSegment, func(p uint64) bool {
+		count++
+		return count < 10
+	})
+	if count != 10 {
+		t.Fatalf("перебор не остановился: %d простых", count)
+	}
+}
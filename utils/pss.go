@@ -0,0 +1,266 @@
+package utils
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"fmt"
+	"hash"
+	"io"
+	"math/big"
+
+	// регистрируем поддерживаемые хэш-функции для crypto.Hash.New
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// Подпись RSASSA-PSS по RFC 8017 (раздел 8.1) с кодированием EMSA-PSS (раздел 9.1).
+
+const (
+	// длина соли при подписи - максимально возможная, при проверке - определяется по подписи
+	PSSSaltLengthAuto = 0
+	// длина соли равна длине хэша
+	PSSSaltLengthEqualsHash = -1
+)
+
+// внутреннее значение для проверки: длина соли определяется по подписи
+const pssSaltLengthDetect = -1
+
+// Параметры подписи PSS
+type PSSOptions struct {
+	// хэш-функция для сообщения, MGF1 и кодирования
+	Hash crypto.Hash
+	// длина соли в байтах, либо PSSSaltLengthAuto / PSSSaltLengthEqualsHash
+	SaltLength int
+}
+
+// длина соли для хэш-функции с длиной hLen при длине кодированного сообщения emLen
+func (opts *PSSOptions) saltLength(hLen, emLen int) int {
+	switch opts.SaltLength {
+	case PSSSaltLengthAuto:
+		return emLen - hLen - 2
+	case PSSSaltLengthEqualsHash:
+		return hLen
+	}
+	return opts.SaltLength
+}
+
+// проверка, что хэш-функция доступна
+func checkHash(h crypto.Hash) error {
+	if !h.Available() {
+		return fmt.Errorf("хэш-функция %v не поддерживается", h)
+	}
+	return nil
+}
+
+// Функция генерации маски MGF1 (RFC 8017, приложение B.2.1)
+// результат записывается в out: out ^= MGF1(seed, len(out))
+func mgf1XOR(out []byte, h hash.Hash, seed []byte) {
+	var counter [4]byte
+	var digest []byte
+	done := 0
+	for done < len(out) {
+		h.Reset()
+		h.Write(seed)
+		h.Write(counter[:])
+		digest = h.Sum(digest[:0])
+		for i := 0; i < len(digest) && done < len(out); i++ {
+			out[done] ^= digest[i]
+			done++
+		}
+		// увеличиваем 4-байтовый счетчик
+		for i := 3; i >= 0; i-- {
+			counter[i]++
+			if counter[i] != 0 {
+				break
+			}
+		}
+	}
+}
+
+// Кодирование EMSA-PSS (RFC 8017, раздел 9.1.1)
+func emsaPSSEncode(mHash []byte, emBits int, salt []byte, h hash.Hash) ([]byte, error) {
+	hLen := h.Size()
+	sLen := len(salt)
+	emLen := (emBits + 7) / 8
+
+	if len(mHash) != hLen {
+		return nil, fmt.Errorf("длина хэша сообщения %d не совпадает с длиной хэш-функции %d", len(mHash), hLen)
+	}
+	if emLen < hLen+sLen+2 {
+		return nil, fmt.Errorf("%w: ключ слишком мал для подписи PSS с такими хэшем и солью", ErrMessageTooLong)
+	}
+
+	em := make([]byte, emLen)
+	// DB = PS || 0x01 || salt, H - последние hLen байт перед 0xbc
+	db := em[:emLen-hLen-1]
+	hashOut := em[emLen-hLen-1 : emLen-1]
+
+	// H = Hash(0x00 * 8 || mHash || salt)
+	var prefix [8]byte
+	h.Reset()
+	h.Write(prefix[:])
+	h.Write(mHash)
+	h.Write(salt)
+	h.Sum(hashOut[:0])
+
+	// PS состоит из нулей, которые уже в буфере
+	db[emLen-sLen-hLen-2] = 0x01
+	copy(db[emLen-sLen-hLen-1:], salt)
+
+	// maskedDB = DB xor MGF1(H)
+	mgf1XOR(db, h, hashOut)
+
+	// обнуляем старшие 8 * emLen - emBits бит
+	db[0] &= 0xff >> uint(8*emLen-emBits)
+
+	em[emLen-1] = 0xbc
+	return em, nil
+}
+
+// Проверка кодирования EMSA-PSS (RFC 8017, раздел 9.1.2)
+// sLen - точная длина соли или pssSaltLengthDetect
+func emsaPSSVerify(mHash, em []byte, emBits, sLen int, h hash.Hash) error {
+	hLen := h.Size()
+	emLen := (emBits + 7) / 8
+	if len(mHash) != hLen || emLen != len(em) {
+		return ErrVerification
+	}
+	if emLen < hLen+2 {
+		return ErrVerification
+	}
+
+	if em[emLen-1] != 0xbc {
+		return ErrVerification
+	}
+
+	// копируем maskedDB, чтобы не портить входные данные
+	db := append([]byte(nil), em[:emLen-hLen-1]...)
+	hashIn := em[emLen-hLen-1 : emLen-1]
+
+	// старшие биты должны быть нулевыми
+	topMask := byte(0xff >> uint(8*emLen-emBits))
+	if db[0]&^topMask != 0 {
+		return ErrVerification
+	}
+
+	// DB = maskedDB xor MGF1(H)
+	mgf1XOR(db, h, hashIn)
+	db[0] &= topMask
+
+	// при автоматической длине соли ищем разделитель 0x01 после нулей
+	if sLen == pssSaltLengthDetect {
+		sep := bytes.IndexByte(db, 0x01)
+		if sep < 0 {
+			return ErrVerification
+		}
+		sLen = len(db) - sep - 1
+	}
+	if sLen < 0 || emLen < hLen+sLen+2 {
+		return ErrVerification
+	}
+
+	// PS должно состоять из нулей, за ним - 0x01
+	psLen := emLen - hLen - sLen - 2
+	for _, b := range db[:psLen] {
+		if b != 0 {
+			return ErrVerification
+		}
+	}
+	if db[psLen] != 0x01 {
+		return ErrVerification
+	}
+	salt := db[len(db)-sLen:]
+
+	// H' = Hash(0x00 * 8 || mHash || salt)
+	var prefix [8]byte
+	h.Reset()
+	h.Write(prefix[:])
+	h.Write(mHash)
+	h.Write(salt)
+	if !bytes.Equal(h.Sum(nil), hashIn) {
+		return ErrVerification
+	}
+	return nil
+}
+
+// Подпись RSASSA-PSS хэша сообщения digest.
+// random - источник соли, nil - crypto/rand.Reader.
+// Операция с приватным ключом выполняется с ослеплением.
+func (privKey *PrivateKey) SignPSS(random io.Reader, pubKey *PublicKey, digest []byte, opts *PSSOptions) ([]byte, error) {
+	if err := checkHash(opts.Hash); err != nil {
+		return nil, err
+	}
+	if random == nil {
+		random = rand.Reader
+	}
+	h := opts.Hash.New()
+
+	// emBits = modBits - 1, чтобы кодированное сообщение было меньше n
+	emBits := pubKey.N.BitLen() - 1
+	emLen := (emBits + 7) / 8
+	sLen := opts.saltLength(h.Size(), emLen)
+	if sLen < 0 {
+		return nil, fmt.Errorf("%w: ключ слишком мал для подписи PSS с хэшем %v", ErrMessageTooLong, opts.Hash)
+	}
+
+	salt := make([]byte, sLen)
+	if _, err := io.ReadFull(random, salt); err != nil {
+		return nil, err
+	}
+
+	em, err := emsaPSSEncode(digest, emBits, salt, h)
+	if err != nil {
+		return nil, err
+	}
+
+	// s = m ^ d (mod n)
+	s, err := privKey.blindedExpD(new(big.Int).SetBytes(em), pubKey)
+	if err != nil {
+		return nil, err
+	}
+	return s.FillBytes(make([]byte, chipherBlockSize(pubKey.N))), nil
+}
+
+// Проверка подписи RSASSA-PSS хэша сообщения digest.
+// Возвращает nil, если подпись верна, иначе ErrVerification.
+func (pubKey *PublicKey) VerifyPSS(digest, sig []byte, opts *PSSOptions) error {
+	if err := checkHash(opts.Hash); err != nil {
+		return err
+	}
+	h := opts.Hash.New()
+
+	sLen := opts.SaltLength
+	switch sLen {
+	case PSSSaltLengthAuto:
+		sLen = pssSaltLengthDetect
+	case PSSSaltLengthEqualsHash:
+		sLen = h.Size()
+	}
+	return pubKey.verifyPSS(digest, sig, sLen, h)
+}
+
+// Проверка подписи RSASSA-PSS с точной длиной соли sLen или pssSaltLengthDetect
+func (pubKey *PublicKey) verifyPSS(digest, sig []byte, sLen int, h hash.Hash) error {
+	// длина подписи должна совпадать с длиной модуля
+	if len(sig) != chipherBlockSize(pubKey.N) {
+		return ErrVerification
+	}
+	s := new(big.Int).SetBytes(sig)
+	if s.Cmp(pubKey.N) >= 0 {
+		return ErrVerification
+	}
+
+	// m = s ^ e (mod n)
+	m := pubKey.expE(s)
+
+	// кодированное сообщение должно помещаться в emLen байт
+	emBits := pubKey.N.BitLen() - 1
+	emLen := (emBits + 7) / 8
+	if m.BitLen() > 8*emLen {
+		return ErrVerification
+	}
+	em := m.FillBytes(make([]byte, emLen))
+
+	return emsaPSSVerify(digest, em, emBits, sLen, h)
+}
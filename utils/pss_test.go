@@ -0,0 +1,106 @@
+package utils
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"math/big"
+	"testing"
+
+	_ "crypto/sha256"
+	_ "crypto/sha512"
+)
+
+// ключ crypto/rsa из bits бит и тот же ключ в типах этого пакета
+func testStdKey(t *testing.T, bits int) (*rsa.PrivateKey, *PublicKey, *PrivateKey) {
+	t.Helper()
+	std, err := rsa.GenerateKey(rand.Reader, bits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return std, NewPublicKey(big.NewInt(int64(std.E)), std.N), NewPrivateKey(std.D)
+}
+
+// хэш тестового сообщения
+func testDigest(hash crypto.Hash, msg string) []byte {
+	h := hash.New()
+	h.Write([]byte(msg))
+	return h.Sum(nil)
+}
+
+// Подписи этого пакета проверяются crypto/rsa и наоборот при длине модуля, кратной и не кратной 8
+func TestPSSCrossVerify(t *testing.T) {
+	for _, bits := range []int{1024, 1025, 2047, 2048} {
+		std, pubKey, privKey := testStdKey(t, bits)
+		for _, hash := range []crypto.Hash{crypto.SHA256, crypto.SHA384} {
+			digest := testDigest(hash, "сообщение для подписи")
+			for _, saltLength := range []int{PSSSaltLengthAuto, PSSSaltLengthEqualsHash, 10} {
+				opts := &PSSOptions{Hash: hash, SaltLength: saltLength}
+				stdOpts := &rsa.PSSOptions{Hash: hash, SaltLength: saltLength}
+
+				sig, err := privKey.SignPSS(nil, pubKey, digest, opts)
+				if err != nil {
+					t.Fatalf("%d бит, %v, соль %d: %s", bits, hash, saltLength, err)
+				}
+				if err := rsa.VerifyPSS(&std.PublicKey, hash, digest, sig, stdOpts); err != nil {
+					t.Fatalf("%d бит, %v, соль %d: crypto/rsa не принимает подпись: %s", bits, hash, saltLength, err)
+				}
+
+				stdSig, err := rsa.SignPSS(rand.Reader, std, hash, digest, stdOpts)
+				if err != nil {
+					t.Fatalf("%d бит, %v, соль %d: %s", bits, hash, saltLength, err)
+				}
+				if err := pubKey.VerifyPSS(digest, stdSig, opts); err != nil {
+					t.Fatalf("%d бит, %v, соль %d: подпись crypto/rsa не принимается: %s", bits, hash, saltLength, err)
+				}
+			}
+		}
+	}
+}
+
+// Чужая длина соли, измененный бит подписи или хэша и короткая подпись отвергаются с ErrVerification
+func TestPSSReject(t *testing.T) {
+	std, pubKey, privKey := testStdKey(t, 2048)
+	hash := crypto.SHA256
+	digest := testDigest(hash, "сообщение для подписи")
+	opts := &PSSOptions{Hash: hash, SaltLength: 10}
+	sig, err := privKey.SignPSS(nil, pubKey, digest, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flipped := append([]byte(nil), sig...)
+	flipped[len(flipped)/2] ^= 0x10
+	tampered := append([]byte(nil), digest...)
+	tampered[0] ^= 1
+
+	cases := []struct {
+		name       string
+		digest     []byte
+		sig        []byte
+		saltLength int
+	}{
+		{"соль 20 вместо 10", digest, sig, 20},
+		{"соль длиной хэша вместо 10", digest, sig, PSSSaltLengthEqualsHash},
+		{"измененный бит подписи", digest, flipped, 10},
+		{"измененный хэш", tampered, sig, 10},
+		{"короткая подпись", digest, sig[:len(sig)-1], 10},
+		{"пустая подпись", digest, nil, PSSSaltLengthAuto},
+	}
+	for _, c := range cases {
+		err := pubKey.VerifyPSS(c.digest, c.sig, &PSSOptions{Hash: hash, SaltLength: c.saltLength})
+		if !errors.Is(err, ErrVerification) {
+			t.Errorf("%s: ошибка %v, ожидалась ErrVerification", c.name, err)
+		}
+		// crypto/rsa отвергает те же подписи
+		if err := rsa.VerifyPSS(&std.PublicKey, hash, c.digest, c.sig, &rsa.PSSOptions{Hash: hash, SaltLength: c.saltLength}); err == nil {
+			t.Errorf("%s: crypto/rsa принимает подпись", c.name)
+		}
+	}
+
+	// с определением длины соли подпись принимается
+	if err := pubKey.VerifyPSS(digest, sig, &PSSOptions{Hash: hash, SaltLength: PSSSaltLengthAuto}); err != nil {
+		t.Fatalf("определение длины соли: %s", err)
+	}
+}
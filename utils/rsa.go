@@ -1,10 +1,13 @@
 package utils
 
 import (
+	"bytes"
 	"crypto/rand"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
+	"strings"
 )
 
 var (
@@ -20,34 +23,143 @@ var (
 type PublicKey struct {
 	E *big.Int
 	N *big.Int
+	// кэш контекста Монтгомери для n
+	mont *montCache
 }
 
 // Тип для представления приватного ключа
 type PrivateKey struct {
 	D *big.Int
+	// источник случайности для ослепления, по умолчанию crypto/rand.Reader
+	// подробнее в utils/blinding.go
+	Rand io.Reader
+	// ослеплять ли дополнительно степень d при каждой операции
+	ExponentBlinding bool
+	// кэш контекста Монтгомери для n из парного публичного ключа
+	mont *montCache
 }
 
 // "Конструктор" для инициализации публичного ключа
 func NewPublicKey(e, n *big.Int) *PublicKey {
 	return &PublicKey{
-		E: e,
-		N: n,
+		E:    e,
+		N:    n,
+		mont: new(montCache),
 	}
 }
 
 // "Конструктор" для инициализации приватного ключа
 func NewPrivateKey(d *big.Int) *PrivateKey {
 	return &PrivateKey{
-		D: d,
+		D:    d,
+		mont: new(montCache),
 	}
 }
 
+// m ^ e (mod n) с кэшированным контекстом Монтгомери
+func (pubKey *PublicKey) expE(m *big.Int) *big.Int {
+	if pubKey.N.Bit(0) == 0 || pubKey.N.Cmp(i1) == 0 {
+		return exp(m, pubKey.E, pubKey.N)
+	}
+	return pubKey.mont.get(pubKey.N).exp(m, pubKey.E)
+}
+
+// c ^ d (mod n) с кэшированным контекстом Монтгомери
+// степень секретная, поэтому используется лестница Монтгомери за постоянное время
+// d передается отдельно, потому что может быть ослеплена
+func (privKey *PrivateKey) expD(c, d, n *big.Int) *big.Int {
+	if n.Bit(0) == 0 || n.Cmp(i1) == 0 {
+		return exp(c, d, n)
+	}
+	return privKey.mont.get(n).ladder(c, d)
+}
+
+// Приватная степень по простым множителям n, nil - если e не обратимо
+// при p == q функция Эйлера φ(p^2) = p * (p - 1)
+func privateExponent(e, p, q *big.Int) *big.Int {
+	phi := new(big.Int).Sub(q, i1)
+	if p.Cmp(q) == 0 {
+		phi.Mul(phi, p)
+	} else {
+		phi.Mul(phi, new(big.Int).Sub(p, i1))
+	}
+	return new(big.Int).ModInverse(e, phi)
+}
+
+// Восстановление приватного ключа по найденному простому множителю p модуля n
+// возвращает ключ и второй множитель q = n / p
+func PrivateKeyFromFactor(pubKey *PublicKey, p *big.Int) (*PrivateKey, *big.Int, error) {
+	if p.Cmp(i1) <= 0 || p.Cmp(pubKey.N) >= 0 {
+		return nil, nil, fmt.Errorf("%s не является нетривиальным делителем n", p)
+	}
+	q, r := new(big.Int).QuoRem(pubKey.N, p, new(big.Int))
+	if r.Sign() != 0 {
+		return nil, nil, fmt.Errorf("%s не является делителем n", p)
+	}
+	d := privateExponent(pubKey.E, p, q)
+	if d == nil {
+		return nil, nil, fmt.Errorf("%w: e не обратимо по модулю φ(n)", ErrKeyFormat)
+	}
+	return NewPrivateKey(d), q, nil
+}
+
+// Восстановление приватного ключа по полному разложению n на простые множители
+// множители могут повторяться (n = p^2 * q и т.п.) и идти в любом порядке
+func PrivateKeyFromPrimes(pubKey *PublicKey, primes []*big.Int) (*PrivateKey, error) {
+	// φ(n) = Π p^(k-1) * (p - 1) по различным простым p в степени k
+	product := big.NewInt(1)
+	phi := big.NewInt(1)
+	seen := make(map[string]bool, len(primes))
+	for _, p := range primes {
+		if p.Cmp(i1) <= 0 || !p.ProbablyPrime(20) {
+			return nil, fmt.Errorf("%s не является простым числом", p)
+		}
+		product.Mul(product, p)
+		if seen[p.String()] {
+			phi.Mul(phi, p)
+		} else {
+			seen[p.String()] = true
+			phi.Mul(phi, new(big.Int).Sub(p, i1))
+		}
+	}
+	if product.Cmp(pubKey.N) != 0 {
+		return nil, errors.New("произведение множителей не равно n")
+	}
+	d := new(big.Int).ModInverse(pubKey.E, phi)
+	if d == nil {
+		return nil, fmt.Errorf("%w: e не обратимо по модулю φ(n)", ErrKeyFormat)
+	}
+	return NewPrivateKey(d), nil
+}
+
 // Процедура генерации ключевой пары
 func GenerateKeyPair() (*PublicKey, *PrivateKey, error) {
+	return generateKeyPair(bitLenght, bitLenght, minDiffLenght, 0)
+}
+
+// Процедура генерации учебной ключевой пары с модулем n ровно из bits бит.
+// Такие ключи (сотни бит) раскладываются атаками из этого пакета и нужны только для их проверки.
+func GenerateKeyPairBits(bits int) (*PublicKey, *PrivateKey, error) {
+	if bits < 64 {
+		return nil, nil, errors.New("длина модуля должна быть не меньше 64 бит")
+	}
+	// generatePrimeNumber(k) дает простое из k + 1 бит, |p - q| - как у обычного ключа, около четверти длины p
+	return generateKeyPair(bits/2-1, bits-bits/2-1, bits/8+1, bits)
+}
+
+// Генерация ключевой пары из простых p и q длиной pBits + 1 и qBits + 1 бит с |p - q| не короче minDiff бит,
+// при nBits > 0 n должно быть ровно из nBits бит
+func generateKeyPair(pBits, qBits, minDiff, nBits int) (*PublicKey, *PrivateKey, error) {
 Primes:
 	// генерируем простые числа p и q
-	p := generatePrimeNumber(bitLenght)
-	q := generatePrimeNumber(bitLenght)
+	p, err := generatePrimeNumber(pBits)
+	if err != nil {
+		return nil, nil, err
+	}
+	q, err := generatePrimeNumber(qBits)
+	if err != nil {
+		return nil, nil, err
+	}
 
 	// вычисляем их разность
 	subPQ := new(big.Int).Sub(p, q)
@@ -56,12 +168,15 @@ Primes:
 	}
 
 	// если их разность маленькое число - повторяем процедуру генерации
-	if subPQ.BitLen() < minDiffLenght {
+	if subPQ.BitLen() < minDiff {
 		goto Primes
 	}
 
 	// n = p * q
 	n := new(big.Int).Mul(p, q)
+	if nBits > 0 && n.BitLen() != nBits {
+		goto Primes
+	}
 	// φ(n) = (p - 1) * (q - 1)
 	phiN := new(big.Int).Mul(new(big.Int).Sub(p, i1), new(big.Int).Sub(q, i1))
 
@@ -98,129 +213,111 @@ Start:
 	return NewPublicKey(e, n), NewPrivateKey(d), nil
 }
 
-// процедура шифрования
-func (pubKey *PublicKey) ShipherBytes(M []byte) string {
-	// инициализируем переменные для хранения шифра и m
-	var bitsM string
-	var chipher string
-	m := []string{}
-
-	// m := [][]byte{}
-
-	// получаем log2(n) с округлением в меньшую сторону
-	// размер блока
-	logN := log2(pubKey.N)
-
-	// переводим в битовое представление
-	for _, bytes := range M {
-		bitsM += fmt.Sprintf("%08b", bytes)
-	}
-
-	// разбиваем на блоки log2(n) битовое представление сообщения
-	var i int64
-	for i = int64(len(bitsM)); i > int64(0); i -= logN {
-		// инициализируем переменную для хранения блока
-		var blockM string
-		// проверяем что блок не последний
-		if i-logN < 0 {
-			// если последний - отрезаем до конца
-			blockM = bitsM[:i]
-		} else {
-			blockM = bitsM[i-logN : i]
-		}
-		// добавляем блок в массив блоков
-		m = append(m, blockM)
+// Разбор публичного ключа из содержимого файла: e и n в десятичном виде на двух строках
+func ParsePublicKey(data []byte) (*PublicKey, error) {
+	// Разбиваем на подстроки по переносу строки
+	keyItems := strings.Split(string(data), "\n")
+	// Проверяем что строк в файле было 2, если нет - вернуть ошибку
+	if len(keyItems) != 2 {
+		return nil, fmt.Errorf("%w: неверное количество строк %d в публичном ключе, должно быть 2", ErrKeyFormat, len(keyItems))
 	}
-
-	// вычисляем длину последнего блока сообщения
-	l := int64(len(m[len(m)-1]))
-
-	// если последний блок меньше чем нужно
-	// дополняем его нолями слева
-	if l != logN {
-		var blockM string
-		for i := l; i < logN; i++ {
-			blockM += "0"
-		}
-		m[len(m)-1] = blockM + m[len(m)-1]
+	// Переводим строковое представление e в число
+	e, ok := new(big.Int).SetString(keyItems[0], 10)
+	// Если перевести в число не удалось - вернуть ошибку
+	if !ok || e.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: e должно быть положительным числом в десятичном представлении на первой строке", ErrKeyFormat)
 	}
+	// Переводим строковое представление n в число
+	n, ok := new(big.Int).SetString(keyItems[1], 10)
+	// Если перевести в число не удалось - вернуть ошибку
+	if !ok || n.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: n должно быть положительным числом в десятичном представлении на второй строке", ErrKeyFormat)
+	}
+	// в блок открытого текста должен помещаться хотя бы один байт
+	if plainBlockSize(n) == 0 {
+		return nil, fmt.Errorf("%w: модуль n слишком мал для шифрования: %d бит", ErrKeyFormat, n.BitLen())
+	}
+	// инициализируем и возвращаем публичный ключ
+	return NewPublicKey(e, n), nil
+}
 
-	// шифруем поблочно
-	for _, mBytes := range m {
-		// блок переводим в целое число
-		mBlock, _ := new(big.Int).SetString(mBytes, 2)
-		chiperbits := exp(mBlock, pubKey.E, pubKey.N).Text(2)
-
-		if int64(len(chiperbits)) < int64(logN+1) {
-			for i := len(chiperbits); i < int(logN)+1; i++ {
-				chiperbits = "0" + chiperbits
-			}
-		}
-
-		// вычисляем значени m * e (mod n) и записываем в массив
-		chipher += chiperbits
+// Разбор приватного ключа из содержимого файла: d в десятичном виде на одной строке
+func ParsePrivateKey(data []byte) (*PrivateKey, error) {
+	// Разбиваем на подстроки по переносу строки
+	keyItems := strings.Split(string(data), "\n")
+	// Проверяем что в файле была 1 строка, если нет - вернуть ошибку
+	if len(keyItems) != 1 {
+		return nil, fmt.Errorf("%w: неверное количество строк %d в приватном ключе, должно быть 1", ErrKeyFormat, len(keyItems))
 	}
+	// Переводим строковое представление в число d
+	d, ok := new(big.Int).SetString(keyItems[0], 10)
+	// Если перевести в число не удалось или d <= 0 - вернуть ошибку
+	if !ok || d.Sign() <= 0 {
+		return nil, fmt.Errorf("%w: d должно быть положительным числом в десятичном представлении", ErrKeyFormat)
+	}
+	return NewPrivateKey(d), nil
+}
 
-	// возврат массима блоков шифра
-	return chipher
+// Проверка, что приватный ключ соответствует публичному:
+// зашифровываем число 2 и проверяем, что оно расшифровывается обратно
+func (privKey *PrivateKey) Check(pubKey *PublicKey) error {
+	c := pubKey.expE(i2)
+	m, err := privKey.blindedExpD(c, pubKey)
+	if err != nil {
+		return err
+	}
+	if m.Cmp(new(big.Int).Mod(i2, pubKey.N)) != 0 {
+		return ErrKeyMismatch
+	}
+	return nil
 }
 
-// процедура расшифрования
-func (privKey *PrivateKey) DeShipherBytes(chiper string, pubKey *PublicKey) []byte {
-	// инициализируем переменные для хранения М
-	bitM := []string{}
-	M := []byte{}
-	chipherBlocks := []string{}
-
-	// получаем log2(n) с округлением в меньшую сторону
-	// размер блока
-	logN := log2(pubKey.N)
-
-	for i := int64(0); i < int64(len(chiper)); i += logN + 1 {
-		chipherBlocks = append(chipherBlocks, chiper[i:i+logN+1])
-	}
-
-	// итерируемся по блокам шифра
-	for _, cBites := range chipherBlocks {
-		// блок шифра переводим в целое число
-		c, _ := new(big.Int).SetString(cBites, 2)
-		// вычисляем M = m * d (mod n)
-		dechipher := exp(c, privKey.D, pubKey.N)
-		// переводим в битовое представление
-		bitM = append(bitM, fmt.Sprintf("%08b", dechipher))
-	}
-
-	// итерируемся по битовому представлению M и дополняем до исходного размера блоков
-	for i := range bitM {
-		// вычисляем длину блока
-		l := int64(len(bitM[i]))
-
-		// инициализируем переменную для записи недостающих бит
-		var blockM string
-		// если длина блока меньше, заполняем по недостающему количеству нулями
-		for i := l; i < logN; i++ {
-			blockM += "0"
-		}
+// Зашифрование одного блока: c = m ^ e (mod n)
+// m должно быть меньше n
+func (pubKey *PublicKey) Encrypt(m *big.Int) (*big.Int, error) {
+	if m.Sign() < 0 || m.Cmp(pubKey.N) >= 0 {
+		return nil, fmt.Errorf("%w: значение занимает %d бит, модуль n - %d бит", ErrMessageTooLong, m.BitLen(), pubKey.N.BitLen())
+	}
+	return pubKey.expE(m), nil
+}
 
-		// склеиваем нули и блок
-		bitM[i] = blockM + bitM[i]
+// Расшифрование одного блока: m = c ^ d (mod n) с ослеплением
+// необходим и публичный ключ, потому что он содержит n
+func (privKey *PrivateKey) Decrypt(c *big.Int, pubKey *PublicKey) (*big.Int, error) {
+	if c.Sign() < 0 || c.Cmp(pubKey.N) >= 0 {
+		return nil, fmt.Errorf("%w: значение блока не меньше n", ErrMalformedCiphertext)
 	}
+	return privKey.blindedExpD(c, pubKey)
+}
 
-	// переменная для кокнатенации блоков в одну битовую строку
-	var m string
-	for i := len(bitM) - 1; i >= 0; i-- {
-		m += bitM[i]
+// процедура шифрования
+// шифрует сообщение целиком в памяти, для больших файлов следует использовать EncryptWriter
+func (pubKey *PublicKey) ShipherBytes(M []byte) ([]byte, error) {
+	// инициализируем буфер для хранения шифра
+	var chipher bytes.Buffer
+
+	// шифруем поблочно через потоковое зашифрование
+	ew := NewEncryptWriter(&chipher, pubKey)
+	if _, err := ew.Write(M); err != nil {
+		return nil, err
+	}
+	if err := ew.Close(); err != nil {
+		return nil, err
 	}
 
-	// бьем на блоки по 8 бит с конца, приводим к сообщение к исходному виду
-	for i := len(m); i >= 0; i -= 8 {
-		if i-8 < 0 {
-			break
-		}
-		c, _ := new(big.Int).SetString(m[i-8:i], 2)
-		M = append(c.Bytes(), M...)
+	// возврат шифра
+	return chipher.Bytes(), nil
+}
+
+// процедура расшифрования
+// расшифровывает шифр целиком в памяти, для больших файлов следует использовать DecryptReader
+func (privKey *PrivateKey) DeShipherBytes(chiper []byte, pubKey *PublicKey) ([]byte, error) {
+	// расшифровываем поблочно через потоковое расшифрование
+	M, err := io.ReadAll(NewDecryptReader(bytes.NewReader(chiper), privKey, pubKey))
+	if err != nil {
+		return nil, err
 	}
 
 	// возвращаем расшифрованное сообщение
-	return M
+	return M, nil
 }
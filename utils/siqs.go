@@ -0,0 +1,644 @@
+package utils
+
+import (
+	"context"
+	crand "crypto/rand"
+	"errors"
+	"math"
+	"math/big"
+	"math/rand"
+	"sync"
+)
+
+// Самоинициализирующееся квадратичное решето (SIQS).
+// Ищутся Y, для которых Y^2 - kN раскладывается над базой множителей - малыми простыми p, по модулю которых kN
+// квадратичный вычет (k - небольшой множитель, подобранный по Кнуту-Шрёппелю). Y берутся в виде Y = A * x + B,
+// где B^2 = kN (mod A), тогда Y^2 - kN = A * g(x), g(x) = A * x^2 + 2B * x + C, и |g(x)| на [-M, M)
+// порядка M * sqrt(kN), а не kN. Делимость g(x) на p определяется двумя корнями по модулю p, поэтому вместо
+// пробного деления по всему интервалу прибавляются log p в ячейки решета, и проверяются только ячейки с большой суммой.
+// A = q_1 * ... * q_s, и для одного A есть 2^(s-1) разных B, корни для которых пересчитываются одним сложением
+// (самоинициализация). Соотношения с одним большим простым вне базы (частичные) сохраняются, и два таких
+// соотношения с одинаковым большим простым дают одно полное.
+// Набор соотношений с четной суммой показателей, найденный методом Гаусса над GF(2), дает X^2 = Y^2 (mod n),
+// и НОД(X - Y, n) с вероятностью 1/2 - делитель n.
+
+// Параметры решета по размеру n в битах
+type siqsSize struct {
+	bits int
+	// размер базы множителей и половина длины интервала решета
+	factorBase, m int
+}
+
+var siqsSizes = []siqsSize{
+	{64, 100, 16384},
+	{100, 200, 32768},
+	{120, 400, 32768},
+	{140, 700, 32768},
+	{160, 1200, 32768},
+	{180, 2000, 32768},
+	{200, 3500, 32768},
+	{220, 5000, 65536},
+	{240, 9000, 65536},
+	{260, 13000, 65536},
+	{280, 17000, 98304},
+	{300, 22000, 98304},
+}
+
+const (
+	// большое простое частичного соотношения меньше pmax * siqsLargeMultiplier
+	siqsLargeMultiplier = 64
+	// простые меньше siqsSieveMin не просеиваются, их вклад учитывается понижением порога
+	siqsSieveMin = 30
+	// сколько соотношений собирать сверх размера базы
+	siqsExtraRelations = 64
+	// запас порога решета в битах на непросеянные малые простые и округление логарифмов, подобран опытным путем
+	siqsThresholdSlack = 12
+)
+
+// Параметры SIQS
+type SIQSParams struct {
+	// количество горутин решета, 0 - по числу ядер
+	Workers int
+	// размер базы множителей и половина длины интервала, 0 - по размеру n
+	FactorBase, M int
+	// вызывается при появлении новых соотношений с их числом и требуемым числом, может быть nil
+	Progress func(relations, needed int)
+}
+
+// Результат SIQS
+type SIQSResult struct {
+	// множители n, nil - если ни одна зависимость не дала делителя
+	P, Q *big.Int
+	// множитель k, размер базы множителей и половина длины интервала
+	Multiplier, FactorBase, M int
+	// полных соотношений, полных из пар частичных, обработано многочленов
+	Full, Combined, Polynomials int
+}
+
+// соотношение Y^2 = Π p_i * L^2 (mod n)
+type siqsRelation struct {
+	y *big.Int
+	// номера простых в базе с повторениями, 0 - знак -1
+	factors []int
+	// произведение больших простых, входящих в квадрат, для полного соотношения - 1
+	large *big.Int
+}
+
+// база множителей: индекс 0 отведен под -1
+type siqsFactorBase struct {
+	kn     *big.Int
+	primes []uint64
+	// корни kN по модулю p и округленные log2 p
+	sqrts []uint64
+	logs  []uint8
+	bigs  []*big.Int
+	// p делит k: корень один, такие простые не просеиваются
+	divK []bool
+}
+
+// Выбор множителя k по Кнуту-Шрёппелю: k * n должно быть квадратичным вычетом по модулю многих малых простых
+func siqsMultiplier(n *big.Int) int {
+	best, bestScore := 1, math.Inf(-1)
+	small := primesUpTo(2000)[1:]
+	for _, k := range []int{1, 3, 5, 7, 11, 13, 15, 17, 19, 21, 23, 29, 31, 33, 35, 37, 39, 41, 43, 47, 51, 53, 55, 57, 59, 61, 65, 67, 69, 71, 73} {
+		kn := new(big.Int).Mul(n, big.NewInt(int64(k)))
+		score := -0.5 * math.Log(float64(k))
+		switch new(big.Int).And(kn, big.NewInt(7)).Int64() {
+		case 1:
+			score += 2 * math.Ln2
+		case 5:
+			score += math.Ln2
+		default:
+			score += 0.5 * math.Ln2
+		}
+		for _, p := range small {
+			lp := math.Log(float64(p))
+			switch {
+			case k%int(p) == 0:
+				score += lp / float64(p)
+			case big.Jacobi(new(big.Int).Mod(kn, new(big.Int).SetUint64(p)), new(big.Int).SetUint64(p)) == 1:
+				score += 2 * lp / float64(p-1)
+			}
+		}
+		if score > bestScore {
+			best, bestScore = k, score
+		}
+	}
+	return best
+}
+
+// Построение базы из size простых. Если одно из простых делит n, оно возвращается в factor.
+func newSIQSFactorBase(n *big.Int, k, size int) (fb *siqsFactorBase, factor *big.Int) {
+	kn := new(big.Int).Mul(n, big.NewInt(int64(k)))
+	fb = &siqsFactorBase{
+		kn:     kn,
+		primes: []uint64{0, 2},
+		sqrts:  []uint64{0, 1},
+		logs:   []uint8{0, 1},
+		bigs:   []*big.Int{iM1, big.NewInt(2)},
+		divK:   []bool{false, false},
+	}
+	r := new(big.Int)
+	forEachPrime(3, math.MaxUint32, func(p uint64) bool {
+		bp := new(big.Int).SetUint64(p)
+		if r.Mod(n, bp).Sign() == 0 {
+			factor = bp
+			return false
+		}
+		r.Mod(kn, bp)
+		divK := r.Sign() == 0
+		if !divK && big.Jacobi(r, bp) != 1 {
+			return true
+		}
+		root := uint64(0)
+		if !divK {
+			root = new(big.Int).ModSqrt(r, bp).Uint64()
+		}
+		fb.primes = append(fb.primes, p)
+		fb.sqrts = append(fb.sqrts, root)
+		fb.logs = append(fb.logs, uint8(math.Round(math.Log2(float64(p)))))
+		fb.bigs = append(fb.bigs, bp)
+		fb.divK = append(fb.divK, divK)
+		return len(fb.primes) < size
+	})
+	return fb, factor
+}
+
+// a^-1 (mod p) для простого p
+func invModPrime(a, p uint64) uint64 {
+	result, e := uint64(1), p-2
+	for a %= p; e > 0; e >>= 1 {
+		if e&1 == 1 {
+			result = result * a % p
+		}
+		a = a * a % p
+	}
+	return result
+}
+
+// Разложение n самоинициализирующимся квадратичным решетом на нескольких горутинах.
+// Предназначено для n примерно от 100 до 300 бит. Если ни одна зависимость не дала делителя,
+// возвращается результат с P == nil и без ошибки.
+func SIQS(ctx context.Context, n *big.Int, params SIQSParams) (*SIQSResult, error) {
+	if n.BitLen() < siqsSizes[0].bits {
+		return nil, errors.New("n слишком мало для квадратичного решета, используйте ро-метод Полларда")
+	}
+	if n.ProbablyPrime(20) {
+		return nil, errors.New("n простое, раскладывать нечего")
+	}
+	result := &SIQSResult{}
+	if n.Bit(0) == 0 {
+		result.P, result.Q = big.NewInt(2), new(big.Int).Rsh(n, 1)
+		return result, nil
+	}
+	if root, ok := perfectSquareRoot(n); ok {
+		result.P, result.Q = root, new(big.Int).Set(root)
+		return result, nil
+	}
+
+	size := siqsSizes[len(siqsSizes)-1]
+	for _, s := range siqsSizes {
+		if n.BitLen() <= s.bits {
+			size = s
+			break
+		}
+	}
+	if params.FactorBase > 0 {
+		size.factorBase = params.FactorBase
+	}
+	if params.M > 0 {
+		size.m = params.M
+	}
+	workers := params.Workers
+	if workers < 1 {
+		workers = DefaultJobs()
+	}
+	progress := params.Progress
+	if progress == nil {
+		progress = func(int, int) {}
+	}
+
+	k := siqsMultiplier(n)
+	fb, factor := newSIQSFactorBase(n, k, size.factorBase)
+	result.Multiplier, result.FactorBase, result.M = k, len(fb.primes), size.m
+	if factor != nil {
+		result.P, result.Q = orderedFactors(n, factor)
+		return result, nil
+	}
+	needed := len(fb.primes) + siqsExtraRelations
+
+	// горутины решета присылают соотношения пачками, сбор останавливается, когда их достаточно
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	found := make(chan []siqsRelation, workers)
+	var polynomials int
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := newSIQSSieve(n, fb, size.m)
+			count := s.run(runCtx, found)
+			mu.Lock()
+			polynomials += count
+			mu.Unlock()
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(found)
+	}()
+
+	var full []siqsRelation
+	partial := make(map[string]siqsRelation)
+	seen := make(map[string]bool)
+	for batch := range found {
+		if len(full) >= needed {
+			continue
+		}
+		for _, rel := range batch {
+			key := rel.y.String()
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+			if rel.large.Cmp(i1) == 0 {
+				full = append(full, rel)
+				result.Full++
+				continue
+			}
+			// два частичных соотношения с одним большим простым L дают полное с L^2
+			lk := rel.large.String()
+			other, ok := partial[lk]
+			if !ok {
+				partial[lk] = rel
+				continue
+			}
+			y := new(big.Int).Mul(rel.y, other.y)
+			y.Mod(y, n)
+			factors := append(append([]int{}, rel.factors...), other.factors...)
+			full = append(full, siqsRelation{y: y, factors: factors, large: rel.large})
+			result.Combined++
+		}
+		progress(len(full), needed)
+		if len(full) >= needed {
+			cancel()
+		}
+	}
+	result.Polynomials = polynomials
+	if len(full) < needed {
+		return nil, ctx.Err()
+	}
+	full = full[:needed]
+
+	// зависимости дают X^2 = Y^2 (mod n)
+	rows := make([][]int, len(full))
+	for i, rel := range full {
+		rows[i] = rel.factors
+	}
+	exponents := make([]int, len(fb.primes))
+	for _, dep := range gf2Dependencies(rows, len(fb.primes)) {
+		x, y := big.NewInt(1), big.NewInt(1)
+		for i := range exponents {
+			exponents[i] = 0
+		}
+		for _, i := range dep {
+			rel := full[i]
+			x.Mul(x, rel.y)
+			x.Mod(x, n)
+			y.Mul(y, rel.large)
+			y.Mod(y, n)
+			for _, j := range rel.factors {
+				exponents[j]++
+			}
+		}
+		for j := 1; j < len(exponents); j++ {
+			if exponents[j] > 0 {
+				y.Mul(y, new(big.Int).Exp(fb.bigs[j], big.NewInt(int64(exponents[j]/2)), n))
+				y.Mod(y, n)
+			}
+		}
+		if g, _ := splitGCD(x.Sub(x, y), n); g != nil {
+			result.P, result.Q = orderedFactors(n, g)
+			return result, nil
+		}
+	}
+	return result, nil
+}
+
+// Состояние решета одной горутины
+type siqsSieve struct {
+	n  *big.Int
+	fb *siqsFactorBase
+	m  int
+	// порог суммы логарифмов и граница большого простого
+	threshold uint8
+	large     uint64
+	rng       *rand.Rand
+	sieve     []uint8
+
+	// текущий многочлен: A, B, множители A (номера в базе), B_l, корни и поправки корней 2 * B_l / A (mod p)
+	a, b     *big.Int
+	aFactors []int
+	inA      []bool
+	bl       []*big.Int
+	soln1    []uint64
+	soln2    []uint64
+	bainv2   [][]uint64
+}
+
+func newSIQSSieve(n *big.Int, fb *siqsFactorBase, m int) *siqsSieve {
+	pmax := fb.primes[len(fb.primes)-1]
+	large := pmax * siqsLargeMultiplier
+	if large > pmax*pmax {
+		large = pmax * pmax
+	}
+	// |g(x)| <= M * sqrt(kN / 2), допускаем недостачу на большое простое и запас
+	gBits := math.Log2(float64(m)) + float64(fb.kn.BitLen()-1)/2
+	threshold := gBits - math.Log2(float64(large)) - siqsThresholdSlack
+	if threshold < 1 {
+		threshold = 1
+	}
+
+	var seed [8]byte
+	crand.Read(seed[:])
+	var s int64
+	for _, b := range seed {
+		s = s<<8 | int64(b)
+	}
+	return &siqsSieve{
+		n: n, fb: fb, m: m,
+		threshold: uint8(threshold),
+		large:     large,
+		rng:       rand.New(rand.NewSource(s)),
+		sieve:     make([]uint8, 2*m),
+		inA:       make([]bool, len(fb.primes)),
+		soln1:     make([]uint64, len(fb.primes)),
+		soln2:     make([]uint64, len(fb.primes)),
+	}
+}
+
+// Перебор многочленов до отмены контекста, соотношения каждого многочлена отправляются в found.
+// Возвращает число обработанных многочленов.
+func (s *siqsSieve) run(ctx context.Context, found chan<- []siqsRelation) int {
+	count := 0
+	for ctx.Err() == nil {
+		s.newA()
+		polys := 1 << (len(s.aFactors) - 1)
+		for i := 0; i < polys && ctx.Err() == nil; i++ {
+			if i > 0 {
+				s.nextB(i)
+			}
+			count++
+			if rels := s.sievePoly(); len(rels) > 0 {
+				select {
+				case found <- rels:
+				case <-ctx.Done():
+				}
+			}
+		}
+	}
+	return count
+}
+
+// Выбор A = q_1 * ... * q_s близкого к sqrt(2kN) / M и первого B
+func (s *siqsSieve) newA() {
+	fb := s.fb
+	target := new(big.Int).Lsh(fb.kn, 1)
+	target.Sqrt(target)
+	target.Quo(target, big.NewInt(int64(s.m)))
+	targetLog := log2(target)
+
+	// q берутся около 2^11, но не больше половины базы, чтобы их было из чего выбирать
+	maxLog := math.Log2(float64(fb.primes[len(fb.primes)/2]))
+	count := int(math.Round(targetLog / 11))
+	if count < 2 {
+		count = 2
+	}
+	for targetLog/float64(count) > maxLog {
+		count++
+	}
+	qLog := targetLog / float64(count)
+	lo, hi := 2, len(fb.primes)
+	for lo < hi && math.Log2(float64(fb.primes[lo])) < qLog-0.5 {
+		lo++
+	}
+	for hi = lo; hi < len(fb.primes) && math.Log2(float64(fb.primes[hi])) < qLog+0.5; hi++ {
+	}
+	for hi-lo < 2*count && (lo > 2 || hi < len(fb.primes)) {
+		if lo > 2 {
+			lo--
+		}
+		if hi < len(fb.primes) {
+			hi++
+		}
+	}
+
+	var best []int
+	bestDiff := math.Inf(1)
+	for attempt := 0; attempt < 30; attempt++ {
+		var factors []int
+		used := make(map[int]bool)
+		a := big.NewInt(1)
+		for len(factors) < count-1 {
+			j := lo + s.rng.Intn(hi-lo)
+			if used[j] || fb.divK[j] {
+				continue
+			}
+			used[j] = true
+			factors = append(factors, j)
+			a.Mul(a, fb.bigs[j])
+		}
+		// последний множитель - простое базы, ближайшее к target / a
+		rest := log2(target) - log2(a)
+		last, lastDiff := -1, math.Inf(1)
+		for j := 2; j < len(fb.primes); j++ {
+			if used[j] || fb.divK[j] {
+				continue
+			}
+			if d := math.Abs(math.Log2(float64(fb.primes[j])) - rest); d < lastDiff {
+				last, lastDiff = j, d
+			}
+		}
+		factors = append(factors, last)
+		if lastDiff < bestDiff {
+			best, bestDiff = factors, lastDiff
+		}
+		if lastDiff < 0.1 {
+			break
+		}
+	}
+
+	for _, j := range s.aFactors {
+		s.inA[j] = false
+	}
+	s.aFactors = best
+	s.a = big.NewInt(1)
+	for _, j := range best {
+		s.inA[j] = true
+		s.a.Mul(s.a, fb.bigs[j])
+	}
+
+	// B_l = (A / q_l) * (t_l * (A / q_l)^-1 mod q_l), тогда B = Σ B_l удовлетворяет B^2 = kN (mod A)
+	s.bl = s.bl[:0]
+	s.b = new(big.Int)
+	for _, j := range best {
+		q := fb.primes[j]
+		aq := new(big.Int).Quo(s.a, fb.bigs[j])
+		aqMod := new(big.Int).Mod(aq, fb.bigs[j]).Uint64()
+		gamma := fb.sqrts[j] * invModPrime(aqMod, q) % q
+		if gamma > q/2 {
+			gamma = q - gamma
+		}
+		bl := aq.Mul(aq, new(big.Int).SetUint64(gamma))
+		s.bl = append(s.bl, bl)
+		s.b.Add(s.b, bl)
+	}
+
+	// корни g(x) по модулю p в координатах решета (x + M) и поправки 2 * B_l * A^-1 (mod p)
+	if len(s.bainv2) < len(best) {
+		s.bainv2 = make([][]uint64, len(best))
+	}
+	for l := range best {
+		if s.bainv2[l] == nil {
+			s.bainv2[l] = make([]uint64, len(fb.primes))
+		}
+	}
+	r := new(big.Int)
+	for j := 2; j < len(fb.primes); j++ {
+		if s.inA[j] || fb.divK[j] {
+			continue
+		}
+		p := fb.primes[j]
+		ainv := invModPrime(r.Mod(s.a, fb.bigs[j]).Uint64(), p)
+		bmod := r.Mod(s.b, fb.bigs[j]).Uint64()
+		t := fb.sqrts[j]
+		shift := uint64(s.m) % p
+		s.soln1[j] = (ainv*((t+p-bmod)%p)%p + shift) % p
+		s.soln2[j] = (ainv*((2*p-t-bmod)%p)%p + shift) % p
+		for l, bl := range s.bl {
+			s.bainv2[l][j] = 2 * r.Mod(bl, fb.bigs[j]).Uint64() % p * ainv % p
+		}
+	}
+}
+
+// Переход к i-му B по коду Грея: B = B + 2 * e * B_v
+func (s *siqsSieve) nextB(i int) {
+	v := 0
+	for i>>v&1 == 0 {
+		v++
+	}
+	odd := i >> v
+	// e = (-1)^ceil(i / 2^(v+1))
+	sign := 1
+	if (odd+1)/2%2 == 1 {
+		sign = -1
+	}
+	delta := new(big.Int).Lsh(s.bl[v], 1)
+	if sign > 0 {
+		s.b.Add(s.b, delta)
+	} else {
+		s.b.Sub(s.b, delta)
+	}
+	// корень A^-1 (±t - B) сдвигается на -e * 2 * B_v * A^-1
+	bainv := s.bainv2[v]
+	for j := 2; j < len(s.fb.primes); j++ {
+		if s.inA[j] || s.fb.divK[j] {
+			continue
+		}
+		p, d := s.fb.primes[j], bainv[j]
+		if sign > 0 {
+			s.soln1[j] = (s.soln1[j] + p - d) % p
+			s.soln2[j] = (s.soln2[j] + p - d) % p
+		} else {
+			s.soln1[j] = (s.soln1[j] + d) % p
+			s.soln2[j] = (s.soln2[j] + d) % p
+		}
+	}
+}
+
+// Просеивание текущего многочлена и проверка кандидатов пробным делением
+func (s *siqsSieve) sievePoly() []siqsRelation {
+	fb := s.fb
+	sieve := s.sieve
+	for i := range sieve {
+		sieve[i] = 0
+	}
+	size := uint64(len(sieve))
+	for j := 2; j < len(fb.primes); j++ {
+		p := fb.primes[j]
+		if p < siqsSieveMin || s.inA[j] || fb.divK[j] {
+			continue
+		}
+		lg := fb.logs[j]
+		for x := s.soln1[j]; x < size; x += p {
+			sieve[x] += lg
+		}
+		if s.soln2[j] != s.soln1[j] {
+			for x := s.soln2[j]; x < size; x += p {
+				sieve[x] += lg
+			}
+		}
+	}
+
+	var rels []siqsRelation
+	for i, v := range sieve {
+		if v >= s.threshold {
+			if rel, ok := s.check(i); ok {
+				rels = append(rels, rel)
+			}
+		}
+	}
+	return rels
+}
+
+// Пробное деление g(x) для ячейки i = x + M
+func (s *siqsSieve) check(i int) (siqsRelation, bool) {
+	fb := s.fb
+	// Y = A * x + B, Y^2 - kN = A * g(x)
+	y := big.NewInt(int64(i - s.m))
+	y.Mul(y, s.a)
+	y.Add(y, s.b)
+	g := new(big.Int).Mul(y, y)
+	g.Sub(g, fb.kn)
+	g.Quo(g, s.a)
+
+	factors := append([]int{}, s.aFactors...)
+	if g.Sign() < 0 {
+		factors = append(factors, 0)
+		g.Neg(g)
+	}
+	if g.Sign() == 0 {
+		return siqsRelation{}, false
+	}
+	for tz := g.TrailingZeroBits(); tz > 0; tz-- {
+		factors = append(factors, 1)
+	}
+	g.Rsh(g, g.TrailingZeroBits())
+
+	q, r := new(big.Int), new(big.Int)
+	for j := 2; j < len(fb.primes); j++ {
+		p := fb.primes[j]
+		if !s.inA[j] && !fb.divK[j] {
+			// p делит g(x) только в корнях
+			if x := uint64(i) % p; x != s.soln1[j] && x != s.soln2[j] {
+				continue
+			}
+		}
+		for {
+			q.QuoRem(g, fb.bigs[j], r)
+			if r.Sign() != 0 {
+				break
+			}
+			g, q = q, g
+			factors = append(factors, j)
+		}
+	}
+
+	large := g
+	if !g.IsUint64() || g.Uint64() >= s.large {
+		return siqsRelation{}, false
+	}
+	return siqsRelation{y: y.Mod(y, s.n), factors: factors, large: large}, true
+}
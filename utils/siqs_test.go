@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"context"
+	"crypto/rand"
+	"math/big"
+	"testing"
+)
+
+// полупростое из двух простых по bits/2 бит
+func testSemiprime(t *testing.T, bits int) (n, p, q *big.Int) {
+	t.Helper()
+	for {
+		p, err := rand.Prime(rand.Reader, bits/2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		q, err := rand.Prime(rand.Reader, bits-bits/2)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if p.Cmp(q) != 0 {
+			if p.Cmp(q) > 0 {
+				p, q = q, p
+			}
+			return new(big.Int).Mul(p, q), p, q
+		}
+	}
+}
+
+// Разложение полупростых 100-120 бит
+func TestSIQS(t *testing.T) {
+	for _, bits := range []int{100, 110, 120} {
+		n, p, q := testSemiprime(t, bits)
+		result, err := SIQS(context.Background(), n, SIQSParams{})
+		if err != nil {
+			t.Fatalf("%d бит: %s", bits, err)
+		}
+		if result.P == nil {
+			// зависимости не дали делителя - редкий, но допустимый исход
+			t.Logf("%d бит: n = %s не разложено", bits, n)
+			continue
+		}
+		if result.P.Cmp(p) != 0 || result.Q.Cmp(q) != 0 {
+			t.Fatalf("%d бит: %s * %s, ожидалось %s * %s", bits, result.P, result.Q, p, q)
+		}
+		if result.Full+result.Combined < result.FactorBase {
+			t.Fatalf("%d бит: %d соотношений при базе %d", bits, result.Full+result.Combined, result.FactorBase)
+		}
+	}
+}
+
+// Четные n и квадраты раскладываются без решета, малые и простые n отвергаются
+func TestSIQSSpecialCases(t *testing.T) {
+	// простые по 70 бит, чтобы 2q и p^2 были не меньше 64 бит
+	_, p, q := testSemiprime(t, 140)
+	even := new(big.Int).Lsh(q, 1)
+	result, err := SIQS(context.Background(), even, SIQSParams{})
+	if err != nil || result.P.Cmp(big.NewInt(2)) != 0 || result.Q.Cmp(q) != 0 {
+		t.Fatalf("четное n: %+v, %v", result, err)
+	}
+	square := new(big.Int).Mul(p, p)
+	result, err = SIQS(context.Background(), square, SIQSParams{})
+	if err != nil || result.P.Cmp(p) != 0 || result.Q.Cmp(p) != 0 {
+		t.Fatalf("квадрат: %+v, %v", result, err)
+	}
+	if _, err := SIQS(context.Background(), big.NewInt(1000003*1000033), SIQSParams{}); err == nil {
+		t.Fatal("малое n принято")
+	}
+	if _, err := SIQS(context.Background(), q, SIQSParams{}); err == nil {
+		t.Fatal("простое n принято")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	n, _, _ := testSemiprime(t, 120)
+	if _, err := SIQS(ctx, n, SIQSParams{}); err == nil {
+		t.Fatal("отмененный контекст не остановил решето")
+	}
+}
@@ -0,0 +1,138 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+)
+
+// Атака на стереотипные сообщения (Копперсмит).
+// Блоки ShipherBytes шифруются без дополнения: c = m^e (mod n). Если сообщение построено по известному
+// шаблону и неизвестно только короткое поле x из k байт, то блок m = a + x * 256^s, где a - известные байты,
+// а s - количество известных байт после поля. Тогда x - малый корень многочлена
+// (x + a * 256^-s)^e - c * 256^(-s*e) = 0 (mod n), который находит SmallRoots при 8k < bitlen(n) / e.
+
+// Символ шаблона, обозначающий неизвестный байт
+const StereotypedMarker = '?'
+
+// наибольшая степень e, для которой строится решетка: ее размерность не меньше e
+const maxStereotypedExponent = maxSmallRootsDimension
+
+// Результат атаки на стереотипное сообщение
+type StereotypedResult struct {
+	// номер блока шифра с неизвестным полем и смещение поля в открытом тексте
+	Block  int
+	Offset int
+	// восстановленные байты поля, nil - если атака не удалась
+	Unknown []byte
+	// шаблон с подставленным полем
+	Message []byte
+	// параметры решетки
+	M, T      int
+	Dimension int
+}
+
+// поиск единственной группы маркеров подряд в шаблоне
+func markerRun(template []byte) (int, int, error) {
+	start := bytes.IndexByte(template, StereotypedMarker)
+	if start < 0 {
+		return 0, 0, fmt.Errorf("в шаблоне нет неизвестных байт %q", StereotypedMarker)
+	}
+	end := start
+	for end < len(template) && template[end] == StereotypedMarker {
+		end++
+	}
+	if bytes.IndexByte(template[end:], StereotypedMarker) >= 0 {
+		return 0, 0, fmt.Errorf("неизвестные байты %q в шаблоне должны идти одной группой", StereotypedMarker)
+	}
+	return start, end, nil
+}
+
+// Атака на шифр chipher в поблочном формате (ShipherBytes, EncryptWriter).
+// template - открытый текст целиком, в котором неизвестные байты заменены на StereotypedMarker;
+// неизвестные байты должны идти одной группой и лежать в одном блоке.
+// Если поле не найдено, возвращается результат с Unknown == nil и без ошибки.
+func StereotypedAttack(ctx context.Context, pubKey *PublicKey, chipher, template []byte, params SmallRootsParams) (*StereotypedResult, error) {
+	n := pubKey.N
+	if !pubKey.E.IsInt64() || pubKey.E.Int64() > maxStereotypedExponent {
+		return nil, fmt.Errorf("e = %s слишком велико для атаки, поддерживается e <= %d", pubKey.E, maxStereotypedExponent)
+	}
+	e := int(pubKey.E.Int64())
+
+	// разбираем поблочный формат: блоки шифра и длина открытого текста в конце
+	blocks, total, err := parseBlocks(chipher, n)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(template)) != total {
+		return nil, fmt.Errorf("длина шаблона %d не совпадает с длиной открытого текста %d", len(template), total)
+	}
+	plainSize := plainBlockSize(n)
+
+	start, end, err := markerRun(template)
+	if err != nil {
+		return nil, err
+	}
+	block := start / plainSize
+	if (end-1)/plainSize != block {
+		return nil, errors.New("неизвестные байты шаблона попадают в разные блоки")
+	}
+	blockStart := block * plainSize
+	blockEnd := blockStart + plainSize
+	if blockEnd > len(template) {
+		blockEnd = len(template)
+	}
+
+	c := blocks[block]
+
+	// m = a + x * 256^s, a - блок шаблона с нулями вместо неизвестных байт
+	known := append([]byte(nil), template[blockStart:blockEnd]...)
+	for i := start - blockStart; i < end-blockStart; i++ {
+		known[i] = 0
+	}
+	a := new(big.Int).SetBytes(known)
+	s := uint(8 * (blockEnd - end))
+	shift := new(big.Int).Exp(i2, big.NewInt(int64(s)), n)
+	shiftInv := new(big.Int).ModInverse(shift, n)
+	if shiftInv == nil {
+		return nil, fmt.Errorf("%w: модуль четный", ErrKeyFormat)
+	}
+
+	// (x + a')^e - c' = Σ C(e, i) * a'^(e-i) * x^i - c', a' = a * 256^-s, c' = c * 256^(-s*e)
+	a.Mul(a, shiftInv)
+	a.Mod(a, n)
+	f := make([]*big.Int, e+1)
+	for i := 0; i <= e; i++ {
+		f[i] = new(big.Int).Binomial(int64(e), int64(i))
+		f[i].Mul(f[i], new(big.Int).Exp(a, big.NewInt(int64(e-i)), n))
+	}
+	cShifted := new(big.Int).Exp(shiftInv, pubKey.E, n)
+	cShifted.Mul(cShifted, c)
+	f[0].Sub(f[0], cShifted)
+
+	bound := new(big.Int).Lsh(i1, uint(8*(end-start)))
+	roots, err := SmallRoots(ctx, f, n, bound, params)
+	if err != nil {
+		return nil, err
+	}
+	result := &StereotypedResult{Block: block, Offset: start, M: roots.M, T: roots.T, Dimension: roots.Dimension}
+
+	// поле - неотрицательное число из end - start байт, проверяем его зашифрованием
+	for _, x := range roots.Roots {
+		if x.Sign() < 0 {
+			continue
+		}
+		m := new(big.Int).Lsh(x, s)
+		m.Add(m, new(big.Int).SetBytes(known))
+		if m.Cmp(n) >= 0 || pubKey.expE(m).Cmp(c) != 0 {
+			continue
+		}
+		result.Unknown = x.FillBytes(make([]byte, end-start))
+		result.Message = append([]byte(nil), template...)
+		copy(result.Message[start:end], result.Unknown)
+		return result, nil
+	}
+	return result, nil
+}
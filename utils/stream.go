@@ -0,0 +1,376 @@
+package utils
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+)
+
+// Формат шифра:
+//   - открытый текст разбивается на блоки по floor((bitlen(n) - 1) / 8) байт,
+//     поэтому значение любого блока гарантированно меньше n;
+//   - каждый блок шифруется отдельно и записывается ровно в ceil(bitlen(n) / 8) байт;
+//   - последний блок (от 0 до размера блока - 1 байт) записывается всегда,
+//     а за ним следуют 8 байт с длиной всего открытого текста (big-endian).
+// Блоки восстанавливаются через FillBytes, поэтому нулевые байты, в том числе ведущие,
+// не теряются, а записанная длина позволяет проверить, что файл расшифрован полностью.
+
+// длина поля с размером открытого текста
+const plainLenSize = 8
+
+// размер блока открытого текста в байтах
+func plainBlockSize(n *big.Int) int {
+	return (n.BitLen() - 1) / 8
+}
+
+// размер блока шифра в байтах
+func chipherBlockSize(n *big.Int) int {
+	return (n.BitLen() + 7) / 8
+}
+
+// Потоковое зашифрование.
+// Данные, записанные в EncryptWriter, разбиваются на блоки и шифруются пачками по мере поступления,
+// поэтому расход памяти не зависит от размера входа.
+// Блоки пачки шифруются параллельно, но записываются в исходном порядке.
+type EncryptWriter struct {
+	ctx    context.Context
+	jobs   int
+	w      io.Writer
+	pubKey *PublicKey
+	// накопленный, но еще не заполненный блок открытого текста
+	buf []byte
+	// заполненные блоки, ожидающие зашифрования
+	batch []*big.Int
+	// буфер для записи блока шифра
+	out []byte
+	// количество байт открытого текста, записанных в поток
+	total uint64
+	err   error
+}
+
+// "Конструктор" для инициализации потокового зашифрования
+// блоки шифруются параллельно на всех доступных ядрах
+func NewEncryptWriter(w io.Writer, pubKey *PublicKey) *EncryptWriter {
+	return NewEncryptWriterContext(context.Background(), w, pubKey, DefaultJobs())
+}
+
+// "Конструктор" для инициализации потокового зашифрования в jobs горутинах
+// при отмене ctx запись прерывается с ошибкой контекста
+func NewEncryptWriterContext(ctx context.Context, w io.Writer, pubKey *PublicKey, jobs int) *EncryptWriter {
+	if jobs < 1 {
+		jobs = 1
+	}
+	ew := &EncryptWriter{
+		ctx:    ctx,
+		jobs:   jobs,
+		w:      w,
+		pubKey: pubKey,
+		buf:    make([]byte, 0, plainBlockSize(pubKey.N)),
+		batch:  make([]*big.Int, 0, jobs*blocksPerJob),
+		out:    make([]byte, chipherBlockSize(pubKey.N)),
+	}
+	// в блок должен помещаться хотя бы один байт
+	if cap(ew.buf) == 0 {
+		ew.err = fmt.Errorf("%w: модуль n слишком мал для шифрования: %d бит", ErrKeyFormat, pubKey.N.BitLen())
+	}
+	return ew
+}
+
+// Запись очередной порции открытого текста
+func (ew *EncryptWriter) Write(p []byte) (int, error) {
+	if ew.err != nil {
+		return 0, ew.err
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// дописываем в блок сколько поместится
+		n := copy(ew.buf[len(ew.buf):cap(ew.buf)], p)
+		ew.buf = ew.buf[:len(ew.buf)+n]
+		p = p[n:]
+		ew.total += uint64(n)
+
+		// блок заполнен - ставим его в очередь на зашифрование
+		// поэтому последний блок, записываемый в Close, всегда неполный
+		if len(ew.buf) == cap(ew.buf) {
+			ew.enqueue()
+		}
+		// очередь заполнена - шифруем пачку
+		if len(ew.batch) == cap(ew.batch) {
+			if ew.err = ew.flush(); ew.err != nil {
+				return written, ew.err
+			}
+		}
+		written += n
+	}
+
+	return written, nil
+}
+
+// Завершение зашифрования: шифруем оставшиеся блоки и записываем длину открытого текста
+func (ew *EncryptWriter) Close() error {
+	if ew.err != nil {
+		return ew.err
+	}
+
+	ew.enqueue()
+	if ew.err = ew.flush(); ew.err != nil {
+		return ew.err
+	}
+
+	var trailer [plainLenSize]byte
+	binary.BigEndian.PutUint64(trailer[:], ew.total)
+	if _, ew.err = ew.w.Write(trailer[:]); ew.err != nil {
+		return ew.err
+	}
+
+	// повторная запись после закрытия недопустима
+	ew.err = errors.New("запись в закрытый поток зашифрования")
+	return nil
+}
+
+// постановка накопленного блока в очередь на зашифрование
+func (ew *EncryptWriter) enqueue() {
+	ew.batch = append(ew.batch, new(big.Int).SetBytes(ew.buf))
+	ew.buf = ew.buf[:0]
+}
+
+// параллельное зашифрование очереди блоков и запись их в w по порядку
+func (ew *EncryptWriter) flush() error {
+	// c = m ^ e (mod n)
+	err := processBlocks(ew.ctx, ew.jobs, ew.batch, func(_ int, m *big.Int) *big.Int {
+		return ew.pubKey.expE(m)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, c := range ew.batch {
+		// блок шифра всегда записывается в фиксированное количество байт
+		if _, err := ew.w.Write(c.FillBytes(ew.out)); err != nil {
+			return err
+		}
+	}
+	ew.batch = ew.batch[:0]
+	return nil
+}
+
+// Потоковое расшифрование.
+// Читает из r шифр, записанный EncryptWriter, и отдает открытый текст по мере расшифрования блоков.
+// Блоки читаются пачками, расшифровываются параллельно и отдаются в исходном порядке.
+type DecryptReader struct {
+	ctx     context.Context
+	jobs    int
+	r       *bufio.Reader
+	privKey *PrivateKey
+	pubKey  *PublicKey
+	// размер блока открытого текста в байтах
+	blockSize int
+	// текущий блок шифра
+	cur []byte
+	// пачка блоков, расшифровываемых за раз
+	batch []*big.Int
+	// буфер для расшифрованной пачки
+	plain []byte
+	// количество уже расшифрованных блоков и байт открытого текста
+	blocks int
+	total  uint64
+	// расшифрованные, но еще не отданные байты
+	out     []byte
+	started bool
+	done    bool
+	err     error
+}
+
+// "Конструктор" для инициализации потокового расшифрования
+// необходим и публичный ключ, потому что он содержит n
+// блоки расшифровываются параллельно на всех доступных ядрах
+func NewDecryptReader(r io.Reader, privKey *PrivateKey, pubKey *PublicKey) *DecryptReader {
+	return NewDecryptReaderContext(context.Background(), r, privKey, pubKey, DefaultJobs())
+}
+
+// "Конструктор" для инициализации потокового расшифрования в jobs горутинах
+// при отмене ctx чтение прерывается с ошибкой контекста
+func NewDecryptReaderContext(ctx context.Context, r io.Reader, privKey *PrivateKey, pubKey *PublicKey, jobs int) *DecryptReader {
+	if jobs < 1 {
+		jobs = 1
+	}
+	blockSize := plainBlockSize(pubKey.N)
+	return &DecryptReader{
+		ctx:       ctx,
+		jobs:      jobs,
+		r:         bufio.NewReader(r),
+		privKey:   privKey,
+		pubKey:    pubKey,
+		blockSize: blockSize,
+		cur:       make([]byte, chipherBlockSize(pubKey.N)),
+		batch:     make([]*big.Int, 0, jobs*blocksPerJob),
+		plain:     make([]byte, jobs*blocksPerJob*blockSize),
+	}
+}
+
+// Чтение очередной порции открытого текста
+func (dr *DecryptReader) Read(p []byte) (int, error) {
+	for len(dr.out) == 0 {
+		if dr.err != nil {
+			return 0, dr.err
+		}
+		if dr.done {
+			return 0, io.EOF
+		}
+		dr.err = dr.step()
+	}
+
+	n := copy(p, dr.out)
+	dr.out = dr.out[n:]
+	return n, nil
+}
+
+// чтение и расшифрование очередной пачки блоков шифра
+func (dr *DecryptReader) step() error {
+	if dr.blockSize == 0 {
+		return fmt.Errorf("%w: модуль n слишком мал для расшифрования: %d бит", ErrKeyFormat, dr.pubKey.N.BitLen())
+	}
+
+	// перед первым блоком убеждаемся, что ключи подходят друг другу,
+	// иначе вместо ошибки получился бы мусор на выходе
+	if !dr.started {
+		dr.started = true
+		if err := dr.privKey.Check(dr.pubKey); err != nil {
+			return err
+		}
+	}
+
+	// читаем блоки, пока не заполним пачку или не дойдем до последнего блока
+	var total uint64
+	last := false
+	dr.batch = dr.batch[:0]
+	for len(dr.batch) < cap(dr.batch) && !last {
+		// читаем блок шифра целиком
+		if _, err := io.ReadFull(dr.r, dr.cur); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return dr.blockError(len(dr.batch), fmt.Errorf("%w: шифр обрывается посреди блока", ErrMalformedCiphertext))
+			}
+			return err
+		}
+
+		// смотрим, что идет после блока: если осталось ровно поле длины - блок последний
+		tail, err := dr.r.Peek(plainLenSize + 1)
+		if err != nil && err != io.EOF {
+			return err
+		}
+		if len(tail) < plainLenSize {
+			return fmt.Errorf("%w: отсутствует длина открытого текста", ErrMalformedCiphertext)
+		}
+		if len(tail) == plainLenSize {
+			last = true
+			total = binary.BigEndian.Uint64(tail)
+		}
+
+		// блок шифра переводим в целое число
+		c := new(big.Int).SetBytes(dr.cur)
+		if c.Cmp(dr.pubKey.N) >= 0 {
+			return dr.blockError(len(dr.batch), fmt.Errorf("%w: значение блока не меньше n", ErrMalformedCiphertext))
+		}
+		dr.batch = append(dr.batch, c)
+	}
+
+	// ослепляющие параметры генерируем последовательно до запуска горутин,
+	// чтобы источник случайности не читался из нескольких горутин сразу
+	blinders := make([]*blinder, len(dr.batch))
+	for i := range blinders {
+		b, err := dr.privKey.newBlinder(dr.pubKey)
+		if err != nil {
+			return err
+		}
+		blinders[i] = b
+	}
+
+	// вычисляем M = c ^ d (mod n) с ослеплением
+	err := processBlocks(dr.ctx, dr.jobs, dr.batch, func(i int, c *big.Int) *big.Int {
+		return dr.privKey.expBlinded(c, dr.pubKey, blinders[i])
+	})
+	if err != nil {
+		return err
+	}
+
+	// собираем открытый текст пачки по порядку
+	out := dr.plain[:0]
+	for i, m := range dr.batch {
+		// длина блока открытого текста в байтах
+		blockLen := dr.blockSize
+		if last && i == len(dr.batch)-1 {
+			// длина последнего блока - остаток от записанной длины открытого текста
+			// она обязана быть меньше размера блока, иначе шифр обрезан или дополнен
+			if total < dr.total || total-dr.total >= uint64(dr.blockSize) {
+				return fmt.Errorf("%w: записана длина открытого текста %d байт, расшифровано %d", ErrMalformedCiphertext, total, dr.total)
+			}
+			blockLen = int(total - dr.total)
+			dr.done = true
+		}
+
+		// значение блока должно помещаться в его длину
+		if m.BitLen() > 8*blockLen {
+			return dr.blockError(i, fmt.Errorf("%w: расшифрованное значение длиннее блока", ErrMalformedCiphertext))
+		}
+		m.FillBytes(out[len(out) : len(out)+blockLen])
+		out = out[:len(out)+blockLen]
+		dr.total += uint64(blockLen)
+	}
+	dr.blocks += len(dr.batch)
+	dr.out = out
+	return nil
+}
+
+// ошибка в блоке с номером i внутри текущей пачки
+func (dr *DecryptReader) blockError(i int, err error) error {
+	return &BlockError{Index: dr.blocks + i, Err: err}
+}
+
+// Разбор шифра в поблочном формате целиком в памяти: значения блоков и длина открытого текста.
+// Нужен атакам, которые работают с отдельными блоками шифра.
+func parseBlocks(chipher []byte, n *big.Int) ([]*big.Int, uint64, error) {
+	plainSize, chipherSize := plainBlockSize(n), chipherBlockSize(n)
+	if plainSize == 0 {
+		return nil, 0, fmt.Errorf("%w: модуль слишком мал", ErrKeyFormat)
+	}
+	if len(chipher) < plainLenSize+chipherSize || (len(chipher)-plainLenSize)%chipherSize != 0 {
+		return nil, 0, fmt.Errorf("%w: длина %d не соответствует поблочному формату", ErrMalformedCiphertext, len(chipher))
+	}
+	count := (len(chipher) - plainLenSize) / chipherSize
+	total := binary.BigEndian.Uint64(chipher[len(chipher)-plainLenSize:])
+	if total/uint64(plainSize) != uint64(count-1) {
+		return nil, 0, fmt.Errorf("%w: длина открытого текста %d не соответствует количеству блоков %d", ErrMalformedCiphertext, total, count)
+	}
+	blocks := make([]*big.Int, count)
+	for i := range blocks {
+		blocks[i] = new(big.Int).SetBytes(chipher[i*chipherSize : (i+1)*chipherSize])
+		if blocks[i].Cmp(n) >= 0 {
+			return nil, 0, &BlockError{Index: i, Err: fmt.Errorf("%w: значение блока не меньше n", ErrMalformedCiphertext)}
+		}
+	}
+	return blocks, total, nil
+}
+
+// Сборка открытого текста длиной total из значений блоков, обратная к разбиению при зашифровании
+func joinBlocks(blocks []*big.Int, n *big.Int, total uint64) ([]byte, error) {
+	plainSize := plainBlockSize(n)
+	plain := make([]byte, total)
+	for i, m := range blocks {
+		start := i * plainSize
+		end := start + plainSize
+		if end > len(plain) {
+			end = len(plain)
+		}
+		// значение блока должно помещаться в его длину
+		if m.BitLen() > 8*(end-start) {
+			return nil, &BlockError{Index: i, Err: fmt.Errorf("%w: расшифрованное значение длиннее блока", ErrMalformedCiphertext)}
+		}
+		m.FillBytes(plain[start:end])
+	}
+	return plain, nil
+}
@@ -0,0 +1,307 @@
+package utils
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"testing"
+)
+
+// Тестовая ключевая пара с модулем ровно из bits бит и e = 65537.
+// Простые берутся из crypto/rand: генерация ключа этого пакета слишком медленная для тестов.
+func testKeyPair(tb testing.TB, bits int) (*PublicKey, *PrivateKey) {
+	tb.Helper()
+	return testKeyPairE(tb, bits, 65537)
+}
+
+// Тестовая ключевая пара с заданным e
+func testKeyPairE(tb testing.TB, bits int, exponent int64) (*PublicKey, *PrivateKey) {
+	tb.Helper()
+	e := big.NewInt(exponent)
+	for {
+		p, err := rand.Prime(rand.Reader, bits/2)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		q, err := rand.Prime(rand.Reader, bits-bits/2)
+		if err != nil {
+			tb.Fatal(err)
+		}
+		n := new(big.Int).Mul(p, q)
+		if p.Cmp(q) == 0 || n.BitLen() != bits {
+			continue
+		}
+		phi := new(big.Int).Mul(new(big.Int).Sub(p, i1), new(big.Int).Sub(q, i1))
+		if d := new(big.Int).ModInverse(e, phi); d != nil {
+			return NewPublicKey(e, n), NewPrivateKey(d)
+		}
+	}
+}
+
+// случайные данные длиной size байт
+func randomBytes(tb testing.TB, size int) []byte {
+	tb.Helper()
+	data := make([]byte, size)
+	if _, err := rand.Read(data); err != nil {
+		tb.Fatal(err)
+	}
+	return data
+}
+
+// зашифрование через EncryptWriter порциями по chunk байт и расшифрование через DecryptReader
+func roundTrip(t *testing.T, pubKey *PublicKey, privKey *PrivateKey, plain []byte, chunk, jobs int) []byte {
+	t.Helper()
+	var chipher bytes.Buffer
+	ew := NewEncryptWriterContext(context.Background(), &chipher, pubKey, jobs)
+	for rest := plain; len(rest) > 0; {
+		n := chunk
+		if n > len(rest) {
+			n = len(rest)
+		}
+		if _, err := ew.Write(rest[:n]); err != nil {
+			t.Fatal(err)
+		}
+		rest = rest[n:]
+	}
+	if err := ew.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// последний блок пишется всегда, за блоками - длина открытого текста
+	blocks := len(plain)/plainBlockSize(pubKey.N) + 1
+	if want := blocks*chipherBlockSize(pubKey.N) + plainLenSize; chipher.Len() != want {
+		t.Fatalf("длина шифра %d байт, ожидалось %d", chipher.Len(), want)
+	}
+
+	got, err := io.ReadAll(NewDecryptReaderContext(context.Background(), &chipher, privKey, pubKey, jobs))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return got
+}
+
+// decrypt(encrypt(x)) == x байт в байт, включая нулевые и ведущие нулевые байты
+func TestStreamRoundTrip(t *testing.T) {
+	pubKey, privKey := testKeyPair(t, 512)
+	size := plainBlockSize(pubKey.N)
+
+	cases := map[string][]byte{
+		"пустой":                    {},
+		"один нулевой байт":         {0},
+		"нулевой блок":              make([]byte, size),
+		"нулевые несколько блоков":  make([]byte, 5*size+3),
+		"ведущий 0x00":              append([]byte{0}, randomBytes(t, 2*size)...),
+		"ведущие нули на весь блок": append(make([]byte, size+1), randomBytes(t, 7)...),
+		"0xff": bytes.Repeat([]byte{0xff}, 3*size),
+	}
+	// длины на границах блоков и около них
+	for k := 1; k <= 3; k++ {
+		for _, d := range []int{-1, 0, 1} {
+			cases[fmt.Sprintf("%d блока %+d", k, d)] = randomBytes(t, k*size+d)
+		}
+	}
+	// случайные двоичные данные случайной длины
+	for i := 0; i < 10; i++ {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(20*size)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		cases[fmt.Sprintf("случайные %d", i)] = randomBytes(t, int(n.Int64()))
+	}
+
+	for name, plain := range cases {
+		for _, chunk := range []int{1, size - 1, 4096} {
+			for _, jobs := range []int{1, 4} {
+				got := roundTrip(t, pubKey, privKey, plain, chunk, jobs)
+				if !bytes.Equal(got, plain) {
+					t.Errorf("%s, порции по %d, горутин %d: расшифровано %d байт вместо %d, данные не совпадают",
+						name, chunk, jobs, len(got), len(plain))
+				}
+			}
+		}
+	}
+}
+
+// ShipherBytes и DeShipherBytes целиком в памяти дают тот же результат
+func TestShipherBytesRoundTrip(t *testing.T) {
+	pubKey, privKey := testKeyPair(t, 512)
+	for _, plain := range [][]byte{{}, make([]byte, 1000), append([]byte{0, 0, 0}, randomBytes(t, 1000)...)} {
+		chipher, err := pubKey.ShipherBytes(plain)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := privKey.DeShipherBytes(chipher, pubKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(got, plain) {
+			t.Errorf("расшифровано %d байт вместо %d, данные не совпадают", len(got), len(plain))
+		}
+	}
+}
+
+// Время зашифрования должно расти линейно с размером входа:
+// в выводе -bench значение MB/s одинаково для всех размеров
+func BenchmarkEncryptWriter(b *testing.B) {
+	pubKey, _ := testKeyPair(b, 2048)
+	for _, size := range []int{64 << 10, 256 << 10, 1 << 20, 4 << 20} {
+		data := randomBytes(b, size)
+		b.Run(fmt.Sprintf("%dKB", size>>10), func(b *testing.B) {
+			b.SetBytes(int64(size))
+			for i := 0; i < b.N; i++ {
+				ew := NewEncryptWriter(io.Discard, pubKey)
+				if _, err := ew.Write(data); err != nil {
+					b.Fatal(err)
+				}
+				if err := ew.Close(); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// Прежний кодек на битовых строках (до перехода на байты), оставлен как эталон для сравнения скорости.
+// Сообщение переводится в строку из символов '0' и '1', режется на блоки по log2(n) бит,
+// шифр каждого блока дополняется нолями до log2(n) + 1 символа.
+func stringLog2(num *big.Int) int64 {
+	var i int64 = 1
+	res := new(big.Int).Set(i2)
+	for {
+		res = new(big.Int).Mul(res, i2)
+		if res.Cmp(num) >= 0 {
+			return i
+		}
+		i++
+	}
+}
+
+func stringShipherBytes(pubKey *PublicKey, M []byte) string {
+	var bitsM string
+	var chipher string
+	m := []string{}
+
+	logN := stringLog2(pubKey.N)
+
+	// переводим в битовое представление
+	for _, bytes := range M {
+		bitsM += fmt.Sprintf("%08b", bytes)
+	}
+
+	// разбиваем на блоки log2(n) битовое представление сообщения
+	for i := int64(len(bitsM)); i > int64(0); i -= logN {
+		var blockM string
+		if i-logN < 0 {
+			blockM = bitsM[:i]
+		} else {
+			blockM = bitsM[i-logN : i]
+		}
+		m = append(m, blockM)
+	}
+
+	// последний блок дополняем нолями слева
+	l := int64(len(m[len(m)-1]))
+	if l != logN {
+		var blockM string
+		for i := l; i < logN; i++ {
+			blockM += "0"
+		}
+		m[len(m)-1] = blockM + m[len(m)-1]
+	}
+
+	// шифруем поблочно
+	for _, mBytes := range m {
+		mBlock, _ := new(big.Int).SetString(mBytes, 2)
+		chiperbits := exp(mBlock, pubKey.E, pubKey.N).Text(2)
+		if int64(len(chiperbits)) < int64(logN+1) {
+			for i := len(chiperbits); i < int(logN)+1; i++ {
+				chiperbits = "0" + chiperbits
+			}
+		}
+		chipher += chiperbits
+	}
+	return chipher
+}
+
+func stringDeShipherBytes(privKey *PrivateKey, chiper string, pubKey *PublicKey) []byte {
+	bitM := []string{}
+	M := []byte{}
+	chipherBlocks := []string{}
+
+	logN := stringLog2(pubKey.N)
+
+	for i := int64(0); i < int64(len(chiper)); i += logN + 1 {
+		chipherBlocks = append(chipherBlocks, chiper[i:i+logN+1])
+	}
+
+	// M = c ^ d (mod n) в битовом представлении
+	for _, cBites := range chipherBlocks {
+		c, _ := new(big.Int).SetString(cBites, 2)
+		dechipher := exp(c, privKey.D, pubKey.N)
+		bitM = append(bitM, fmt.Sprintf("%08b", dechipher))
+	}
+
+	// дополняем блоки до исходного размера
+	for i := range bitM {
+		l := int64(len(bitM[i]))
+		var blockM string
+		for i := l; i < logN; i++ {
+			blockM += "0"
+		}
+		bitM[i] = blockM + bitM[i]
+	}
+
+	var m string
+	for i := len(bitM) - 1; i >= 0; i-- {
+		m += bitM[i]
+	}
+
+	// бьем на байты с конца
+	for i := len(m); i >= 0; i -= 8 {
+		if i-8 < 0 {
+			break
+		}
+		c, _ := new(big.Int).SetString(m[i-8:i], 2)
+		M = append(c.Bytes(), M...)
+	}
+	return M
+}
+
+// Зашифрование и расшифрование байтовым кодеком и прежним кодеком на битовых строках.
+// Степень в обоих случаях считается одинаково (exp), поэтому разница - только в разбиении на блоки и сборке.
+// Байтовый кодек линеен по длине входа, прежний квадратичен: начиная с 64 КБ при росте входа в 4 раза
+// время растет в 13-15 раз, и 1 МБ обрабатывается им больше 9 минут. Поэтому 1 МБ сравнивается только с CODEC_BENCH_1MB=1 и -timeout 0:
+//
+//	CODEC_BENCH_1MB=1 go test ./utils -run - -bench Codec -benchtime 1x -timeout 0
+func BenchmarkCodec(b *testing.B) {
+	pubKey, privKey := testKeyPair(b, 1024)
+	sizes := []int{16 << 10, 64 << 10, 256 << 10}
+	if os.Getenv("CODEC_BENCH_1MB") != "" {
+		sizes = append(sizes, 1<<20)
+	}
+	for _, size := range sizes {
+		data := randomBytes(b, size)
+		b.Run(fmt.Sprintf("bytes/%dKB", size>>10), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				chipher, err := pubKey.ShipherBytes(data)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if _, err := privKey.DeShipherBytes(chipher, pubKey); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+		b.Run(fmt.Sprintf("strings/%dKB", size>>10), func(b *testing.B) {
+			b.SetBytes(int64(len(data)))
+			for i := 0; i < b.N; i++ {
+				stringDeShipherBytes(privKey, stringShipherBytes(pubKey, data), pubKey)
+			}
+		})
+	}
+}
@@ -0,0 +1,241 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"math/big"
+)
+
+// Метод Уильямса p + 1.
+// Последовательность Люка V_0 = 2, V_1 = A, V_{k+1} = A * V_k - V_{k-1} по модулю p имеет период,
+// делящий p - (D/p), где D = A^2 - 4 и (D/p) - символ Лежандра. Если p + 1 гладкое и (D/p) = -1,
+// то для M - произведения степеней простых до B1 - V_M(A) = 2 (mod p), и p делит НОД(V_M - 2, n).
+// Символ (D/p) заранее неизвестен, поэтому перебираются несколько начальных A с разными D
+// (при (D/p) = 1 метод работает как p - 1). Этап 2 ищет один дополнительный простой делитель r из (B1, B2]:
+// V_r(V_M(A)) = V_(M*r)(A), значения V_r для r = ±1 (mod 6) получаются шагами V_{k+6} = V_k * V_6 - V_{k-6}.
+
+// Начальные значения A: у A^2 - 4 попарно разные свободные от квадратов части (5, 3, 21, 2, 15, 77, 6, 13, 35, 165),
+// поэтому символы (D/p) для них независимы
+var williamsSeeds = []int64{3, 4, 5, 6, 8, 9, 10, 11, 12, 13}
+
+// Параметры метода p + 1
+type WilliamsPP1Params struct {
+	// границы гладкости этапов 1 и 2, при B2 <= B1 этап 2 не выполняется
+	B1, B2 uint64
+	// количество начальных значений A, от 1 до 10
+	Seeds int
+	// вызывается периодически с начальным A, номером этапа и долей выполненной работы этапа, может быть nil
+	Progress func(seed int64, stage int, done float64)
+}
+
+// Параметры по умолчанию
+var DefaultWilliamsPP1Params = WilliamsPP1Params{B1: 200000, B2: 20000000, Seeds: 3}
+
+// Результат метода p + 1
+type WilliamsPP1Result struct {
+	// множители n, nil - если не найдены
+	P, Q *big.Int
+	// начальное A и этап, на котором найден множитель, 0 - множитель не найден
+	Seed  int64
+	Stage int
+}
+
+// V_k(a) (mod n) лестницей: пара (V_j, V_{j+1}) переходит в (V_2j, V_2j+1) или (V_2j+1, V_2j+2)
+// V_2j = V_j^2 - 2, V_2j+1 = V_j * V_{j+1} - a
+func lucasV(a, k, n *big.Int) *big.Int {
+	if k.Sign() == 0 {
+		return big.NewInt(2)
+	}
+	x := new(big.Int).Set(a)
+	y := new(big.Int).Mul(a, a)
+	y.Sub(y, i2)
+	y.Mod(y, n)
+	t := new(big.Int)
+	for i := k.BitLen() - 2; i >= 0; i-- {
+		t.Mul(x, y)
+		t.Sub(t, a)
+		t.Mod(t, n)
+		if k.Bit(i) == 1 {
+			// (V_2j+1, V_2j+2)
+			y.Mul(y, y)
+			y.Sub(y, i2)
+			y.Mod(y, n)
+			x.Set(t)
+		} else {
+			// (V_2j, V_2j+1)
+			x.Mul(x, x)
+			x.Sub(x, i2)
+			x.Mod(x, n)
+			y.Set(t)
+		}
+	}
+	return x
+}
+
+// Разложение n методом Уильямса p + 1.
+// Если множитель не найден ни с одним начальным A, возвращается результат с P == nil и без ошибки.
+func WilliamsPP1(ctx context.Context, n *big.Int, params WilliamsPP1Params) (*WilliamsPP1Result, error) {
+	if n.Cmp(big.NewInt(4)) < 0 || n.Bit(0) == 0 {
+		return nil, errors.New("n должно быть нечетным и больше 3")
+	}
+	if params.B1 < 2 {
+		return nil, errors.New("граница B1 должна быть не меньше 2")
+	}
+	if params.Seeds < 1 || params.Seeds > len(williamsSeeds) {
+		return nil, errors.New("количество начальных значений должно быть от 1 до 10")
+	}
+	progress := params.Progress
+	if progress == nil {
+		progress = func(int64, int, float64) {}
+	}
+
+	result := &WilliamsPP1Result{}
+	for _, seed := range williamsSeeds[:params.Seeds] {
+		g, stage, err := williamsSeed(ctx, n, big.NewInt(seed), params, func(stage int, done float64) {
+			progress(seed, stage, done)
+		})
+		if err != nil {
+			return nil, err
+		}
+		if g != nil {
+			result.P, result.Q = orderedFactors(n, g)
+			result.Seed, result.Stage = seed, stage
+			return result, nil
+		}
+	}
+	return result, nil
+}
+
+// Оба этапа метода p + 1 для одного начального a.
+// Возвращает делитель и этап, на котором он найден, или nil.
+func williamsSeed(ctx context.Context, n, a *big.Int, params WilliamsPP1Params, progress func(stage int, done float64)) (*big.Int, int, error) {
+	var found *big.Int
+	done := false
+	vm2 := new(big.Int)
+
+	// этап 1: a = V_(q^k)(a) для всех простых q <= B1, степени пачки перемножаются в один показатель
+	checkpoint := new(big.Int).Set(a)
+	var powers []*big.Int
+	var err error
+	flush := func() {
+		exponent := big.NewInt(1)
+		for _, pk := range powers {
+			exponent.Mul(exponent, pk)
+		}
+		a = lucasV(a, exponent, n)
+		g, all := splitGCD(vm2.Sub(a, i2), n)
+		if all {
+			// все множители n найдены сразу: повторяем пачку с проверкой после каждой степени
+			a.Set(checkpoint)
+			for _, pk := range powers {
+				a = lucasV(a, pk, n)
+				if g, all = splitGCD(vm2.Sub(a, i2), n); g != nil || all {
+					break
+				}
+			}
+		}
+		found, done = g, g != nil || all
+		checkpoint.Set(a)
+		powers = powers[:0]
+	}
+	forEachPrime(2, params.B1, func(q uint64) bool {
+		pk := q
+		for pk <= params.B1/q {
+			pk *= q
+		}
+		powers = append(powers, new(big.Int).SetUint64(pk))
+		if len(powers) == pm1Batch {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+			flush()
+			progress(1, float64(q)/float64(params.B1))
+		}
+		return !done
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if !done && len(powers) > 0 {
+		flush()
+	}
+	if !done {
+		progress(1, 1)
+	}
+	if done || params.B2 <= params.B1 {
+		return found, 1, nil
+	}
+
+	// этап 2: acc = Π (V_r(a) - 2) по простым r из (B1, B2]
+	// для классов r = 1 и r = 5 (mod 6) храним пары (V_{k-6}, V_k) и шагаем по k с шагом 6
+	v6 := lucasV(a, big.NewInt(6), n)
+	type walk struct {
+		prev, cur *big.Int
+		k         uint64
+	}
+	var walks [6]*walk
+	vr := func(r uint64) *big.Int {
+		if r < 5 {
+			return lucasV(a, new(big.Int).SetUint64(r), n)
+		}
+		w := walks[r%6]
+		if w == nil {
+			w = &walk{
+				prev: lucasV(a, new(big.Int).SetUint64(r-6), n),
+				cur:  lucasV(a, new(big.Int).SetUint64(r), n),
+				k:    r,
+			}
+			walks[r%6] = w
+		}
+		for w.k < r {
+			// V_{k+6} = V_k * V_6 - V_{k-6}
+			next := new(big.Int).Mul(w.cur, v6)
+			next.Sub(next, w.prev)
+			next.Mod(next, n)
+			w.prev, w.cur = w.cur, next
+			w.k += 6
+		}
+		return w.cur
+	}
+
+	acc := big.NewInt(1)
+	var batch []*big.Int
+	check := func() {
+		g, all := splitGCD(acc, n)
+		if all {
+			// повторяем пачку с проверкой каждого простого
+			for _, v := range batch {
+				if g, all = splitGCD(vm2.Sub(v, i2), n); g != nil || all {
+					break
+				}
+			}
+		}
+		found, done = g, g != nil || all
+		batch = batch[:0]
+		acc.SetInt64(1)
+	}
+	forEachPrime(params.B1+1, params.B2, func(r uint64) bool {
+		v := new(big.Int).Set(vr(r))
+		batch = append(batch, v)
+		acc.Mul(acc, vm2.Sub(v, i2))
+		acc.Mod(acc, n)
+		if len(batch) == pm1Batch {
+			if err = ctx.Err(); err != nil {
+				return false
+			}
+			check()
+			progress(2, float64(r-params.B1)/float64(params.B2-params.B1))
+		}
+		return !done
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	if !done && len(batch) > 0 {
+		check()
+	}
+	if !done {
+		progress(2, 1)
+	}
+	return found, 2, nil
+}
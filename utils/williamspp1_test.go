@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"context"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+// V_k(a) по лестнице совпадает с прямой рекуррентностью V_{k+1} = a * V_k - V_{k-1}
+func TestLucasV(t *testing.T) {
+	n := big.NewInt(1000003)
+	for _, a := range []int64{3, 4, 10} {
+		prev, cur := big.NewInt(2), big.NewInt(a)
+		for k := int64(1); k < 300; k++ {
+			if got := lucasV(big.NewInt(a), big.NewInt(k), n); got.Cmp(cur) != 0 {
+				t.Fatalf("V_%d(%d) = %s, ожидалось %s", k, a, got, cur)
+			}
+			next := new(big.Int).Mul(cur, big.NewInt(a))
+			next.Sub(next, prev)
+			next.Mod(next, n)
+			prev, cur = cur, next
+		}
+		if lucasV(big.NewInt(a), new(big.Int), n).Int64() != 2 {
+			t.Fatalf("V_0(%d) != 2", a)
+		}
+	}
+}
+
+func TestWilliamsPP1(t *testing.T) {
+	random := rand.New(rand.NewSource(3))
+	ctx := context.Background()
+	params := WilliamsPP1Params{B1: 1000, B2: 100000, Seeds: len(williamsSeeds)}
+	q := testPrime(t, random, 128)
+
+	// этап 1: p + 1 гладкое до B1
+	p := smoothPrime(t, random, 1000, 96, 1, -1)
+	result, err := WilliamsPP1(ctx, new(big.Int).Mul(p, q), params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.P == nil || result.P.Cmp(p) != 0 || result.Q.Cmp(q) != 0 || result.Stage != 1 {
+		t.Fatalf("этап 1: %+v", result)
+	}
+	// при этом p - 1 не гладкое: метод p - 1 его не находит
+	pm1, err := PollardPM1(ctx, new(big.Int).Mul(p, q), PollardPM1Params{B1: 1000, B2: 100000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pm1.P != nil {
+		t.Fatalf("метод p - 1 разложил n: %+v", pm1)
+	}
+
+	// этап 2: p + 1 = s * r, r - простое из (B1, B2]
+	r := nextPrime(big.NewInt(50000)).Int64()
+	p = smoothPrime(t, random, 1000, 96, r, -1)
+	n := new(big.Int).Mul(p, q)
+	result, err = WilliamsPP1(ctx, n, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.P == nil || result.P.Cmp(p) != 0 || result.Stage != 2 {
+		t.Fatalf("этап 2: %+v", result)
+	}
+	params.B2 = 0
+	result, err = WilliamsPP1(ctx, n, params)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.P != nil {
+		t.Fatalf("без этапа 2: %+v", result)
+	}
+
+	for _, bad := range []WilliamsPP1Params{{B1: 1, Seeds: 1}, {B1: 1000, Seeds: 0}, {B1: 1000, Seeds: 11}} {
+		if _, err := WilliamsPP1(ctx, n, bad); err == nil {
+			t.Fatalf("параметры %+v приняты", bad)
+		}
+	}
+}